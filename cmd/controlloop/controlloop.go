@@ -17,14 +17,19 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/go-co-op/gocron/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -36,10 +41,13 @@ import (
 	nadinformers "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/client/informers/externalversions"
 
 	"github.com/k8snetworkplumbingwg/whereabouts/pkg/controlloop"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/daemon"
 	wbclient "github.com/k8snetworkplumbingwg/whereabouts/pkg/generated/clientset/versioned"
 	wbinformers "github.com/k8snetworkplumbingwg/whereabouts/pkg/generated/informers/externalversions"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/journal"
 	"github.com/k8snetworkplumbingwg/whereabouts/pkg/logging"
 	"github.com/k8snetworkplumbingwg/whereabouts/pkg/reconciler"
+	wbk8s "github.com/k8snetworkplumbingwg/whereabouts/pkg/storage/kubernetes"
 )
 
 const (
@@ -54,14 +62,22 @@ const (
 	cronSchedulerCreationError
 	fileWatcherError
 	couldNotCreateConfigWatcherError
+	couldNotRegisterMetrics
+	couldNotStartDaemon
 )
 
 const (
-	defaultLogLevel = "debug"
+	defaultLogLevel               = "debug"
+	defaultPendingReleaseInterval = time.Minute
 )
 
 func main() {
 	logLevel := flag.String("log-level", defaultLogLevel, "Specify the pod controller application logging level")
+	pendingReleasesDir := flag.String("pending-releases-dir", controlloop.DefaultPendingReleasesDir, "Directory of the node's pending-release journal")
+	pendingReleasesInterval := flag.Duration("pending-releases-interval", defaultPendingReleaseInterval, "How often the node's pending-release journal is drained")
+	metricsBindAddress := flag.String("metrics-bind-address", "", "Address the metrics endpoint binds to, e.g. :9090; disabled when empty")
+	daemonSocket := flag.String("daemon-socket", "", "Unix socket the node-local allocation daemon serves on, as mounted in the container; disabled when empty")
+	flag.Parse()
 	if logLevel != nil && logging.GetLoggingLevel().String() != *logLevel {
 		logging.SetLogLevel(*logLevel)
 	}
@@ -82,6 +98,28 @@ func main() {
 	networkController.Start(stopChan)
 	defer networkController.Shutdown()
 
+	if err := journal.RegisterMetrics(prometheus.DefaultRegisterer); err != nil {
+		_ = logging.Errorf("could not register the pending-release journal metrics: %v", err)
+		os.Exit(couldNotRegisterMetrics)
+	}
+	if *metricsBindAddress != "" {
+		go serveMetrics(*metricsBindAddress)
+	}
+
+	pendingReleasesDrainer, err := newPendingReleasesDrainer(*pendingReleasesDir, *pendingReleasesInterval)
+	if err != nil {
+		_ = logging.Errorf("could not create the pending releases drainer: %v", err)
+		os.Exit(couldNotCreateController)
+	}
+	pendingReleasesDrainer.Start(stopChan)
+
+	if *daemonSocket != "" {
+		if err := startDaemon(*daemonSocket, stopChan); err != nil {
+			_ = logging.Errorf("could not start the allocation daemon: %v", err)
+			os.Exit(couldNotStartDaemon)
+		}
+	}
+
 	s, err := gocron.NewScheduler(gocron.WithLocation(time.UTC))
 	if err != nil {
 		os.Exit(cronSchedulerCreationError)
@@ -163,6 +201,12 @@ func newPodController(stopChannel chan struct{}) (*controlloop.PodController, er
 		return nil, err
 	}
 
+	namespace := wbk8s.ResolveNamespace("", "")
+	logging.Verbosef("using the whereabouts namespace %s", namespace)
+	if err := wbk8s.CheckNamespace(context.Background(), wbClientSet, namespace); err != nil {
+		_ = logging.Errorf("the whereabouts namespace does not match the existing IPPools: %v", err)
+	}
+
 	const noResyncPeriod = 0
 	ipPoolInformerFactory := wbinformers.NewSharedInformerFactory(wbClientSet, noResyncPeriod)
 	netAttachDefInformerFactory := nadinformers.NewSharedInformerFactory(nadK8sClientSet, noResyncPeriod)
@@ -190,6 +234,72 @@ func newPodController(stopChannel chan struct{}) (*controlloop.PodController, er
 	return controller, nil
 }
 
+func newPendingReleasesDrainer(dir string, interval time.Duration) (*controlloop.PendingReleasesDrainer, error) {
+	cfg, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to implicitly generate the kubeconfig: %w", err)
+	}
+
+	k8sClientSet, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the Kubernetes client: %w", err)
+	}
+
+	wbClientSet, err := wbclient.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return controlloop.NewPendingReleasesDrainer(k8sClientSet, wbClientSet, dir, interval), nil
+}
+
+func startDaemon(socketPath string, stopChan chan struct{}) error {
+	cfg, err := rest.InClusterConfig()
+	if err != nil {
+		return fmt.Errorf("failed to implicitly generate the kubeconfig: %w", err)
+	}
+
+	k8sClientSet, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create the Kubernetes client: %w", err)
+	}
+
+	wbClientSet, err := wbclient.NewForConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	listener, err := daemon.Listen(socketPath)
+	if err != nil {
+		return err
+	}
+
+	const noResyncPeriod = 0
+	wbInformerFactory := wbinformers.NewSharedInformerFactory(wbClientSet, noResyncPeriod)
+	server := daemon.NewServer(k8sClientSet, wbClientSet, wbInformerFactory, "whereabouts-daemon/"+os.Getenv("NODENAME"))
+	wbInformerFactory.Start(stopChan)
+	go func() {
+		if err := server.Serve(listener, stopChan); err != nil {
+			_ = logging.Errorf("allocation daemon failure: %v", err)
+		}
+	}()
+	return nil
+}
+
+func serveMetrics(bindAddress string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	server := &http.Server{
+		Addr:              bindAddress,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	logging.Verbosef("serving metrics on %s", bindAddress)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		_ = logging.Errorf("metrics endpoint failure: %v", err)
+	}
+}
+
 func newEventBroadcaster(k8sClientset kubernetes.Interface) record.EventBroadcaster {
 	eventBroadcaster := record.NewBroadcaster()
 	eventBroadcaster.StartLogging(logging.Verbosef)
@@ -0,0 +1,237 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/clientcmd"
+
+	nadclient "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/client/clientset/versioned"
+
+	whereaboutsv1alpha1 "github.com/k8snetworkplumbingwg/whereabouts/pkg/api/whereabouts.cni.cncf.io/v1alpha1"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/config"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/fsck"
+	clientset "github.com/k8snetworkplumbingwg/whereabouts/pkg/generated/clientset/versioned"
+	wbkubernetes "github.com/k8snetworkplumbingwg/whereabouts/pkg/storage/kubernetes"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+const (
+	_ int = iota
+	// problemsFound is the exit code when problems are left unrepaired
+	problemsFound
+	invalidFlags
+	couldNotCreateClients
+	couldNotListPools
+	couldNotRepairPools
+)
+
+var (
+	masterURL    string
+	kubeconfig   string
+	namespace    string
+	configPath   string
+	outputFormat string
+	repair       bool
+	dryRun       bool
+	assumeYes    bool
+)
+
+// ippool-fsck checks the IPPools of a namespace, and repairs them on demand: the allocations of the repairable
+// problems are removed from their pools, once confirmed.
+func main() {
+	flag.Parse()
+	if outputFormat != "text" && outputFormat != "json" {
+		fmt.Fprintf(os.Stderr, "unsupported output format %q, it must be text or json\n", outputFormat)
+		os.Exit(invalidFlags)
+	}
+
+	cfg, err := clientcmd.BuildConfigFromFlags(masterURL, kubeconfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error building kubeconfig: %v\n", err)
+		os.Exit(couldNotCreateClients)
+	}
+	whereaboutsClient, err := clientset.NewForConfig(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error building whereabouts clientset: %v\n", err)
+		os.Exit(couldNotCreateClients)
+	}
+	nadClient, err := nadclient.NewForConfig(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error building network-attachment-definition clientset: %v\n", err)
+		os.Exit(couldNotCreateClients)
+	}
+
+	if namespace == "" {
+		namespace = wbkubernetes.ResolveNamespace("", kubeconfig)
+	}
+
+	ctx := context.Background()
+	poolList, err := whereaboutsClient.WhereaboutsV1alpha1().IPPools(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error listing the IPPools: %v\n", err)
+		os.Exit(couldNotListPools)
+	}
+	rangeConfiguration, err := nadRangeConfiguration(ctx, nadClient)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error listing the network-attachment-definitions: %v\n", err)
+		os.Exit(couldNotListPools)
+	}
+
+	report := fsck.Check(poolList.Items, rangeConfiguration)
+	printReport(os.Stdout, report)
+
+	unrepaired := len(report.Problems)
+	if repair {
+		repaired, err := repairPools(ctx, whereaboutsClient, poolList.Items, report)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error repairing the IPPools: %v\n", err)
+			os.Exit(couldNotRepairPools)
+		}
+		unrepaired -= repaired
+	}
+	if unrepaired > 0 {
+		os.Exit(problemsFound)
+	}
+}
+
+// nadRangeConfiguration returns the configuration of the range of an IPPool in the network-attachment-definitions
+// of the cluster, or the bare range of the pool when none configures it.
+func nadRangeConfiguration(ctx context.Context, nadClient nadclient.Interface) (fsck.RangeConfiguration, error) {
+	nads, err := nadClient.K8sCniCncfIoV1().NetworkAttachmentDefinitions(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	type nadIPAMConf struct {
+		namespace string
+		ipamConf  *types.IPAMConfig
+	}
+	var ipamConfs []nadIPAMConf
+	for _, nad := range nads.Items {
+		// the network-attachment-definitions of other IPAM plugins are skipped
+		if ipamConf, err := config.LoadIPAMConfiguration([]byte(nad.Spec.Config), "", configPath); err == nil {
+			ipamConfs = append(ipamConfs, nadIPAMConf{namespace: nad.GetNamespace(), ipamConf: ipamConf})
+		}
+	}
+
+	return func(pool *whereaboutsv1alpha1.IPPool) types.RangeConfiguration {
+		poolName := pool.GetName()
+		if pool.Spec.Shard != nil {
+			poolName = pool.Spec.Shard.Parent
+		}
+		for _, nadConf := range ipamConfs {
+			// the pools of tenant namespaces only belong to the network-attachment-definitions of their namespace
+			if nadConf.ipamConf.TenantNamespaces && nadConf.namespace != pool.GetNamespace() {
+				continue
+			}
+			if rangeConf, ok := wbkubernetes.IPPoolRangeConfiguration(poolName, nadConf.ipamConf); ok {
+				return rangeConf
+			}
+		}
+		return types.RangeConfiguration{Range: pool.Spec.Range}
+	}, nil
+}
+
+func printReport(out io.Writer, report fsck.Report) {
+	if outputFormat == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(report)
+		return
+	}
+
+	fmt.Fprintf(out, "checked %d allocations of %d IPPools, found %d problems\n", report.Allocations, report.Pools, len(report.Problems))
+	if len(report.Problems) == 0 {
+		return
+	}
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "POOL\tOFFSET\tTYPE\tREPAIRABLE\tMESSAGE")
+	for _, problem := range report.Problems {
+		fmt.Fprintf(w, "%s/%s\t%s\t%s\t%t\t%s\n", problem.Namespace, problem.Pool, problem.Offset, problem.Type, problem.Repairable, problem.Message)
+	}
+	_ = w.Flush()
+}
+
+// repairPools removes the allocations of the repairable problems from their pools, unless running dry, once
+// confirmed. The pools are updated as long as they were not changed since they were checked. It returns the number of
+// problems repaired.
+func repairPools(ctx context.Context, client clientset.Interface, pools []whereaboutsv1alpha1.IPPool, report fsck.Report) (int, error) {
+	var repairedPools []*whereaboutsv1alpha1.IPPool
+	var repairs int
+	for idx := range pools {
+		repairedPool, repaired := fsck.Repair(&pools[idx], report)
+		if len(repaired) == 0 {
+			continue
+		}
+		repairedPools = append(repairedPools, repairedPool)
+		repairs += len(repaired)
+		for _, problem := range repaired {
+			fmt.Fprintf(os.Stderr, "removing offset %s from IPPool %s/%s: %s\n", problem.Offset, problem.Namespace, problem.Pool, problem.Message)
+		}
+	}
+	if repairs == 0 {
+		fmt.Fprintln(os.Stderr, "no repairable problem found")
+		return 0, nil
+	}
+	if dryRun {
+		fmt.Fprintf(os.Stderr, "dry run: %d allocations of %d IPPools would be removed\n", repairs, len(repairedPools))
+		return 0, nil
+	}
+	if !assumeYes && !confirm(fmt.Sprintf("remove %d allocations of %d IPPools?", repairs, len(repairedPools))) {
+		fmt.Fprintln(os.Stderr, "repair cancelled")
+		return 0, nil
+	}
+
+	for _, pool := range repairedPools {
+		if _, err := client.WhereaboutsV1alpha1().IPPools(pool.GetNamespace()).Update(ctx, pool, metav1.UpdateOptions{}); err != nil {
+			return 0, fmt.Errorf("failed to update IPPool %s/%s: %w", pool.GetNamespace(), pool.GetName(), err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "removed %d allocations of %d IPPools\n", repairs, len(repairedPools))
+	return repairs, nil
+}
+
+func confirm(question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func init() {
+	flag.StringVar(&kubeconfig, "kubeconfig", "", "Path to a kubeconfig. Only required if out-of-cluster.")
+	flag.StringVar(&masterURL, "master", "", "The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster.")
+	flag.StringVar(&namespace, "namespace", "", "Namespace of the IPPools to check; resolved like the other whereabouts components when empty")
+	flag.StringVar(&configPath, "config-path", "/etc/cni/net.d/whereabouts.d/whereabouts.conf", "Path to the whereabouts flat configuration file the network-attachment-definitions are read with")
+	flag.StringVar(&outputFormat, "output", "text", "Format of the report: text or json")
+	flag.BoolVar(&repair, "repair", false, "Remove the allocations of the repairable problems from their IPPools")
+	flag.BoolVar(&dryRun, "dry-run", false, "With -repair, only print the allocations which would be removed")
+	flag.BoolVar(&assumeYes, "yes", false, "With -repair, do not ask for confirmation before removing the allocations")
+}
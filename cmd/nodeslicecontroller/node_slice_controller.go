@@ -17,9 +17,7 @@
 package main
 
 import (
-	"errors"
 	"flag"
-	"os"
 	"time"
 
 	nadclient "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/client/clientset/versioned"
@@ -33,6 +31,8 @@ import (
 	informers "github.com/k8snetworkplumbingwg/whereabouts/pkg/generated/informers/externalversions"
 	node_controller "github.com/k8snetworkplumbingwg/whereabouts/pkg/node-controller"
 	"github.com/k8snetworkplumbingwg/whereabouts/pkg/node-controller/signals"
+	pool_controller "github.com/k8snetworkplumbingwg/whereabouts/pkg/pool-controller"
+	wbkubernetes "github.com/k8snetworkplumbingwg/whereabouts/pkg/storage/kubernetes"
 )
 
 var (
@@ -73,10 +73,10 @@ func main() {
 		klog.FlushAndExit(klog.ExitFlushTimeout, 1)
 	}
 
-	whereaboutsNamespace := os.Getenv("WHEREABOUTS_NAMESPACE")
-	if whereaboutsNamespace == "" {
-		logger.Error(errors.New("env var for WHEREABOUTS_NAMESPACE not set"), "unable to discover namespace")
-		klog.FlushAndExit(klog.ExitFlushTimeout, 1)
+	whereaboutsNamespace := wbkubernetes.ResolveNamespace("", kubeconfig)
+	logger.Info("Using the whereabouts namespace", "namespace", whereaboutsNamespace)
+	if err := wbkubernetes.CheckNamespace(ctx, whereaboutsClient, whereaboutsNamespace); err != nil {
+		logger.Error(err, "The whereabouts namespace does not match the existing IPPools")
 	}
 
 	kubeInformerFactory := kubeinformers.NewSharedInformerFactory(kubeClient, time.Second*30)
@@ -95,12 +95,27 @@ func main() {
 		whereaboutsNamespace,
 	)
 
+	poolController := pool_controller.NewController(
+		ctx,
+		whereaboutsClient,
+		whereaboutsInformerFactory.Whereabouts().V1alpha1().IPPools(),
+		nadInformerFactory.K8sCniCncfIo().V1().NetworkAttachmentDefinitions(),
+		whereaboutsInformerFactory.Whereabouts().V1alpha1().NodeSlicePools(),
+	)
+
 	// notice that there is no need to run Start methods in a separate goroutine. (i.e. go kubeInformerFactory.Start(ctx.done())
 	// Start method is non-blocking and runs all registered informers in a dedicated goroutine.
 	kubeInformerFactory.Start(ctx.Done())
 	whereaboutsInformerFactory.Start(ctx.Done())
 	nadInformerFactory.Start(ctx.Done())
 
+	go func() {
+		if err := poolController.Run(ctx, 1); err != nil {
+			logger.Error(err, "Error running IPPool controller")
+			klog.FlushAndExit(klog.ExitFlushTimeout, 1)
+		}
+	}()
+
 	if err = controller.Run(ctx, 1); err != nil {
 		logger.Error(err, "Error running controller")
 		klog.FlushAndExit(klog.ExitFlushTimeout, 1)
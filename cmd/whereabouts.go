@@ -19,27 +19,55 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
+	"time"
 
 	"github.com/containernetworking/cni/pkg/skel"
 	cnitypes "github.com/containernetworking/cni/pkg/types"
 	current "github.com/containernetworking/cni/pkg/types/100"
 	cniversion "github.com/containernetworking/cni/pkg/version"
 	"github.com/k8snetworkplumbingwg/whereabouts/pkg/config"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/daemon"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/ipmanagement"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/journal"
 	"github.com/k8snetworkplumbingwg/whereabouts/pkg/logging"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage/etcd"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage/ipaddress"
 	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage/kubernetes"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage/local"
 	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
 	"github.com/k8snetworkplumbingwg/whereabouts/pkg/version"
 )
 
+const (
+	// pendingReleasesDrainLimit and pendingReleasesDrainTimeLimit bound the share of the pending releases a DEL
+	// drains after its own release, so that it does not wait for a large backlog; the control loop drains the rest.
+	pendingReleasesDrainLimit     = 3
+	pendingReleasesDrainTimeLimit = 5 * time.Second
+)
+
+// daemonSocketPath is where the node-local whereabouts daemon listens, when it is enabled.
+var daemonSocketPath = daemon.DefaultSocketPath
+
 func cmdAddFunc(args *skel.CmdArgs) error {
 	ipamConf, confVersion, err := config.LoadIPAMConfig(args.StdinData, args.Args)
 	if err != nil {
 		logging.Errorf("IPAM configuration load failed: %s", err)
-		return err
+		return storage.CNIError(&storage.InvalidConfigError{Err: err})
 	}
 	logging.Debugf("ADD - IPAM configuration successfully read: %+v", *ipamConf)
+	if ipamConf.GetDatastore() != types.DatastoreKubernetes {
+		store, err := newStore(*ipamConf)
+		if err != nil {
+			return storage.CNIError(err)
+		}
+		defer safeCloseStore(store)
+		return cmdAddWithStore(store, args.ContainerID, args.IfName, *ipamConf, confVersion)
+	}
+
 	ipam, err := kubernetes.NewKubernetesIPAM(args.ContainerID, args.IfName, *ipamConf)
 	if err != nil {
 		return logging.Errorf("failed to create Kubernetes IPAM manager: %v", err)
@@ -57,6 +85,14 @@ func cmdDelFunc(args *skel.CmdArgs) error {
 		return err
 	}
 	logging.Debugf("DEL - IPAM configuration successfully read: %+v", *ipamConf)
+	if ipamConf.GetDatastore() != types.DatastoreKubernetes {
+		store, err := newStore(*ipamConf)
+		if err != nil {
+			return storage.CNIError(err)
+		}
+		defer safeCloseStore(store)
+		return cmdDelWithStore(store, args.ContainerID, args.IfName, *ipamConf)
+	}
 
 	ipam, err := kubernetes.NewKubernetesIPAM(args.ContainerID, args.IfName, *ipamConf)
 	if err != nil {
@@ -89,31 +125,89 @@ func cmdCheck(args *skel.CmdArgs) error {
 	return fmt.Errorf("CNI CHECK method is not implemented")
 }
 
+// ipManagement hands the request over to the node-local whereabouts daemon when its socket is present, and
+// manages the IPs directly otherwise.
+func ipManagement(ctx context.Context, mode int, client *kubernetes.KubernetesIPAM) ([]net.IPNet, error) {
+	daemonClient := daemon.NewClient(daemonSocketPath)
+	if daemonClient.Available() {
+		ips, err := daemonClient.IPManagement(ctx, mode, client.ContainerID, client.IfName, client.Namespace, client.Config)
+		if !errors.Is(err, daemon.ErrUnavailable) {
+			return ips, err
+		}
+		logging.Debugf("falling back to direct IP management: %v", err)
+	}
+	return kubernetes.IPManagement(ctx, mode, client.Config, client)
+}
+
 func cmdAdd(client *kubernetes.KubernetesIPAM, cniVersion string) error {
-	// Initialize our result, and assign DNS & routing.
-	result := &current.Result{}
-	result.DNS = client.Config.DNS
-	result.Routes = client.Config.Routes
+	ctx, cancel := context.WithTimeout(context.Background(), types.AddTimeLimit)
+	defer cancel()
 
-	var newips []net.IPNet
+	newips, err := ipManagement(ctx, types.Allocate, client)
+	if err != nil {
+		logging.Errorf("Error at storage engine: %s", err)
+		client.RecordAllocationFailure(err)
+		return storage.CNIError(fmt.Errorf("error at storage engine: %w", err))
+	}
+	return printResult(client.Config, newips, cniVersion)
+}
 
+// newStore returns the storage backend selected by the configuration, other than the Kubernetes one.
+func newStore(ipamConf types.IPAMConfig) (storage.Store, error) {
+	switch ipamConf.GetDatastore() {
+	case types.DatastoreLocal:
+		return local.New(local.Path(ipamConf)), nil
+	case types.DatastoreEtcd:
+		store, err := etcd.New(ipamConf)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case types.DatastoreIPAddress:
+		store, err := ipaddress.New(ipamConf)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	default:
+		return nil, &storage.InvalidConfigError{Err: fmt.Errorf("unsupported datastore %q", ipamConf.Datastore)}
+	}
+}
+
+func safeCloseStore(store storage.Store) {
+	if err := store.Close(); err != nil {
+		_ = logging.Errorf("failed to close the connection to the storage backend: %v", err)
+	}
+}
+
+// cmdAddWithStore allocates the IPs from a storage backend other than the Kubernetes one.
+func cmdAddWithStore(store storage.Store, containerID, ifName string, ipamConf types.IPAMConfig, cniVersion string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), types.AddTimeLimit)
 	defer cancel()
 
-	newips, err := kubernetes.IPManagement(ctx, types.Allocate, client.Config, client)
+	newips, err := ipmanagement.Manage(ctx, types.Allocate, store, ipamConf, containerID, ifName)
 	if err != nil {
 		logging.Errorf("Error at storage engine: %s", err)
-		return fmt.Errorf("error at storage engine: %w", err)
+		return storage.CNIError(fmt.Errorf("error at storage engine: %w", err))
 	}
+	return printResult(ipamConf, newips, cniVersion)
+}
+
+// printResult prints the result of an ADD which allocated newips.
+func printResult(ipamConf types.IPAMConfig, newips []net.IPNet, cniVersion string) error {
+	// Initialize our result, and assign DNS & routing.
+	result := &current.Result{}
+	result.DNS = ipamConf.DNS
+	result.Routes = ipamConf.Routes
 
 	for _, newip := range newips {
 		result.IPs = append(result.IPs, &current.IPConfig{
 			Address: newip,
-			Gateway: client.Config.Gateway})
+			Gateway: ipamConf.Gateway})
 	}
 
 	// Assign all the static IP elements.
-	for _, v := range client.Config.Addresses {
+	for _, v := range ipamConf.Addresses {
 		result.IPs = append(result.IPs, &current.IPConfig{
 			Address: v.Address,
 			Gateway: v.Gateway})
@@ -126,7 +220,62 @@ func cmdDel(client *kubernetes.KubernetesIPAM) error {
 	ctx, cancel := context.WithTimeout(context.Background(), types.DelTimeLimit)
 	defer cancel()
 
-	_, _ = kubernetes.IPManagement(ctx, types.Deallocate, client.Config, client)
+	pendingReleases := journal.New(journal.Dir(client.Config))
+	if _, err := ipManagement(ctx, types.Deallocate, client); err != nil {
+		var invalidConfigErr *storage.InvalidConfigError
+		if errors.As(err, &invalidConfigErr) {
+			logging.Errorf("Error releasing IPs for ContainerID: %q - ifName: %q: %s", client.ContainerID, client.IfName, err)
+			return nil
+		}
+
+		logging.Errorf("Error releasing IPs for ContainerID: %q - ifName: %q, recording it as a pending release: %s", client.ContainerID, client.IfName, err)
+		entry := journal.NewEntry(client.ContainerID, client.IfName, client.Namespace, client.Config, err)
+		if err := pendingReleases.Record(entry); err != nil {
+			logging.Errorf("failed to record the pending release: %v", err)
+		}
+		return nil
+	}
+
+	if err := pendingReleases.Remove(client.ContainerID, client.IfName); err != nil {
+		logging.Errorf("failed to remove the pending release: %v", err)
+	}
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), pendingReleasesDrainTimeLimit)
+	defer drainCancel()
+	err := pendingReleases.DrainUpTo(drainCtx, pendingReleasesDrainLimit, releasePendingEntry)
+	if errors.Is(err, context.DeadlineExceeded) {
+		logging.Debugf("leaving the remaining pending releases to the control loop")
+	} else if err != nil {
+		logging.Errorf("failed to drain the pending releases: %v", err)
+	}
+	return nil
+}
+
+// cmdDelWithStore releases the IPs from a storage backend other than the Kubernetes one. The pending-release
+// journal is only drained against the Kubernetes datastore, so failures are reported to the runtime, which retries
+// the DEL.
+func cmdDelWithStore(store storage.Store, containerID, ifName string, ipamConf types.IPAMConfig) error {
+	ctx, cancel := context.WithTimeout(context.Background(), types.DelTimeLimit)
+	defer cancel()
 
+	if _, err := ipmanagement.Manage(ctx, types.Deallocate, store, ipamConf, containerID, ifName); err != nil {
+		var invalidConfigErr *storage.InvalidConfigError
+		if errors.As(err, &invalidConfigErr) {
+			logging.Errorf("Error releasing IPs for ContainerID: %q - ifName: %q: %s", containerID, ifName, err)
+			return nil
+		}
+		return storage.CNIError(fmt.Errorf("error at storage engine: %w", err))
+	}
 	return nil
 }
+
+// releasePendingEntry releases the IPs of a DEL which previously failed.
+func releasePendingEntry(ctx context.Context, entry journal.Entry) error {
+	ipam, err := kubernetes.NewKubernetesIPAMWithNamespace(entry.ContainerID, entry.IfName, entry.Config, entry.Namespace)
+	if err != nil {
+		return fmt.Errorf("IPAM client initialization error: %w", err)
+	}
+	defer func() { safeCloseKubernetesBackendConnection(ipam) }()
+
+	_, err = ipManagement(ctx, types.Deallocate, ipam)
+	return err
+}
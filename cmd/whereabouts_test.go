@@ -17,6 +17,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -35,16 +36,24 @@ import (
 	current "github.com/containernetworking/cni/pkg/types/100"
 	"github.com/containernetworking/plugins/pkg/testutils"
 
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
 	k8sclient "k8s.io/client-go/kubernetes"
 	fakek8sclient "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 
-	"github.com/k8snetworkplumbingwg/whereabouts/pkg/allocate"
 	"github.com/k8snetworkplumbingwg/whereabouts/pkg/api/whereabouts.cni.cncf.io/v1alpha1"
 	"github.com/k8snetworkplumbingwg/whereabouts/pkg/config"
 	wbclientset "github.com/k8snetworkplumbingwg/whereabouts/pkg/generated/clientset/versioned"
 	"github.com/k8snetworkplumbingwg/whereabouts/pkg/generated/clientset/versioned/fake"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/journal"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage"
 	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage/kubernetes"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage/local"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage/memory"
 	whereaboutstypes "github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
 )
 
@@ -590,6 +599,311 @@ var _ = Describe("Whereabouts operations", func() {
 		Expect(err).NotTo(HaveOccurred())
 	})
 
+	It("reserves static addresses that fall within the managed range", func() {
+		const (
+			ipRange       = "192.168.1.0/24"
+			staticAddress = "192.168.1.5/24"
+			otherPodName  = "otherPOD"
+		)
+		conf := fmt.Sprintf(`{
+      "cniVersion": "0.3.1",
+      "name": "mynet",
+      "type": "ipvlan",
+      "master": "foo0",
+      "ipam": {
+        "type": "whereabouts",
+        "kubernetes": {"kubeconfig": "%s"},
+        "range": %q
+      }
+    }`, kubeConfigPath, ipRange)
+		staticCNIArgs := func(podName string) string {
+			return fmt.Sprintf("%s;IP=%s", cniArgs(podNamespace, podName), staticAddress)
+		}
+
+		confPath := filepath.Join(tmpDir, "whereabouts.conf")
+		Expect(os.WriteFile(confPath, []byte(conf), 0755)).To(Succeed())
+		ipamConf, cniVersion, err := config.LoadIPAMConfig([]byte(conf), staticCNIArgs(podName), confPath)
+		Expect(err).NotTo(HaveOccurred())
+		otherIPAMConf, _, err := config.LoadIPAMConfig([]byte(conf), staticCNIArgs(otherPodName), confPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		wbClientSet := fake.NewSimpleClientset(ipPool(ipamConf.IPRanges[0].Range, podNamespace, ipamConf.NetworkName))
+		wbClient := *kubernetes.NewKubernetesClient(wbClientSet, fakek8sclient.NewSimpleClientset())
+		poolAllocations := func() map[string]v1alpha1.IPAllocation {
+			pool, err := wbClientSet.WhereaboutsV1alpha1().IPPools(podNamespace).Get(
+				context.TODO(),
+				kubernetes.IPPoolName(kubernetes.PoolIdentifier{IpRange: ipRange}),
+				metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			return pool.Spec.Allocations
+		}
+
+		args := &skel.CmdArgs{
+			ContainerID: "dummy",
+			Netns:       nspath,
+			IfName:      ifname,
+			StdinData:   []byte(conf),
+			Args:        staticCNIArgs(podName),
+		}
+		r, _, err := testutils.CmdAddWithArgs(args, func() error {
+			return cmdAdd(mutateK8sIPAM(args.ContainerID, ifname, ipamConf, wbClient), cniVersion)
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		result, err := current.GetResult(r)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.IPs).To(HaveLen(2))
+		Expect(result.IPs[0].Address).To(Equal(mustCIDR("192.168.1.1/24")))
+		Expect(result.IPs[1].Address).To(Equal(mustCIDR(staticAddress)))
+		Expect(poolAllocations()).To(HaveKey("1"))
+		Expect(poolAllocations()).To(HaveKey("5"))
+
+		otherArgs := &skel.CmdArgs{
+			ContainerID: "other",
+			Netns:       nspath,
+			IfName:      ifname,
+			StdinData:   []byte(conf),
+			Args:        staticCNIArgs(otherPodName),
+		}
+		_, _, err = testutils.CmdAddWithArgs(otherArgs, func() error {
+			return cmdAdd(mutateK8sIPAM(otherArgs.ContainerID, ifname, otherIPAMConf, wbClient), cniVersion)
+		})
+		Expect(err).To(HaveOccurred())
+		Expect(poolAllocations()).To(HaveLen(2))
+
+		err = testutils.CmdDelWithArgs(args, func() error {
+			return cmdDel(mutateK8sIPAM(args.ContainerID, ifname, ipamConf, wbClient))
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(poolAllocations()).To(BeEmpty())
+	})
+
+	It("reports an exhausted range with a whereabouts specific CNI error code", func() {
+		ipamConf := ipamConfig(podName, podNamespace, "", "192.168.1.0/30", "", kubeConfigPath)
+		Expect(ipamConf.IPRanges).NotTo(BeEmpty())
+
+		wbClient := *kubernetes.NewKubernetesClient(
+			fake.NewSimpleClientset(
+				ipPool(ipamConf.IPRanges[0].Range, podNamespace, "", []whereaboutstypes.IPReservation{
+					{PodRef: "dummyNS/pod1", IfName: ifname}, {PodRef: "dummyNS/pod2", IfName: ifname}}...)),
+			fakek8sclient.NewSimpleClientset())
+
+		cniConf, err := newCNINetConf("0.3.1", ipamConf)
+		Expect(err).NotTo(HaveOccurred())
+
+		args := &skel.CmdArgs{
+			ContainerID: "dummy",
+			Netns:       nspath,
+			IfName:      ifname,
+			StdinData:   cniConf,
+			Args:        cniArgs(podNamespace, podName),
+		}
+		_, _, err = testutils.CmdAddWithArgs(args, func() error {
+			return cmdAdd(mutateK8sIPAM(args.ContainerID, ifname, ipamConf, wbClient), "0.3.1")
+		})
+
+		var cniErr *types.Error
+		Expect(errors.As(err, &cniErr)).To(BeTrue())
+		Expect(cniErr.Code).To(Equal(whereaboutstypes.ErrRangeExhausted))
+		Expect(cniErr.Msg).To(ContainSubstring("total: 2 / reserved: 2 / excluded: 0"))
+	})
+
+	It("records a Warning event on the pod when the range is exhausted", func() {
+		ipamConf := ipamConfig(podName, podNamespace, "", "192.168.1.0/30", "", kubeConfigPath)
+		Expect(ipamConf.IPRanges).NotTo(BeEmpty())
+
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: podName, Namespace: podNamespace, UID: k8stypes.UID("pod-uid")}}
+		k8sClientSet := fakek8sclient.NewSimpleClientset(pod)
+		wbClient := *kubernetes.NewKubernetesClient(
+			fake.NewSimpleClientset(
+				ipPool(ipamConf.IPRanges[0].Range, podNamespace, "", []whereaboutstypes.IPReservation{
+					{PodRef: "dummyNS/pod1", IfName: ifname}, {PodRef: "dummyNS/pod2", IfName: ifname}}...)),
+			k8sClientSet)
+
+		cniConf, err := newCNINetConf("0.3.1", ipamConf)
+		Expect(err).NotTo(HaveOccurred())
+
+		args := &skel.CmdArgs{
+			ContainerID: "dummy",
+			Netns:       nspath,
+			IfName:      ifname,
+			StdinData:   cniConf,
+			Args:        cniArgs(podNamespace, podName),
+		}
+		_, _, err = testutils.CmdAddWithArgs(args, func() error {
+			return cmdAdd(mutateK8sIPAM(args.ContainerID, ifname, ipamConf, wbClient), "0.3.1")
+		})
+		Expect(err).To(HaveOccurred())
+
+		events, err := k8sClientSet.CoreV1().Events(podNamespace).List(context.TODO(), metav1.ListOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(events.Items).To(HaveLen(1))
+		event := events.Items[0]
+		Expect(event.Type).To(Equal(corev1.EventTypeWarning))
+		Expect(event.Reason).To(Equal("IPRangeExhausted"))
+		Expect(event.InvolvedObject.Kind).To(Equal("Pod"))
+		Expect(event.InvolvedObject.Name).To(Equal(podName))
+		Expect(event.InvolvedObject.UID).To(Equal(pod.UID))
+		Expect(event.Message).To(ContainSubstring(`on network "net1" from ranges [192.168.1.0/30]`))
+		Expect(event.Message).To(ContainSubstring("total: 2 / reserved: 2 / excluded: 0"))
+	})
+
+	It("records a failed release in the pending-release journal", func() {
+		ipamConf := ipamConfig(podName, podNamespace, "", "192.168.1.0/24", "", kubeConfigPath)
+		Expect(ipamConf).NotTo(BeNil())
+		ipamConf.ConfigurationPath = filepath.Join(tmpDir, "whereabouts.conf")
+
+		wbClientSet := fake.NewSimpleClientset(ipPool(ipamConf.IPRanges[0].Range, podNamespace, ""))
+		backendDown := false
+		wbClientSet.PrependReactor("list", "ippools", func(_ k8stesting.Action) (bool, runtime.Object, error) {
+			if backendDown {
+				return true, nil, apierrors.NewServiceUnavailable("the API server is down")
+			}
+			return false, nil, nil
+		})
+		wbClient := *kubernetes.NewKubernetesClient(wbClientSet, fakek8sclient.NewSimpleClientset())
+		poolAllocations := func() map[string]v1alpha1.IPAllocation {
+			pool, err := wbClientSet.WhereaboutsV1alpha1().IPPools(podNamespace).Get(
+				context.TODO(),
+				kubernetes.IPPoolName(kubernetes.PoolIdentifier{IpRange: ipamConf.IPRanges[0].Range}),
+				metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			return pool.Spec.Allocations
+		}
+		pendingReleases := journal.New(filepath.Join(tmpDir, "pending-releases"))
+
+		cniConf, err := newCNINetConf("0.3.1", ipamConf)
+		Expect(err).NotTo(HaveOccurred())
+		args := &skel.CmdArgs{
+			ContainerID: "dummy",
+			Netns:       nspath,
+			IfName:      ifname,
+			StdinData:   cniConf,
+			Args:        cniArgs(podNamespace, podName),
+		}
+		_, _, err = testutils.CmdAddWithArgs(args, func() error {
+			return cmdAdd(mutateK8sIPAM(args.ContainerID, ifname, ipamConf, wbClient), "0.3.1")
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(poolAllocations()).To(HaveLen(1))
+
+		backendDown = true
+		err = testutils.CmdDelWithArgs(args, func() error {
+			return cmdDel(mutateK8sIPAM(args.ContainerID, ifname, ipamConf, wbClient))
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(poolAllocations()).To(HaveLen(1))
+
+		entries, err := pendingReleases.List()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].ContainerID).To(Equal(args.ContainerID))
+		Expect(entries[0].IfName).To(Equal(ifname))
+		Expect(entries[0].Namespace).To(Equal(podNamespace))
+		Expect(entries[0].Config.GetPodRef()).To(Equal(ipamConf.GetPodRef()))
+		Expect(entries[0].LastError).To(ContainSubstring("the API server is down"))
+
+		backendDown = false
+		err = testutils.CmdDelWithArgs(args, func() error {
+			return cmdDel(mutateK8sIPAM(args.ContainerID, ifname, ipamConf, wbClient))
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(poolAllocations()).To(BeEmpty())
+		Expect(pendingReleases.List()).To(BeEmpty())
+	})
+
+	It("allocates and releases addresses from the local datastore selected by the flat configuration file", func() {
+		flatConfPath := filepath.Join(tmpDir, "whereabouts.conf")
+		Expect(os.WriteFile(flatConfPath, []byte(`{"datastore": "local"}`), 0600)).To(Succeed())
+		conf := fmt.Sprintf(`{
+			"cniVersion": "0.3.1",
+			"name": "mynet",
+			"type": "ipvlan",
+			"master": "foo0",
+			"ipam": {
+			  "type": "whereabouts",
+			  "configuration_path": "%s",
+			  "range": "192.168.1.0/24"
+			}
+		  }`, flatConfPath)
+		store := local.New(filepath.Join(tmpDir, "whereabouts-datastore.json"))
+		poolAllocations := func() []whereaboutstypes.IPReservation {
+			pool, err := store.GetIPPool(context.TODO(), storage.PoolIdentifier{IpRange: "192.168.1.0/24"})
+			Expect(err).NotTo(HaveOccurred())
+			return pool.Allocations()
+		}
+
+		for i, expectedIP := range []string{"192.168.1.1/24", "192.168.1.2/24"} {
+			args := &skel.CmdArgs{
+				ContainerID: fmt.Sprintf("dummy-%d", i),
+				Netns:       nspath,
+				IfName:      ifname,
+				StdinData:   []byte(conf),
+				Args:        cniArgs(podNamespace, fmt.Sprintf("%s-%d", podName, i)),
+			}
+			r, _, err := testutils.CmdAddWithArgs(args, func() error {
+				return cmdAddFunc(args)
+			})
+			Expect(err).NotTo(HaveOccurred())
+			result, err := current.GetResult(r)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.IPs[0].Address).To(Equal(mustCIDR(expectedIP)))
+		}
+		Expect(poolAllocations()).To(HaveLen(2))
+
+		args := &skel.CmdArgs{
+			ContainerID: "dummy-0",
+			Netns:       nspath,
+			IfName:      ifname,
+			StdinData:   []byte(conf),
+			Args:        cniArgs(podNamespace, podName+"-0"),
+		}
+		err := testutils.CmdDelWithArgs(args, func() error {
+			return cmdDelFunc(args)
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(poolAllocations()).To(ConsistOf(HaveField("ContainerID", "dummy-1")))
+	})
+
+	It("retries the conflicting pool updates, and reports an unavailable backend, of the other datastores", func() {
+		ipamConf := ipamConfig(podName, podNamespace, "", "192.168.1.0/24", "", kubeConfigPath)
+		store := memory.New()
+		store.InjectFault(memory.OpUpdatePool, memory.FaultConflict, 2)
+		cniConf, err := newCNINetConf("0.3.1", ipamConf)
+		Expect(err).NotTo(HaveOccurred())
+
+		args := &skel.CmdArgs{
+			ContainerID: "dummy",
+			Netns:       nspath,
+			IfName:      ifname,
+			StdinData:   cniConf,
+			Args:        cniArgs(podNamespace, podName),
+		}
+		r, _, err := testutils.CmdAddWithArgs(args, func() error {
+			return cmdAddWithStore(store, args.ContainerID, args.IfName, *ipamConf, "0.3.1")
+		})
+		Expect(err).NotTo(HaveOccurred())
+		result, err := current.GetResult(r)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.IPs[0].Address).To(Equal(mustCIDR("192.168.1.1/24")))
+		Expect(store.Calls(memory.OpUpdatePool)).To(Equal(3))
+
+		store.InjectFault(memory.OpStatus, memory.FaultTimeout, 1)
+		err = testutils.CmdDelWithArgs(args, func() error {
+			return cmdDelWithStore(store, args.ContainerID, args.IfName, *ipamConf)
+		})
+		var cniErr *types.Error
+		Expect(errors.As(err, &cniErr)).To(BeTrue())
+		Expect(cniErr.Code).To(BeEquivalentTo(types.ErrTryAgainLater))
+		Expect(store.Allocations(storage.PoolIdentifier{IpRange: "192.168.1.0/24"})).To(HaveLen(1))
+
+		err = testutils.CmdDelWithArgs(args, func() error {
+			return cmdDelWithStore(store, args.ContainerID, args.IfName, *ipamConf)
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(store.Allocations(storage.PoolIdentifier{IpRange: "192.168.1.0/24"})).To(BeEmpty())
+	})
+
 	It("allocates an address using IPRanges notation", func() {
 		backend := fmt.Sprintf(`"kubernetes": {"kubeconfig": "%s"}`, kubeConfigPath)
 		conf := fmt.Sprintf(`{
@@ -995,12 +1309,10 @@ var _ = Describe("Whereabouts operations", func() {
 		})
 		Expect(err).To(HaveOccurred())
 
-		// ensure the error is of the correct type
-		switch e := errors.Unwrap(err); e.(type) {
-		case allocate.AssignmentError:
-		default:
-			Fail(fmt.Sprintf("expected AssignmentError, got: %s", e))
-		}
+		// ensure the error is reported as an exhausted range
+		var cniErr *types.Error
+		Expect(errors.As(err, &cniErr)).To(BeTrue())
+		Expect(cniErr.Code).To(Equal(whereaboutstypes.ErrRangeExhausted))
 	})
 
 	It("detects IPv4 addresses used in other ranges, to allow for overlapping IP address ranges", func() {
@@ -21,8 +21,6 @@ package client
 
 import (
 	"context"
-	"errors"
-	"fmt"
 	"time"
 
 	kubeClient "github.com/k8snetworkplumbingwg/whereabouts/pkg/storage/kubernetes"
@@ -33,10 +31,7 @@ import (
 func isIPPoolAllocationsEmpty(ctx context.Context, k8sIPAM *kubeClient.KubernetesIPAM, ipPoolCIDR string) wait.ConditionWithContextFunc {
 	return func(context.Context) (bool, error) {
 		ipPool, err := k8sIPAM.GetIPPool(ctx, kubeClient.PoolIdentifier{IpRange: ipPoolCIDR, NetworkName: kubeClient.UnnamedNetwork})
-		noPoolError := fmt.Errorf("k8s pool initialized")
-		if errors.Is(err, noPoolError) {
-			return true, nil
-		} else if err != nil {
+		if err != nil {
 			return false, err
 		}
 
@@ -57,11 +52,7 @@ func isIPPoolAllocationsEmptyForNodeSlices(ctx context.Context, k8sIPAM *kubeCli
 		for _, node := range nodes.Items {
 			ipPool, err := k8sIPAM.GetIPPool(ctx, kubeClient.PoolIdentifier{NodeName: node.Name, IpRange: ipPoolCIDR, NetworkName: k8sIPAM.Config.NetworkName})
 			if err != nil {
-				if err.Error() == "k8s pool initialized" {
-					continue
-				} else {
-					return false, err
-				}
+				return false, err
 			}
 
 			if len(ipPool.Allocations()) != 0 {
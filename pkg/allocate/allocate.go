@@ -18,35 +18,31 @@ package allocate
 
 import (
 	"fmt"
+	"math"
 	"net"
+	"sort"
 
 	"github.com/k8snetworkplumbingwg/whereabouts/pkg/iphelpers"
 	"github.com/k8snetworkplumbingwg/whereabouts/pkg/logging"
 	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
 )
 
-// AssignmentError defines an IP assignment error.
-type AssignmentError struct {
-	firstIP       net.IP
-	lastIP        net.IP
-	ipnet         net.IPNet
-	excludeRanges []string
-}
-
-func (a AssignmentError) Error() string {
-	return fmt.Sprintf("Could not allocate IP in range: ip: %v / - %v / range: %s / excludeRanges: %v",
-		a.firstIP, a.lastIP, a.ipnet.String(), a.excludeRanges)
-}
-
 // AssignIP assigns an IP using a range and a reserve list.
 func AssignIP(ipamConf types.RangeConfiguration, reservelist []types.IPReservation, containerID, podRef, ifName string) (net.IPNet, []types.IPReservation, error) {
+	return AssignIPFromOffset(ipamConf, reservelist, containerID, podRef, ifName, 0)
+}
+
+// AssignIPFromOffset assigns an IP like AssignIP, but looks for a free IP starting at the given offset of the range
+// (modulo its size), wrapping around its end. Concurrent allocators starting at different offsets rarely pick the
+// same IP.
+func AssignIPFromOffset(ipamConf types.RangeConfiguration, reservelist []types.IPReservation, containerID, podRef, ifName string, offset uint64) (net.IPNet, []types.IPReservation, error) {
 
 	// Setup the basics here.
 	_, ipnet, _ := net.ParseCIDR(ipamConf.Range)
 
 	// Verify if podRef and ifName have already an allocation.
 	for i, r := range reservelist {
-		if r.PodRef == podRef && r.IfName == ifName {
+		if r.PodRef == podRef && r.IfName == ifName && !r.IsStatic {
 			logging.Debugf("IP already allocated for podRef: %q - ifName:%q - IP: %s", podRef, ifName, r.IP.String())
 			if r.ContainerID != containerID {
 				logging.Debugf("updating container ID: %q", containerID)
@@ -57,7 +53,7 @@ func AssignIP(ipamConf types.RangeConfiguration, reservelist []types.IPReservati
 		}
 	}
 
-	newip, updatedreservelist, err := IterateForAssignment(*ipnet, ipamConf.RangeStart, ipamConf.RangeEnd, reservelist, ipamConf.OmitRanges, containerID, podRef, ifName)
+	newip, updatedreservelist, err := iterateForAssignment(*ipnet, ipamConf.RangeStart, ipamConf.RangeEnd, reservelist, ipamConf.OmitRanges, containerID, podRef, ifName, offset)
 	if err != nil {
 		return net.IPNet{}, nil, err
 	}
@@ -79,6 +75,89 @@ func DeallocateIP(reservelist []types.IPReservation, containerID, ifName string)
 	return removeIdxFromSlice(reservelist, index), ip
 }
 
+// StaticIPsInRange returns the IPs of the given static addresses which fall within the allocatable boundaries of the
+// range, i.e. the ones that must be reserved in the range's pool so they are not handed out dynamically. The IPs of
+// the exclude list are left out, as whereabouts does not manage them.
+func StaticIPsInRange(ipamConf types.RangeConfiguration, addresses []types.Address) []net.IP {
+	_, ipnet, err := net.ParseCIDR(ipamConf.Range)
+	if err != nil {
+		return nil
+	}
+	firstIP, lastIP, err := iphelpers.GetIPRange(*ipnet, ipamConf.RangeStart, ipamConf.RangeEnd)
+	if err != nil {
+		return nil
+	}
+
+	var excluded []*net.IPNet
+	for _, omitRange := range ipamConf.OmitRanges {
+		if subnet, err := parseExcludedRange(omitRange); err == nil {
+			excluded = append(excluded, subnet)
+		}
+	}
+
+	var staticIPs []net.IP
+	for _, address := range addresses {
+		ip := address.Address.IP
+		if !ipnet.Contains(ip) || iphelpers.CompareIPs(ip, firstIP) < 0 || iphelpers.CompareIPs(ip, lastIP) > 0 {
+			continue
+		}
+		if skipExcludedSubnets(ip, excluded) != nil {
+			logging.Debugf("Not reserving static IP %v, which is excluded from the range", ip)
+			continue
+		}
+		staticIPs = append(staticIPs, ip)
+	}
+	return staticIPs
+}
+
+// ReserveStaticIPs adds reservations for the given static IPs to the reserve list. Reservations already held by the
+// same podRef and ifName are kept (and their container ID refreshed); a static IP reserved by anybody else results in
+// a ConflictError.
+func ReserveStaticIPs(reservelist []types.IPReservation, staticIPs []net.IP, containerID, podRef, ifName string) ([]types.IPReservation, error) {
+	for _, ip := range staticIPs {
+		index := getIPReservationIndex(reservelist, ip)
+		if index < 0 {
+			logging.Debugf("Reserving static IP: %q - container ID %q - podRef: %q - ifName: %q", ip.String(), containerID, podRef, ifName)
+			reservelist = append(reservelist, types.IPReservation{IP: ip, ContainerID: containerID, PodRef: podRef, IfName: ifName, IsStatic: true})
+			continue
+		}
+
+		r := reservelist[index]
+		if r.PodRef != podRef || r.IfName != ifName {
+			return reservelist, ConflictError{IP: ip, PodRef: r.PodRef, IfName: r.IfName}
+		}
+		logging.Debugf("Static IP already reserved for podRef: %q - ifName: %q - IP: %s", podRef, ifName, ip.String())
+		reservelist[index].ContainerID = containerID
+		reservelist[index].IsStatic = true
+	}
+	return reservelist, nil
+}
+
+// ReleaseStaticIPs removes the reservations of the given static IPs held by containerID and ifName from the reserve
+// list. Returns the updated reserve list and the released IPs.
+func ReleaseStaticIPs(reservelist []types.IPReservation, staticIPs []net.IP, containerID, ifName string) ([]types.IPReservation, []net.IP) {
+	var released []net.IP
+	for _, ip := range staticIPs {
+		index := getIPReservationIndex(reservelist, ip)
+		if index < 0 || reservelist[index].ContainerID != containerID || reservelist[index].IfName != ifName {
+			continue
+		}
+		logging.Debugf("Releasing static IP: %v", ip.String())
+		reservelist = removeIdxFromSlice(reservelist, index)
+		released = append(released, ip)
+	}
+	return reservelist, released
+}
+
+func getIPReservationIndex(reservelist []types.IPReservation, ip net.IP) int {
+	for idx, v := range reservelist {
+		if v.IP.Equal(ip) {
+			return idx
+		}
+	}
+	return -1
+}
+
 func getMatchingIPReservationIndex(reservelist []types.IPReservation, id, ifName string) int {
 	for idx, v := range reservelist {
 		if v.ContainerID == id && v.IfName == ifName {
@@ -100,6 +179,10 @@ func removeIdxFromSlice(s []types.IPReservation, i int) []types.IPReservation {
 // reserveList holds a list of reserved IPs.
 // excludeRanges holds a list of subnets to be excluded (meaning the full subnet, including the network and broadcast IP).
 func IterateForAssignment(ipnet net.IPNet, rangeStart net.IP, rangeEnd net.IP, reserveList []types.IPReservation, excludeRanges []string, containerID, podRef, ifName string) (net.IP, []types.IPReservation, error) {
+	return iterateForAssignment(ipnet, rangeStart, rangeEnd, reserveList, excludeRanges, containerID, podRef, ifName, 0)
+}
+
+func iterateForAssignment(ipnet net.IPNet, rangeStart net.IP, rangeEnd net.IP, reserveList []types.IPReservation, excludeRanges []string, containerID, podRef, ifName string, offset uint64) (net.IP, []types.IPReservation, error) {
 	// Get the valid range, delimited by the ipnet's first and last usable IP as well as the rangeStart and rangeEnd.
 	firstIP, lastIP, err := iphelpers.GetIPRange(ipnet, rangeStart, rangeEnd)
 	if err != nil {
@@ -125,6 +208,37 @@ func IterateForAssignment(ipnet net.IPNet, rangeStart net.IP, rangeEnd net.IP, r
 		excluded = append(excluded, subnet)
 	}
 
+	// Start the iteration at the given offset of the range, then wrap around to its first IP.
+	startIP := firstIP
+	if offset > 0 {
+		if size, err := iphelpers.IPGetOffset(lastIP, firstIP); err == nil && size < math.MaxUint64 {
+			startIP = iphelpers.IPAddOffset(firstIP, offset%(size+1))
+		}
+	}
+	if ip := findFreeIP(ipnet, startIP, lastIP, reserved, excluded); ip != nil {
+		return reserveIP(ip, reserveList, containerID, podRef, ifName)
+	}
+	if iphelpers.CompareIPs(startIP, firstIP) > 0 {
+		if ip := findFreeIP(ipnet, firstIP, iphelpers.DecIP(startIP), reserved, excluded); ip != nil {
+			return reserveIP(ip, reserveList, containerID, podRef, ifName)
+		}
+	}
+
+	// No IP address for assignment found, return an error.
+	total, reservedCount, excludedCount := rangeCapacity(firstIP, lastIP, reserveList, excluded)
+	return net.IP{}, reserveList, AssignmentError{
+		firstIP:       firstIP,
+		lastIP:        lastIP,
+		ipnet:         ipnet,
+		excludeRanges: excludeRanges,
+		Total:         total,
+		Reserved:      reservedCount,
+		Excluded:      excludedCount,
+	}
+}
+
+// findFreeIP returns the first IP between firstIP and lastIP which is neither reserved nor excluded, or nil.
+func findFreeIP(ipnet net.IPNet, firstIP, lastIP net.IP, reserved map[string]bool, excluded []*net.IPNet) net.IP {
 	// Iterate over every IP address in the range, accounting for reserved IPs and exclude ranges. Make sure that ip is
 	// within ipnet, and make sure that ip is smaller than lastIP.
 	for ip := firstIP; ipnet.Contains(ip) && iphelpers.CompareIPs(ip, lastIP) <= 0; ip = iphelpers.IncIP(ip) {
@@ -138,14 +252,74 @@ func IterateForAssignment(ipnet net.IPNet, rangeStart net.IP, rangeEnd net.IP, r
 			ip = skipTo
 			continue
 		}
-		// Assign and reserve the IP and return.
-		logging.Debugf("Reserving IP: %q - container ID %q - podRef: %q - ifName: %q", ip.String(), containerID, podRef, ifName)
-		reserveList = append(reserveList, types.IPReservation{IP: ip, ContainerID: containerID, PodRef: podRef, IfName: ifName})
-		return ip, reserveList, nil
+		return ip
 	}
+	return nil
+}
 
-	// No IP address for assignment found, return an error.
-	return net.IP{}, reserveList, AssignmentError{firstIP, lastIP, ipnet, excludeRanges}
+func reserveIP(ip net.IP, reserveList []types.IPReservation, containerID, podRef, ifName string) (net.IP, []types.IPReservation, error) {
+	logging.Debugf("Reserving IP: %q - container ID %q - podRef: %q - ifName: %q", ip.String(), containerID, podRef, ifName)
+	reserveList = append(reserveList, types.IPReservation{IP: ip, ContainerID: containerID, PodRef: podRef, IfName: ifName})
+	return ip, reserveList, nil
+}
+
+// rangeCapacity returns the number of IPs between firstIP and lastIP, as well as how many of them are reserved and
+// how many are excluded.
+func rangeCapacity(firstIP, lastIP net.IP, reserveList []types.IPReservation, excluded []*net.IPNet) (uint64, uint64, uint64) {
+	offset, err := iphelpers.IPGetOffset(lastIP, firstIP)
+	if err != nil {
+		return 0, 0, 0
+	}
+	total := offset + 1
+
+	reserved := make(map[string]bool)
+	for _, r := range reserveList {
+		if iphelpers.CompareIPs(r.IP, firstIP) >= 0 && iphelpers.CompareIPs(r.IP, lastIP) <= 0 {
+			reserved[r.IP.String()] = true
+		}
+	}
+
+	// Clamp every excluded subnet to the range, and merge the resulting (possibly overlapping) intervals.
+	type interval struct{ start, end uint64 }
+	var intervals []interval
+	for _, subnet := range excluded {
+		start, end := iphelpers.NetworkIP(*subnet), iphelpers.SubnetBroadcastIP(*subnet)
+		if iphelpers.CompareIPs(end, firstIP) < 0 || iphelpers.CompareIPs(start, lastIP) > 0 {
+			continue
+		}
+		if iphelpers.CompareIPs(start, firstIP) < 0 {
+			start = firstIP
+		}
+		if iphelpers.CompareIPs(end, lastIP) > 0 {
+			end = lastIP
+		}
+		startOffset, err := iphelpers.IPGetOffset(start, firstIP)
+		if err != nil {
+			continue
+		}
+		endOffset, err := iphelpers.IPGetOffset(end, firstIP)
+		if err != nil {
+			continue
+		}
+		intervals = append(intervals, interval{startOffset, endOffset})
+	}
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].start < intervals[j].start })
+
+	var merged []interval
+	for _, i := range intervals {
+		if len(merged) > 0 && i.start <= merged[len(merged)-1].end+1 {
+			if i.end > merged[len(merged)-1].end {
+				merged[len(merged)-1].end = i.end
+			}
+			continue
+		}
+		merged = append(merged, i)
+	}
+	var excludedCount uint64
+	for _, i := range merged {
+		excludedCount += i.end - i.start + 1
+	}
+	return total, uint64(len(reserved)), excludedCount
 }
 
 // skipExcludedSubnets iterates through all subnets and checks if ip is part of them. If i is part of one of the subnets,
@@ -17,6 +17,7 @@
 package allocate
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"testing"
@@ -357,6 +358,29 @@ var _ = Describe("Allocation operations", func() {
 		Expect(err).To(MatchError(HavePrefix("Could not allocate IP in range")))
 	})
 
+	It("reports the capacity of an exhausted range", func() {
+		_, ipnet, err := net.ParseCIDR("192.168.0.0/28")
+		Expect(err).NotTo(HaveOccurred())
+		startip := net.ParseIP("192.168.0.1")
+		lastip := net.ParseIP("192.168.0.6")
+
+		ipres := []types.IPReservation{
+			{IP: net.ParseIP("192.168.0.1"), PodRef: "default/pod1"},
+			{IP: net.ParseIP("192.168.0.2"), PodRef: "default/pod2"},
+			{IP: net.ParseIP("192.168.0.3"), PodRef: "default/pod3"},
+			{IP: net.ParseIP("192.168.0.9"), PodRef: "default/pod4"},
+		}
+		exrange := []string{"192.168.0.4/30", "192.168.0.5"}
+		_, _, err = IterateForAssignment(*ipnet, startip, lastip, ipres, exrange, "0xdeadbeef", "", "")
+
+		var assignmentErr AssignmentError
+		Expect(errors.As(err, &assignmentErr)).To(BeTrue())
+		Expect(assignmentErr.Total).To(BeEquivalentTo(6))
+		Expect(assignmentErr.Reserved).To(BeEquivalentTo(3))
+		Expect(assignmentErr.Excluded).To(BeEquivalentTo(3))
+		Expect(assignmentErr.CNIErrorCode()).To(Equal(types.ErrRangeExhausted))
+	})
+
 	Context("test reserve lists", func() {
 		When("an empty reserve list is provided", func() {
 			It("is properly updated", func() {
@@ -431,4 +455,128 @@ var _ = Describe("Allocation operations", func() {
 			})
 		})
 	})
+
+	Context("assigning from an offset", func() {
+		rangeConf := types.RangeConfiguration{Range: "192.168.0.0/29"}
+
+		It("starts looking for a free IP at the offset", func() {
+			newip, _, err := AssignIPFromOffset(rangeConf, nil, "0xdeadbeef", "default/pod1", "eth0", 3)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fmt.Sprint(newip.IP)).To(Equal("192.168.0.4"))
+		})
+
+		It("takes the offset modulo the size of the range", func() {
+			newip, _, err := AssignIPFromOffset(rangeConf, nil, "0xdeadbeef", "default/pod1", "eth0", 6+3)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fmt.Sprint(newip.IP)).To(Equal("192.168.0.4"))
+		})
+
+		It("wraps around the end of the range", func() {
+			ipres := []types.IPReservation{
+				{IP: net.ParseIP("192.168.0.5"), PodRef: "default/pod2"},
+				{IP: net.ParseIP("192.168.0.6"), PodRef: "default/pod3"},
+			}
+			newip, _, err := AssignIPFromOffset(rangeConf, ipres, "0xdeadbeef", "default/pod1", "eth0", 4)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fmt.Sprint(newip.IP)).To(Equal("192.168.0.1"))
+		})
+
+		It("reports an exhausted range", func() {
+			var ipres []types.IPReservation
+			for i := 1; i <= 6; i++ {
+				ipres = append(ipres, types.IPReservation{IP: net.ParseIP(fmt.Sprintf("192.168.0.%d", i)), PodRef: "default/pod2"})
+			}
+			_, _, err := AssignIPFromOffset(rangeConf, ipres, "0xdeadbeef", "default/pod1", "eth0", 4)
+			Expect(err).To(BeAssignableToTypeOf(AssignmentError{}))
+		})
+	})
+
+	Context("static addresses", func() {
+		staticAddress := func(cidr string) types.Address {
+			ip, ipnet, err := net.ParseCIDR(cidr)
+			Expect(err).NotTo(HaveOccurred())
+			return types.Address{Address: net.IPNet{IP: ip, Mask: ipnet.Mask}}
+		}
+
+		It("only returns the static IPs within the allocatable range", func() {
+			rangeConf := types.RangeConfiguration{
+				Range:      "192.168.0.0/28",
+				RangeStart: net.ParseIP("192.168.0.4"),
+				RangeEnd:   net.ParseIP("192.168.0.10"),
+			}
+			addresses := []types.Address{
+				staticAddress("192.168.0.5/28"),
+				staticAddress("192.168.0.2/28"),
+				staticAddress("10.10.0.1/24"),
+			}
+
+			staticIPs := StaticIPsInRange(rangeConf, addresses)
+			Expect(staticIPs).To(HaveLen(1))
+			Expect(fmt.Sprint(staticIPs[0])).To(Equal("192.168.0.5"))
+		})
+
+		It("leaves out the static IPs of the exclude list", func() {
+			rangeConf := types.RangeConfiguration{
+				Range:      "192.168.0.0/28",
+				OmitRanges: []string{"192.168.0.4/30", "192.168.0.9"},
+			}
+			addresses := []types.Address{
+				staticAddress("192.168.0.5/28"),
+				staticAddress("192.168.0.8/28"),
+				staticAddress("192.168.0.9/28"),
+			}
+
+			staticIPs := StaticIPsInRange(rangeConf, addresses)
+			Expect(staticIPs).To(HaveLen(1))
+			Expect(fmt.Sprint(staticIPs[0])).To(Equal("192.168.0.8"))
+		})
+
+		It("reserves static IPs and keeps them away from the dynamic assignment", func() {
+			rangeConf := types.RangeConfiguration{Range: "192.168.0.0/28", RangeStart: net.ParseIP("192.168.0.1")}
+			staticIPs := []net.IP{net.ParseIP("192.168.0.1")}
+
+			ipres, err := ReserveStaticIPs(nil, staticIPs, "0xdeadbeef", "default/pod1", "eth0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ipres).To(HaveLen(1))
+
+			newip, ipres, err := AssignIP(rangeConf, ipres, "0xdeadbeef", "default/pod1", "eth0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fmt.Sprint(newip.IP)).To(Equal("192.168.0.2"))
+			Expect(ipres).To(HaveLen(2))
+
+			// a subsequent ADD for the same pod is idempotent
+			ipres, err = ReserveStaticIPs(ipres, staticIPs, "0xdeadbeef", "default/pod1", "eth0")
+			Expect(err).NotTo(HaveOccurred())
+			newip, ipres, err = AssignIP(rangeConf, ipres, "0xdeadbeef", "default/pod1", "eth0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fmt.Sprint(newip.IP)).To(Equal("192.168.0.2"))
+			Expect(ipres).To(HaveLen(2))
+		})
+
+		It("fails to reserve a static IP held by another pod", func() {
+			ipres := []types.IPReservation{
+				{IP: net.ParseIP("192.168.0.1"), PodRef: "default/pod1", IfName: "eth0"},
+			}
+
+			_, err := ReserveStaticIPs(ipres, []net.IP{net.ParseIP("192.168.0.1")}, "0xdeadbeef", "default/pod2", "eth0")
+			Expect(err).To(BeAssignableToTypeOf(ConflictError{}))
+		})
+
+		It("releases only the static IPs owned by the container", func() {
+			ipres := []types.IPReservation{
+				{IP: net.ParseIP("192.168.0.1"), ContainerID: "0xdeadbeef", PodRef: "default/pod1", IfName: "eth0"},
+				{IP: net.ParseIP("192.168.0.2"), ContainerID: "0xdeadbeef", PodRef: "default/pod1", IfName: "eth0"},
+				{IP: net.ParseIP("192.168.0.3"), ContainerID: "0xcafe", PodRef: "default/pod2", IfName: "eth0"},
+			}
+
+			ipres, released := ReleaseStaticIPs(ipres, []net.IP{net.ParseIP("192.168.0.1"), net.ParseIP("192.168.0.3")}, "0xdeadbeef", "eth0")
+			Expect(released).To(HaveLen(1))
+			Expect(fmt.Sprint(released[0])).To(Equal("192.168.0.1"))
+			Expect(ipres).To(HaveLen(2))
+
+			ipres, ip := DeallocateIP(ipres, "0xdeadbeef", "eth0")
+			Expect(fmt.Sprint(ip)).To(Equal("192.168.0.2"))
+			Expect(ipres).To(HaveLen(1))
+		})
+	})
 })
@@ -0,0 +1,65 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package allocate
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+// AssignmentError defines an IP assignment error: the range is exhausted.
+type AssignmentError struct {
+	firstIP       net.IP
+	lastIP        net.IP
+	ipnet         net.IPNet
+	excludeRanges []string
+
+	// Total is the number of IPs between the first and last IP of the range.
+	Total uint64
+	// Reserved is the number of IPs of the range already reserved.
+	Reserved uint64
+	// Excluded is the number of IPs of the range covered by the exclude list.
+	Excluded uint64
+}
+
+func (a AssignmentError) Error() string {
+	return fmt.Sprintf("Could not allocate IP in range: ip: %v / - %v / range: %s / excludeRanges: %v / total: %d / reserved: %d / excluded: %d",
+		a.firstIP, a.lastIP, a.ipnet.String(), a.excludeRanges, a.Total, a.Reserved, a.Excluded)
+}
+
+// CNIErrorCode returns the CNI error code of an exhausted range.
+func (a AssignmentError) CNIErrorCode() uint {
+	return types.ErrRangeExhausted
+}
+
+// ConflictError defines a static IP that is already reserved by another pod / interface.
+type ConflictError struct {
+	IP     net.IP
+	PodRef string
+	IfName string
+}
+
+func (c ConflictError) Error() string {
+	return fmt.Sprintf("static IP %s is already reserved for podRef: %q - ifName: %q", c.IP, c.PodRef, c.IfName)
+}
+
+// CNIErrorCode returns the CNI error code of an IP conflict.
+func (c ConflictError) CNIErrorCode() uint {
+	return types.ErrIPConflict
+}
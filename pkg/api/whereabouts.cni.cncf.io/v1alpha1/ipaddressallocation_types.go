@@ -0,0 +1,57 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+// IPAddressAllocationSpec defines the desired state of IPAddressAllocation
+type IPAddressAllocationSpec struct {
+	// IP is the allocated address
+	IP string `json:"ip"`
+	// Range is the range of the pool the IP is allocated from, in CIDR notation
+	Range       string `json:"range"`
+	ContainerID string `json:"containerid,omitempty"`
+	PodRef      string `json:"podref"`
+	IfName      string `json:"ifname,omitempty"`
+	// IsStatic marks the allocation of a statically requested IP
+	IsStatic bool `json:"isStatic,omitempty"`
+}
+
+// +genclient
+// +kubebuilder:object:root=true
+// +kubebuilder:printcolumn:name="IP",type=string,JSONPath=`.spec.ip`
+// +kubebuilder:printcolumn:name="Range",type=string,JSONPath=`.spec.range`
+// +kubebuilder:printcolumn:name="Pod",type=string,JSONPath=`.spec.podref`
+
+// IPAddressAllocation is the Schema for the IPAddressAllocations API: a single IP allocated from a pool, for the
+// pools kept one object per allocated IP rather than as an IPPool.
+type IPAddressAllocation struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec IPAddressAllocationSpec `json:"spec"`
+}
+
+// +kubebuilder:object:root=true
+
+// IPAddressAllocationList contains a list of IPAddressAllocation
+type IPAddressAllocationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []IPAddressAllocation `json:"items"`
+}
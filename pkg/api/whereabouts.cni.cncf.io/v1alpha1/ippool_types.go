@@ -29,6 +29,24 @@ type IPPoolSpec struct {
 	// Allocations is the set of allocated IPs for the given range. Its` indices are a direct mapping to the
 	// IP with the same index/offset for the pool's range.
 	Allocations map[string]IPAllocation `json:"allocations"`
+	// ShardSize is set on the pools split into shards: it is the number of offsets of the range held by each shard.
+	// The allocations of such a pool are held by its shards, rather than by the pool itself.
+	// +optional
+	ShardSize int64 `json:"shardSize,omitempty"`
+	// Shard is set on the shards of a pool, which hold the allocations of a slice of the offsets of its range; the
+	// Range of a shard is the range of the pool, so that its allocations are indexed by the same offsets.
+	// +optional
+	Shard *IPPoolShard `json:"shard,omitempty"`
+}
+
+// IPPoolShard locates a shard in the range of the pool it belongs to
+type IPPoolShard struct {
+	// Parent is the name of the pool the shard belongs to, in the same namespace
+	Parent string `json:"parent"`
+	// Start is the first offset held by the shard
+	Start int64 `json:"start"`
+	// End is the offset following the last offset held by the shard
+	End int64 `json:"end"`
 }
 
 // ParseCIDR formats the Range of the IPPool
@@ -41,10 +59,47 @@ type IPAllocation struct {
 	ContainerID string `json:"id"`
 	PodRef      string `json:"podref"`
 	IfName      string `json:"ifname,omitempty"`
+	// IsStatic marks the allocation of a statically requested IP
+	IsStatic bool `json:"isStatic,omitempty"`
+}
+
+// IPPoolStatus defines the observed utilization of IPPool
+type IPPoolStatus struct {
+	// Total is the number of usable IPs of the range, between its range_start and range_end when configured
+	Total int64 `json:"total"`
+	// Allocated is the number of allocated IPs, in the pool or in its shards
+	Allocated int64 `json:"allocated"`
+	// Excluded is the number of usable IPs of the range excluded from the allocations by the configuration
+	Excluded int64 `json:"excluded"`
+	// Free is the number of usable IPs which are neither allocated nor excluded
+	Free int64 `json:"free"`
+	// LastUpdated is when the counts were last changed
+	// +optional
+	LastUpdated metav1.Time `json:"lastUpdated,omitempty"`
+	// Conditions hold the Exhausted and NearlyExhausted conditions of the pool
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
+// The conditions of IPPoolStatus.
+const (
+	// IPPoolExhausted is true when no IP is free in the pool
+	IPPoolExhausted = "Exhausted"
+	// IPPoolNearlyExhausted is true when few IPs are free in the pool
+	IPPoolNearlyExhausted = "NearlyExhausted"
+)
+
 // +genclient
 // +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Range",type=string,JSONPath=`.spec.range`
+// +kubebuilder:printcolumn:name="Total",type=integer,JSONPath=`.status.total`
+// +kubebuilder:printcolumn:name="Allocated",type=integer,JSONPath=`.status.allocated`
+// +kubebuilder:printcolumn:name="Free",type=integer,JSONPath=`.status.free`
+// +kubebuilder:printcolumn:name="Exhausted",type=string,JSONPath=`.status.conditions[?(@.type=="Exhausted")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // IPPool is the Schema for the ippools API
 type IPPool struct {
@@ -52,6 +107,8 @@ type IPPool struct {
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
 	Spec IPPoolSpec `json:"spec,omitempty"`
+	// +optional
+	Status IPPoolStatus `json:"status,omitempty"`
 }
 
 // +kubebuilder:object:root=true
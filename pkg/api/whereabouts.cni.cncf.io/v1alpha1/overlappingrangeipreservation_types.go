@@ -20,6 +20,10 @@ import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 // OverlappingRangeIPReservationSpec defines the desired state of OverlappingRangeIPReservation
 type OverlappingRangeIPReservationSpec struct {
+	// IP is the reserved address
+	IP string `json:"ip,omitempty"`
+	// NetworkName is the name of the network the address is reserved in, empty for the unnamed network
+	NetworkName string `json:"networkname,omitempty"`
 	ContainerID string `json:"containerid,omitempty"`
 	PodRef      string `json:"podref"`
 	IfName      string `json:"ifname,omitempty"`
@@ -27,6 +31,9 @@ type OverlappingRangeIPReservationSpec struct {
 
 // +genclient
 // +kubebuilder:object:root=true
+// +kubebuilder:printcolumn:name="IP",type=string,JSONPath=`.spec.ip`
+// +kubebuilder:printcolumn:name="Network",type=string,JSONPath=`.spec.networkname`
+// +kubebuilder:printcolumn:name="Pod",type=string,JSONPath=`.spec.podref`
 
 // OverlappingRangeIPReservation is the Schema for the OverlappingRangeIPReservations API
 type OverlappingRangeIPReservation struct {
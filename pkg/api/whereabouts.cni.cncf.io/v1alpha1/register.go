@@ -60,6 +60,8 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		&OverlappingRangeIPReservationList{},
 		&NodeSlicePool{},
 		&NodeSlicePoolList{},
+		&IPAddressAllocation{},
+		&IPAddressAllocationList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
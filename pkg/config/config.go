@@ -68,6 +68,7 @@ func LoadIPAMConfig(bytes []byte, envArgs string, extraConfigPaths ...string) (*
 	}
 	n.IPAM.PodName = string(args.K8S_POD_NAME)
 	n.IPAM.PodNamespace = string(args.K8S_POD_NAMESPACE)
+	n.IPAM.PodUID = string(args.K8S_POD_UID)
 
 	flatipam, foundflatfile, err := GetFlatIPAM(false, n.IPAM, extraConfigPaths...)
 	if err != nil {
@@ -141,8 +142,19 @@ func LoadIPAMConfig(bytes []byte, envArgs string, extraConfigPaths ...string) (*
 	n.IPAM.RangeStart = nil
 	n.IPAM.RangeEnd = nil
 
-	if n.IPAM.Kubernetes.KubeConfigPath == "" {
-		return nil, "", storageError()
+	switch n.IPAM.GetDatastore() {
+	case types.DatastoreKubernetes, types.DatastoreIPAddress:
+		if n.IPAM.Kubernetes.KubeConfigPath == "" {
+			return nil, "", storageError()
+		}
+	case types.DatastoreEtcd:
+		if n.IPAM.EtcdHost == "" {
+			return nil, "", fmt.Errorf("the etcd datastore requires the `etcd_host` parameter")
+		}
+	case types.DatastoreLocal:
+	default:
+		return nil, "", fmt.Errorf("unsupported datastore %q, it must be one of %q, %q, %q or %q", n.IPAM.Datastore,
+			types.DatastoreKubernetes, types.DatastoreIPAddress, types.DatastoreEtcd, types.DatastoreLocal)
 	}
 
 	if n.IPAM.GatewayStr != "" {
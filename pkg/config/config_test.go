@@ -344,6 +344,73 @@ var _ = Describe("Allocation operations", func() {
 		Expect(ipamConfig.IPRanges[0].RangeEnd).To(Equal(net.ParseIP("192.168.1.209")))
 	})
 
+	It("does not require a kubeconfig with the local datastore", func() {
+		conf := `{
+      "cniVersion": "0.3.1",
+      "name": "mynet",
+      "type": "ipvlan",
+      "master": "foo0",
+        "ipam": {
+          "type": "whereabouts",
+          "datastore": "local",
+          "range": "192.168.1.0/24"
+        }
+      }`
+
+		confPath := filepath.Join(tmpDir, "whereabouts.conf")
+		Expect(os.WriteFile(confPath, []byte(conf), 0755)).To(Succeed())
+
+		ipamConfig, _, err := LoadIPAMConfig([]byte(conf), "", confPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ipamConfig.Datastore).To(Equal("local"))
+	})
+
+	It("selects the etcd datastore when an etcd host is given without a kubeconfig", func() {
+		conf := `{
+      "cniVersion": "0.3.1",
+      "name": "mynet",
+      "type": "ipvlan",
+      "master": "foo0",
+        "ipam": {
+          "type": "whereabouts",
+          "etcd_host": "https://10.0.0.1:2379",
+          "etcd_username": "whereabouts",
+          "etcd_ca_cert_file": "/etc/whereabouts/ca.crt",
+          "range": "192.168.1.0/24"
+        }
+      }`
+
+		confPath := filepath.Join(tmpDir, "whereabouts.conf")
+		Expect(os.WriteFile(confPath, []byte(conf), 0755)).To(Succeed())
+
+		ipamConfig, _, err := LoadIPAMConfig([]byte(conf), "", confPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ipamConfig.GetDatastore()).To(Equal("etcd"))
+		Expect(ipamConfig.EtcdHost).To(Equal("https://10.0.0.1:2379"))
+		Expect(ipamConfig.EtcdUsername).To(Equal("whereabouts"))
+		Expect(ipamConfig.EtcdCACertFile).To(Equal("/etc/whereabouts/ca.crt"))
+	})
+
+	It("errors when an unknown datastore is specified", func() {
+		conf := `{
+      "cniVersion": "0.3.1",
+      "name": "mynet",
+      "type": "ipvlan",
+      "master": "foo0",
+        "ipam": {
+          "type": "whereabouts",
+          "datastore": "etcd3",
+          "range": "192.168.1.0/24"
+        }
+      }`
+
+		confPath := filepath.Join(tmpDir, "whereabouts.conf")
+		Expect(os.WriteFile(confPath, []byte(conf), 0755)).To(Succeed())
+
+		_, _, err := LoadIPAMConfig([]byte(conf), "", confPath)
+		Expect(err).To(MatchError(ContainSubstring(`unsupported datastore "etcd3"`)))
+	})
+
 	It("can unmarshall the cronjob expression", func() {
 		conf := `{
       "cniVersion": "0.3.1",
@@ -181,3 +181,7 @@ func castToIPPool(pools []interface{}) []v1alpha1.IPPool {
 	}
 	return ipPools
 }
+
+func ipPoolsNamespace() string {
+	return kubeClient.ResolveNamespace("", "")
+}
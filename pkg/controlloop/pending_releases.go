@@ -0,0 +1,87 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controlloop
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+
+	wbclientset "github.com/k8snetworkplumbingwg/whereabouts/pkg/generated/clientset/versioned"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/journal"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/logging"
+	wbclient "github.com/k8snetworkplumbingwg/whereabouts/pkg/storage/kubernetes"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+// DefaultPendingReleasesDir is the location of the node's pending-release journal, as mounted in the control loop.
+const DefaultPendingReleasesDir = defaultMountPath + journal.DefaultDir
+
+// PendingReleasesDrainer periodically releases the IPs recorded in the node's pending-release journal, i.e. the
+// IPs whose CNI DEL could not be persisted in the storage backend.
+type PendingReleasesDrainer struct {
+	k8sClient   kubernetes.Interface
+	wbClient    wbclientset.Interface
+	journal     *journal.Journal
+	interval    time.Duration
+	cleanupFunc garbageCollector
+}
+
+// NewPendingReleasesDrainer returns a drainer for the journal kept in dir, which is drained every interval.
+func NewPendingReleasesDrainer(k8sClient kubernetes.Interface, wbClient wbclientset.Interface, dir string, interval time.Duration) *PendingReleasesDrainer {
+	return newPendingReleasesDrainer(k8sClient, wbClient, dir, interval, wbclient.IPManagement)
+}
+
+func newPendingReleasesDrainer(k8sClient kubernetes.Interface, wbClient wbclientset.Interface, dir string, interval time.Duration, cleanupFunc garbageCollector) *PendingReleasesDrainer {
+	return &PendingReleasesDrainer{
+		k8sClient:   k8sClient,
+		wbClient:    wbClient,
+		journal:     journal.New(dir),
+		interval:    interval,
+		cleanupFunc: cleanupFunc,
+	}
+}
+
+// Start drains the journal every interval, until stopChan is closed.
+func (d *PendingReleasesDrainer) Start(stopChan <-chan struct{}) {
+	logging.Verbosef("starting the pending releases drainer")
+	go wait.Until(d.drain, d.interval, stopChan)
+}
+
+func (d *PendingReleasesDrainer) drain() {
+	ctx, cancel := context.WithTimeout(context.Background(), types.DelTimeLimit)
+	defer cancel()
+
+	if err := d.journal.Drain(ctx, d.release); err != nil {
+		logging.Errorf("failed to drain the pending releases: %v", err)
+	}
+}
+
+func (d *PendingReleasesDrainer) release(ctx context.Context, entry journal.Entry) error {
+	k8sIPAM := &wbclient.KubernetesIPAM{
+		Config:      entry.Config,
+		ContainerID: entry.ContainerID,
+		IfName:      entry.IfName,
+		Namespace:   entry.Namespace,
+		Client:      *wbclient.NewKubernetesClient(d.wbClient, d.k8sClient),
+	}
+
+	_, err := d.cleanupFunc(ctx, types.Deallocate, entry.Config, k8sIPAM)
+	return err
+}
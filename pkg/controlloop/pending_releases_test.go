@@ -0,0 +1,88 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controlloop
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakek8sclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/api/whereabouts.cni.cncf.io/v1alpha1"
+	fakewbclient "github.com/k8snetworkplumbingwg/whereabouts/pkg/generated/clientset/versioned/fake"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/journal"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage/kubernetes"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+var _ = Describe("Pending releases drainer", func() {
+	const (
+		ipRange     = "192.168.2.0/24"
+		namespace   = "default"
+		podName     = "tiny-winy-pod"
+		containerID = "container1"
+		ifName      = "net1"
+	)
+
+	var journalDir string
+
+	BeforeEach(func() {
+		var err error
+		journalDir, err = os.MkdirTemp("", "pending-releases")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(journalDir)).To(Succeed())
+	})
+
+	It("releases the IPs recorded in the pending-release journal", func() {
+		pool := ipPool(kubernetes.PoolIdentifier{IpRange: ipRange}, ipPoolsNamespace())
+		pool.ResourceVersion = "1"
+		pool.Spec.Allocations = map[string]v1alpha1.IPAllocation{
+			"1": {ContainerID: containerID, PodRef: fmt.Sprintf("%s/%s", namespace, podName), IfName: ifName},
+		}
+		wbClient := fakewbclient.NewSimpleClientset(pool)
+
+		ipamConf := types.IPAMConfig{
+			Type:                "whereabouts",
+			PodName:             podName,
+			PodNamespace:        namespace,
+			LeaderLeaseDuration: 1500,
+			LeaderRenewDeadline: 1000,
+			LeaderRetryPeriod:   500,
+			IPRanges:            []types.RangeConfiguration{{Range: ipRange}},
+		}
+		pendingReleases := journal.New(filepath.Join(journalDir, "pending-releases"))
+		Expect(pendingReleases.Record(journal.NewEntry(containerID, ifName, ipPoolsNamespace(), ipamConf, fmt.Errorf("the API server is down")))).To(Succeed())
+
+		drainer := NewPendingReleasesDrainer(fakek8sclient.NewSimpleClientset(), wbClient, filepath.Join(journalDir, "pending-releases"), time.Minute)
+		drainer.drain()
+
+		Expect(pendingReleases.List()).To(BeEmpty())
+		updatedPool, err := wbClient.WhereaboutsV1alpha1().IPPools(ipPoolsNamespace()).Get(context.TODO(), pool.GetName(), metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updatedPool.Spec.Allocations).To(BeEmpty())
+	})
+})
@@ -225,15 +225,15 @@ func (pc *PodController) garbageCollectPodIPs(pod *v1.Pod) error {
 
 		var pools []*whereaboutsv1alpha1.IPPool
 		for _, rangeConfig := range ipamConfig.IPRanges {
-			pool, err := pc.ipPool(wbclient.PoolIdentifier{IpRange: rangeConfig.Range, NetworkName: ipamConfig.NetworkName})
+			rangePools, err := pc.ipPool(wbclient.Namespace(*ipamConfig), wbclient.PoolIdentifier{IpRange: rangeConfig.Range, NetworkName: ipamConfig.NetworkName})
 
 			if err != nil {
 				return fmt.Errorf("failed to get the IPPool data: %+v", err)
 			}
 
-			logging.Verbosef("pool range [%s]", pool.Spec.Range)
+			logging.Verbosef("pool range [%s]", rangeConfig.Range)
 
-			pools = append(pools, pool)
+			pools = append(pools, rangePools...)
 		}
 
 		for _, pool := range pools {
@@ -326,12 +326,16 @@ func (pc *PodController) ifaceNetAttachDef(ifaceStatus nadv1.NetworkStatus) (*na
 	return nad, nil
 }
 
-func (pc *PodController) ipPool(poolIdentifier wbclient.PoolIdentifier) (*whereaboutsv1alpha1.IPPool, error) {
-	pool, err := pc.ipPoolLister.IPPools(ipPoolsNamespace()).Get(wbclient.IPPoolName(poolIdentifier))
+// ipPool returns the IPPools holding the allocations of a pool: the pool itself or, when it is sharded, its shards.
+func (pc *PodController) ipPool(namespace string, poolIdentifier wbclient.PoolIdentifier) ([]*whereaboutsv1alpha1.IPPool, error) {
+	pool, err := pc.ipPoolLister.IPPools(namespace).Get(wbclient.IPPoolName(poolIdentifier))
 	if err != nil {
 		return nil, err
 	}
-	return pool, nil
+	if pool.Spec.ShardSize == 0 {
+		return []*whereaboutsv1alpha1.IPPool{pool}, nil
+	}
+	return pc.ipPoolLister.IPPools(pool.GetNamespace()).List(wbclient.ShardSelector(pool.GetName()))
 }
 
 func (pc *PodController) addressGarbageCollected(pod *v1.Pod, networkName string, ipRange string, allocationIndex string) error {
@@ -340,7 +344,7 @@ func (pc *PodController) addressGarbageCollected(pod *v1.Pod, networkName string
 		if err != nil {
 			return err
 		}
-		index, err := strconv.Atoi(allocationIndex)
+		index, err := strconv.ParseUint(allocationIndex, 10, 64)
 		if err != nil {
 			return err
 		}
@@ -349,7 +353,7 @@ func (pc *PodController) addressGarbageCollected(pod *v1.Pod, networkName string
 			v1.EventTypeNormal,
 			addressGarbageCollected,
 			"successful cleanup of IP address [%s] from network %s",
-			iphelpers.IPAddOffset(ip, uint64(index)),
+			iphelpers.IPAddOffset(ip, index),
 			networkName)
 	}
 	return nil
@@ -413,16 +417,6 @@ func ipamConfiguration(nad *nadv1.NetworkAttachmentDefinition, podNamespace stri
 	return ipamConfig, nil
 }
 
-func ipPoolsNamespace() string {
-	const wbNamespaceEnvVariableName = "WHEREABOUTS_NAMESPACE"
-	if wbNamespace, found := os.LookupEnv(wbNamespaceEnvVariableName); found {
-		return wbNamespace
-	}
-
-	const wbDefaultNamespace = "kube-system"
-	return wbDefaultNamespace
-}
-
 func podFromTombstone(obj interface{}) (*v1.Pod, error) {
 	pod, isPod := obj.(*v1.Pod)
 	if !isPod {
@@ -0,0 +1,281 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package daemon
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/api/whereabouts.cni.cncf.io/v1alpha1"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/ipmanagement"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/logging"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage"
+	wbclient "github.com/k8snetworkplumbingwg/whereabouts/pkg/storage/kubernetes"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+// poolBatch holds the pools read by the requests of a batch, along with their updates, until all the requests were
+// managed: each updated pool is then written with a single patch.
+type poolBatch struct {
+	server *Server
+	// fresh tells to read the pools from the API server rather than from the informer cache.
+	fresh bool
+	pools map[string]*batchPool
+	// order keeps the pools in the order they were read, so that they are written in the same order every time.
+	order []*batchPool
+
+	statusChecked bool
+	statusErr     error
+}
+
+func newPoolBatch(server *Server, fresh bool) *poolBatch {
+	return &poolBatch{server: server, fresh: fresh, pools: map[string]*batchPool{}}
+}
+
+// manage manages the requests against the pools of the batch, writes the updated pools, and responds to the
+// requests. It returns the requests to manage again: those whose pools changed since they were read, or whose
+// overlapping range reservations were taken meanwhile.
+func (b *poolBatch) manage(ctx context.Context, requests []*pendingRequest) []*pendingRequest {
+	var stores []*batchStore
+	for _, req := range requests {
+		store := &batchStore{KubernetesIPAM: req.ipam, batch: b, request: req}
+		ips, err := ipmanagement.Manage(ctx, req.mode, store, req.ipam.Config, req.ipam.ContainerID, req.ipam.IfName)
+		if err != nil {
+			req.respond(nil, err)
+			continue
+		}
+		store.ips = ips
+		stores = append(stores, store)
+	}
+
+	writeErrs := b.write(ctx)
+
+	var retries []*pendingRequest
+	for _, store := range stores {
+		err := store.writeErr(writeErrs)
+		if err == nil {
+			err = store.reserveOverlappingRanges(ctx)
+		}
+		if isTemporary(err) {
+			logging.Debugf("managing containerID: %q - ifName: %q again: %v", store.ContainerID, store.IfName, err)
+			retries = append(retries, store.request)
+			continue
+		}
+		store.request.respond(store.ips, err)
+	}
+	return retries
+}
+
+// write patches the updated pools, returning the errors by pool.
+func (b *poolBatch) write(ctx context.Context) map[*batchPool]error {
+	errs := map[*batchPool]error{}
+	for _, pool := range b.order {
+		if !pool.updated {
+			continue
+		}
+		kubernetesPool, err := wbclient.NewKubernetesIPPool(b.server.wbClient, pool.resource)
+		if err == nil {
+			err = kubernetesPool.Update(ctx, pool.reservations)
+		}
+		if err != nil {
+			errs[pool] = err
+			continue
+		}
+		b.server.recordWritten(kubernetesPool.IPPool())
+	}
+	return errs
+}
+
+// read returns the IPPool resource of the given pool, from the informer cache unless the batch is to read it
+// afresh. A pool the cache does not hold yet is read, or created, through the API server.
+func (b *poolBatch) read(ctx context.Context, ipam *wbclient.KubernetesIPAM, poolIdentifier storage.PoolIdentifier) (*v1alpha1.IPPool, error) {
+	if !b.fresh {
+		pool, err := b.server.cachedPool(ipam.Namespace, wbclient.IPPoolName(poolIdentifier))
+		if err == nil {
+			return pool, nil
+		}
+		if !k8serrors.IsNotFound(err) {
+			return nil, err
+		}
+	}
+	return ipam.GetIPPoolResource(ctx, poolIdentifier)
+}
+
+// batchPool is a pool read by the requests of a batch, holding their updates until it is written.
+type batchPool struct {
+	resource     *v1alpha1.IPPool
+	reservations []types.IPReservation
+	updated      bool
+}
+
+func newBatchPool(server *Server, resource *v1alpha1.IPPool) (*batchPool, error) {
+	kubernetesPool, err := wbclient.NewKubernetesIPPool(server.wbClient, resource.DeepCopy())
+	if err != nil {
+		return nil, err
+	}
+	return &batchPool{resource: resource, reservations: kubernetesPool.Allocations()}, nil
+}
+
+// Allocations returns the reservations of the pool, including the updates of the batch.
+func (p *batchPool) Allocations() []types.IPReservation {
+	return slices.Clone(p.reservations)
+}
+
+// requestPool is a pool of the batch, as updated by one of its requests.
+type requestPool struct {
+	*batchPool
+	store *batchStore
+}
+
+// Update keeps the reservations until the pool is written along with the updates of the other requests.
+func (p *requestPool) Update(_ context.Context, reservations []types.IPReservation) error {
+	p.reservations = slices.Clone(reservations)
+	p.updated = true
+	if !slices.Contains(p.store.updated, p.batchPool) {
+		p.store.updated = append(p.store.updated, p.batchPool)
+	}
+	return nil
+}
+
+// batchStore is the storage.Store a request of a batch is managed with: its pools are those of the batch, and its
+// overlapping range reservations are deferred until its pools were written.
+type batchStore struct {
+	*wbclient.KubernetesIPAM
+	batch   *poolBatch
+	request *pendingRequest
+	ips     []net.IPNet
+	// updated are the pools of the batch the request updated.
+	updated []*batchPool
+	// overlappingRangeUpdates are the deferred updates of the overlapping range reservations.
+	overlappingRangeUpdates []func(ctx context.Context) error
+}
+
+// Status checks the connectivity to the API server once per batch.
+func (s *batchStore) Status(ctx context.Context) error {
+	if !s.batch.statusChecked {
+		s.batch.statusErr = s.KubernetesIPAM.Status(ctx)
+		s.batch.statusChecked = true
+	}
+	return s.batch.statusErr
+}
+
+// GetIPPool returns the pool of the batch, reading it on first use. The sharded pools are read and updated on their
+// own, their allocations being held by their shards.
+func (s *batchStore) GetIPPool(ctx context.Context, poolIdentifier storage.PoolIdentifier) (storage.IPPool, error) {
+	key := s.Namespace + "/" + wbclient.IPPoolName(poolIdentifier)
+	pool, ok := s.batch.pools[key]
+	if !ok {
+		resource, err := s.batch.read(ctx, s.KubernetesIPAM, poolIdentifier)
+		if err != nil {
+			return nil, err
+		}
+		if resource.Spec.ShardSize > 0 {
+			return s.KubernetesIPAM.GetIPPool(ctx, poolIdentifier)
+		}
+		pool, err = newBatchPool(s.batch.server, resource)
+		if err != nil {
+			return nil, err
+		}
+		s.batch.pools[key] = pool
+		s.batch.order = append(s.batch.order, pool)
+	}
+	return &requestPool{batchPool: pool, store: s}, nil
+}
+
+// GetOverlappingRangeStore returns the overlapping range store of the request, whose updates are deferred.
+func (s *batchStore) GetOverlappingRangeStore() (storage.OverlappingRangeStore, error) {
+	store, err := s.KubernetesIPAM.GetOverlappingRangeStore()
+	if err != nil {
+		return nil, err
+	}
+	return &deferredOverlappingRangeStore{OverlappingRangeStore: store, batchStore: s}, nil
+}
+
+// writeErr returns the error the first of the pools updated by the request failed to be written with.
+func (s *batchStore) writeErr(errs map[*batchPool]error) error {
+	for _, pool := range s.updated {
+		if err, ok := errs[pool]; ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// reserveOverlappingRanges applies the deferred updates of the overlapping range reservations of the request. An
+// IP reserved by another pod meanwhile is reported as a temporary error, the request being managed again.
+func (s *batchStore) reserveOverlappingRanges(ctx context.Context) error {
+	for _, update := range s.overlappingRangeUpdates {
+		err := update(ctx)
+		var reservedErr *storage.OverlappingRangeReservedError
+		if errors.As(err, &reservedErr) {
+			return &temporaryError{err}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deferredOverlappingRangeStore reads the overlapping range reservations right away, but defers their updates until
+// the pools of the request were written, as the store of a single request would.
+type deferredOverlappingRangeStore struct {
+	storage.OverlappingRangeStore
+	batchStore *batchStore
+}
+
+func (d *deferredOverlappingRangeStore) UpdateOverlappingRangeAllocation(_ context.Context, mode int, ip net.IP,
+	containerID, podRef, ifName, networkName string) error {
+	d.deferUpdate(func(ctx context.Context) error {
+		return d.OverlappingRangeStore.UpdateOverlappingRangeAllocation(ctx, mode, ip, containerID, podRef, ifName, networkName)
+	})
+	return nil
+}
+
+func (d *deferredOverlappingRangeStore) TransferOverlappingRangeReservation(_ context.Context,
+	reservation *v1alpha1.OverlappingRangeIPReservation, ip net.IP, containerID, ifName, networkName string) error {
+	d.deferUpdate(func(ctx context.Context) error {
+		return d.OverlappingRangeStore.TransferOverlappingRangeReservation(ctx, reservation, ip, containerID, ifName, networkName)
+	})
+	return nil
+}
+
+func (d *deferredOverlappingRangeStore) deferUpdate(update func(ctx context.Context) error) {
+	d.batchStore.overlappingRangeUpdates = append(d.batchStore.overlappingRangeUpdates, update)
+}
+
+// temporaryError is a failure of a request which is managed again.
+type temporaryError struct {
+	error
+}
+
+func (t *temporaryError) Temporary() bool {
+	return true
+}
+
+func (t *temporaryError) Unwrap() error {
+	return t.error
+}
+
+func isTemporary(err error) bool {
+	e, ok := err.(storage.Temporary)
+	return ok && e.Temporary()
+}
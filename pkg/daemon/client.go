@@ -0,0 +1,100 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	cnitypes "github.com/containernetworking/cni/pkg/types"
+
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+// ErrUnavailable is returned when the daemon cannot be reached; the caller should manage the IPs itself.
+var ErrUnavailable = errors.New("the whereabouts daemon is unavailable")
+
+// Client talks to the node-local allocation daemon.
+type Client struct {
+	socketPath string
+	httpClient *http.Client
+}
+
+// NewClient returns a client of the daemon listening on socketPath.
+func NewClient(socketPath string) *Client {
+	return &Client{
+		socketPath: socketPath,
+		httpClient: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, "unix", socketPath)
+				},
+			},
+		},
+	}
+}
+
+// Available tells whether the daemon socket is present.
+func (c *Client) Available() bool {
+	info, err := os.Stat(c.socketPath)
+	return err == nil && info.Mode()&os.ModeSocket != 0
+}
+
+// IPManagement has the daemon allocate, or release, the IPs of a pod interface. It returns ErrUnavailable when
+// the daemon cannot be reached, in which case the request was not processed.
+func (c *Client) IPManagement(ctx context.Context, mode int, containerID, ifName, namespace string, ipamConf types.IPAMConfig) ([]net.IPNet, error) {
+	body, err := json.Marshal(newRequest(mode, containerID, ifName, namespace, ipamConf))
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize the daemon request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://whereabouts"+ipamPath, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the daemon request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+		}
+		return nil, fmt.Errorf("daemon request failed: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	var resp Response
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to decode the daemon response (status %d): %w", httpResp.StatusCode, err)
+	}
+	if resp.Error != nil {
+		if resp.Error.Code == cnitypes.ErrInvalidNetworkConfig {
+			return nil, &storage.InvalidConfigError{Err: resp.Error}
+		}
+		return nil, resp.Error
+	}
+	return resp.IPs, nil
+}
@@ -0,0 +1,249 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakek8sclient "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/api/whereabouts.cni.cncf.io/v1alpha1"
+	fakewbclient "github.com/k8snetworkplumbingwg/whereabouts/pkg/generated/clientset/versioned/fake"
+	wbinformers "github.com/k8snetworkplumbingwg/whereabouts/pkg/generated/informers/externalversions"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage"
+	wbclient "github.com/k8snetworkplumbingwg/whereabouts/pkg/storage/kubernetes"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+func TestDaemon(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Daemon Suite")
+}
+
+var _ = Describe("Node-local allocation daemon", func() {
+	const (
+		ipRange   = "192.168.3.0/24"
+		namespace = "kube-system"
+		podCount  = 10
+	)
+
+	var (
+		socketDir string
+		client    *Client
+		wbClient  *fakewbclient.Clientset
+		stopChan  chan struct{}
+	)
+
+	ipamConfig := func(podName string) types.IPAMConfig {
+		return types.IPAMConfig{
+			Type:                "whereabouts",
+			PodName:             podName,
+			PodNamespace:        "default",
+			LeaderLeaseDuration: 1500,
+			LeaderRenewDeadline: 1000,
+			LeaderRetryPeriod:   500,
+			IPRanges:            []types.RangeConfiguration{{Range: ipRange}},
+		}
+	}
+
+	BeforeEach(func() {
+		var err error
+		socketDir, err = os.MkdirTemp("", "whereabouts-daemon")
+		Expect(err).NotTo(HaveOccurred())
+
+		pool := &v1alpha1.IPPool{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            wbclient.IPPoolName(wbclient.PoolIdentifier{IpRange: ipRange}),
+				Namespace:       namespace,
+				ResourceVersion: "1",
+			},
+			Spec: v1alpha1.IPPoolSpec{Range: ipRange, Allocations: map[string]v1alpha1.IPAllocation{}},
+		}
+		wbClient = fakewbclient.NewSimpleClientset(pool)
+
+		socketPath := filepath.Join(socketDir, "run", "whereabouts.sock")
+		listener, err := Listen(socketPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		stop := make(chan struct{})
+		stopChan = stop
+		wbInformerFactory := wbinformers.NewSharedInformerFactory(wbClient, 0)
+		server := NewServer(fakek8sclient.NewSimpleClientset(), wbClient, wbInformerFactory, "whereabouts-daemon/node1")
+		wbInformerFactory.Start(stop)
+		go func() {
+			defer GinkgoRecover()
+			Expect(server.Serve(listener, stop)).To(Succeed())
+		}()
+		client = NewClient(socketPath)
+	})
+
+	AfterEach(func() {
+		close(stopChan)
+		Expect(os.RemoveAll(socketDir)).To(Succeed())
+	})
+
+	It("allocates distinct IPs to concurrent requests, then releases them", func() {
+		Expect(client.Available()).To(BeTrue())
+
+		var (
+			wg          sync.WaitGroup
+			mu          sync.Mutex
+			allocations = map[string]string{}
+		)
+		for i := 0; i < podCount; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer GinkgoRecover()
+				defer wg.Done()
+
+				podName := fmt.Sprintf("pod%d", i)
+				ips, err := client.IPManagement(context.Background(), types.Allocate, fmt.Sprintf("container%d", i), "net1", namespace, ipamConfig(podName))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ips).To(HaveLen(1))
+
+				mu.Lock()
+				defer mu.Unlock()
+				allocations[ips[0].IP.String()] = podName
+			}(i)
+		}
+		wg.Wait()
+		Expect(allocations).To(HaveLen(podCount))
+
+		for i := 0; i < podCount; i++ {
+			_, err := client.IPManagement(context.Background(), types.Deallocate, fmt.Sprintf("container%d", i), "net1", namespace, ipamConfig(fmt.Sprintf("pod%d", i)))
+			Expect(err).NotTo(HaveOccurred())
+		}
+		pool, err := wbClient.WhereaboutsV1alpha1().IPPools(namespace).Get(context.Background(), wbclient.IPPoolName(wbclient.PoolIdentifier{IpRange: ipRange}), metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pool.Spec.Allocations).To(BeEmpty())
+	})
+
+	It("merges the updates of a batch into a single patch per pool", func() {
+		wbInformerFactory := wbinformers.NewSharedInformerFactory(wbClient, 0)
+		server := NewServer(fakek8sclient.NewSimpleClientset(), wbClient, wbInformerFactory, "whereabouts-daemon/node1")
+		wbInformerFactory.Start(stopChan)
+		wbInformerFactory.WaitForCacheSync(stopChan)
+		wbClient.ClearActions()
+
+		var batch []*pendingRequest
+		for i := 0; i < podCount; i++ {
+			batch = append(batch, server.newPendingRequest(newRequest(types.Allocate, fmt.Sprintf("container%d", i), "net1", namespace, ipamConfig(fmt.Sprintf("pod%d", i)))))
+		}
+		Expect(newPoolBatch(server, false).manage(context.Background(), batch)).To(BeEmpty())
+
+		allocated := map[string]bool{}
+		for _, req := range batch {
+			resp := <-req.result
+			Expect(resp.Error).To(BeNil())
+			Expect(resp.IPs).To(HaveLen(1))
+			allocated[resp.IPs[0].IP.String()] = true
+		}
+		Expect(allocated).To(HaveLen(podCount))
+
+		patches := 0
+		for _, action := range wbClient.Actions() {
+			Expect(action.GetVerb()).NotTo(Equal("get"), "the pool is expected to be read from the cache")
+			if action.GetVerb() == "patch" {
+				patches++
+			}
+		}
+		Expect(patches).To(Equal(1))
+
+		pool, err := wbClient.WhereaboutsV1alpha1().IPPools(namespace).Get(context.Background(), wbclient.IPPoolName(wbclient.PoolIdentifier{IpRange: ipRange}), metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pool.Spec.Allocations).To(HaveLen(podCount))
+	})
+
+	It("manages a batch again against the pool read from the API server when its cached pool is stale", func() {
+		poolName := wbclient.IPPoolName(wbclient.PoolIdentifier{IpRange: ipRange})
+		wbInformerFactory := wbinformers.NewSharedInformerFactory(wbClient, 0)
+		server := NewServer(fakek8sclient.NewSimpleClientset(), wbClient, wbInformerFactory, "whereabouts-daemon/node1")
+		// the cache holds the pool before another node allocated its first IP
+		stale, err := wbClient.WhereaboutsV1alpha1().IPPools(namespace).Get(context.Background(), poolName, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(wbInformerFactory.Whereabouts().V1alpha1().IPPools().Informer().GetIndexer().Add(stale)).To(Succeed())
+
+		current := stale.DeepCopy()
+		current.ResourceVersion = "2"
+		current.Spec.Allocations["1"] = v1alpha1.IPAllocation{ContainerID: "other", PodRef: "default/other", IfName: "net1"}
+		Expect(wbClient.Tracker().Update(v1alpha1.SchemeGroupVersion.WithResource("ippools"), current, namespace)).To(Succeed())
+
+		// the resource version the patch is conditioned on does not match
+		conflicts := 0
+		wbClient.PrependReactor("patch", "ippools", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			if conflicts > 0 {
+				return false, nil, nil
+			}
+			conflicts++
+			return true, nil, k8serrors.NewInvalid(schema.GroupKind{Group: v1alpha1.SchemeGroupVersion.Group, Kind: "IPPool"}, poolName, nil)
+		})
+
+		req := server.newPendingRequest(newRequest(types.Allocate, "container1", "net1", namespace, ipamConfig("pod1")))
+		server.manageBatch(context.Background(), []*pendingRequest{req})
+
+		resp := <-req.result
+		Expect(resp.Error).To(BeNil())
+		Expect(resp.IPs).To(HaveLen(1))
+		Expect(resp.IPs[0].IP.String()).To(Equal("192.168.3.2"))
+
+		pool, err := wbClient.WhereaboutsV1alpha1().IPPools(namespace).Get(context.Background(), poolName, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pool.Spec.Allocations).To(HaveLen(2))
+	})
+
+	It("rejects requests without a pod name as an invalid configuration", func() {
+		_, err := client.IPManagement(context.Background(), types.Allocate, "container1", "net1", namespace, ipamConfig(""))
+		var invalidConfigErr *storage.InvalidConfigError
+		Expect(errors.As(err, &invalidConfigErr)).To(BeTrue())
+	})
+
+	It("reports itself unavailable when nothing listens on the socket", func() {
+		missing := NewClient(filepath.Join(socketDir, "missing.sock"))
+		Expect(missing.Available()).To(BeFalse())
+
+		_, err := missing.IPManagement(context.Background(), types.Allocate, "container1", "net1", namespace, ipamConfig("pod1"))
+		Expect(errors.Is(err, ErrUnavailable)).To(BeTrue())
+	})
+
+	It("replaces the socket left behind by a previous instance", func() {
+		stale := filepath.Join(socketDir, "stale.sock")
+		listener, err := net.Listen("unix", stale)
+		Expect(err).NotTo(HaveOccurred())
+		// leave the socket file behind, as a crashed daemon would
+		listener.(*net.UnixListener).SetUnlinkOnClose(false)
+		Expect(listener.Close()).To(Succeed())
+
+		listener, err = Listen(stale)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(listener.Close()).To(Succeed())
+	})
+})
@@ -0,0 +1,71 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package daemon implements the optional node-local allocation daemon, and the client the CNI plugin uses to
+// reach it over a unix socket. The daemon keeps a single Kubernetes client for the node, and serves the requests
+// received concurrently in batches, holding the pools leases once per batch rather than once per request. The pools
+// are read from an informer cache, and written with a single patch per pool and batch.
+package daemon
+
+import (
+	"net"
+
+	cnitypes "github.com/containernetworking/cni/pkg/types"
+
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+const (
+	// DefaultSocketPath is where the CNI plugin looks for the daemon socket, on the host.
+	DefaultSocketPath = "/run/whereabouts/whereabouts.sock"
+
+	ipamPath = "/v1/ipam"
+)
+
+// Request is an allocation, or a release, of the IPs of a pod interface.
+type Request struct {
+	Mode        int              `json:"mode"`
+	ContainerID string           `json:"containerID"`
+	IfName      string           `json:"ifName"`
+	Namespace   string           `json:"namespace"`
+	Config      types.IPAMConfig `json:"ipam"`
+	// OverlappingRanges is kept aside from the IPAM configuration, which omits it when disabled and enables it
+	// by default when unmarshalled.
+	OverlappingRanges bool `json:"enableOverlappingRanges"`
+}
+
+// Response carries either the allocated IPs, or the CNI error the request failed with.
+type Response struct {
+	IPs   []net.IPNet     `json:"ips,omitempty"`
+	Error *cnitypes.Error `json:"error,omitempty"`
+}
+
+func newRequest(mode int, containerID, ifName, namespace string, ipamConf types.IPAMConfig) Request {
+	return Request{
+		Mode:              mode,
+		ContainerID:       containerID,
+		IfName:            ifName,
+		Namespace:         namespace,
+		Config:            ipamConf,
+		OverlappingRanges: ipamConf.OverlappingRanges,
+	}
+}
+
+func (r *Request) ipamConfig() types.IPAMConfig {
+	ipamConf := r.Config
+	ipamConf.OverlappingRanges = r.OverlappingRanges
+	return ipamConf
+}
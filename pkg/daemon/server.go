@@ -0,0 +1,325 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/api/whereabouts.cni.cncf.io/v1alpha1"
+	wbclientset "github.com/k8snetworkplumbingwg/whereabouts/pkg/generated/clientset/versioned"
+	wbinformers "github.com/k8snetworkplumbingwg/whereabouts/pkg/generated/informers/externalversions"
+	wblisters "github.com/k8snetworkplumbingwg/whereabouts/pkg/generated/listers/whereabouts.cni.cncf.io/v1alpha1"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/logging"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage"
+	wbclient "github.com/k8snetworkplumbingwg/whereabouts/pkg/storage/kubernetes"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+const (
+	maxBatchSize      = 32
+	readHeaderTimeout = 10 * time.Second
+	socketDirMode     = 0700
+)
+
+// Server is the node-local allocation daemon.
+type Server struct {
+	client      wbclient.Client
+	wbClient    wbclientset.Interface
+	identity    string
+	pools       wblisters.IPPoolLister
+	poolsSynced cache.InformerSynced
+
+	mu sync.Mutex
+	// queues holds the requests waiting for each set of leases, while their batches are processed.
+	queues map[string][]*pendingRequest
+	// written holds the pools as last written by the daemon, until the informer cache catches up with them.
+	written map[string]*v1alpha1.IPPool
+}
+
+type pendingRequest struct {
+	mode      int
+	ipam      *wbclient.KubernetesIPAM
+	result    chan Response
+	responded bool
+}
+
+// NewServer returns a daemon using the given clients; identity is the holder identity it records in the leases. The
+// daemon reads the IPPools from the informer of wbInformerFactory, which the caller starts.
+func NewServer(k8sClient kubernetes.Interface, wbClient wbclientset.Interface, wbInformerFactory wbinformers.SharedInformerFactory, identity string) *Server {
+	poolInformer := wbInformerFactory.Whereabouts().V1alpha1().IPPools()
+	s := &Server{
+		client:      *wbclient.NewKubernetesClient(wbClient, k8sClient),
+		wbClient:    wbClient,
+		identity:    identity,
+		pools:       poolInformer.Lister(),
+		poolsSynced: poolInformer.Informer().HasSynced,
+		queues:      map[string][]*pendingRequest{},
+		written:     map[string]*v1alpha1.IPPool{},
+	}
+	_, _ = poolInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    s.observePool,
+		UpdateFunc: func(_, obj interface{}) { s.observePool(obj) },
+		DeleteFunc: s.forgetPool,
+	})
+	return s
+}
+
+// Listen creates the unix socket the daemon serves on, replacing the one left behind by a previous instance.
+func Listen(socketPath string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(socketPath), socketDirMode); err != nil {
+		return nil, fmt.Errorf("failed to create the daemon socket directory: %w", err)
+	}
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove the stale daemon socket: %w", err)
+	}
+	return net.Listen("unix", socketPath)
+}
+
+// Serve serves the requests received on listener until stopChan is closed, once the IPPools are cached.
+func (s *Server) Serve(listener net.Listener, stopChan <-chan struct{}) error {
+	if !cache.WaitForCacheSync(stopChan, s.poolsSynced) {
+		// stopped before the cache synced
+		return listener.Close()
+	}
+
+	server := &http.Server{Handler: s, ReadHeaderTimeout: readHeaderTimeout}
+	go func() {
+		<-stopChan
+		_ = server.Close()
+	}()
+
+	logging.Verbosef("whereabouts daemon serving on %s", listener.Addr())
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != ipamPath {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeResponse(w, errorResponse(&storage.InvalidConfigError{Err: fmt.Errorf("invalid daemon request: %v", err)}))
+		return
+	}
+	if req.Config.PodName == "" {
+		writeResponse(w, errorResponse(&storage.InvalidConfigError{Err: fmt.Errorf("IPAM client initialization error: no pod name")}))
+		return
+	}
+
+	pending := s.newPendingRequest(req)
+	if pending.ipam.Config.OptimisticConcurrency || pending.ipam.Config.PerAddressAllocations {
+		// the pools are not protected by leases, and are updated as the request is received
+		go s.process(pending)
+	} else {
+		leaseNames, err := wbclient.LeaseNames(r.Context(), pending.ipam)
+		if err != nil {
+			writeResponse(w, errorResponse(err))
+			return
+		}
+		s.enqueue(pending.ipam.Namespace+"/"+strings.Join(leaseNames, ","), pending)
+	}
+
+	select {
+	case resp := <-pending.result:
+		writeResponse(w, resp)
+	case <-r.Context().Done():
+		logging.Errorf("client went away before its request for containerID: %q - ifName: %q was processed", req.ContainerID, req.IfName)
+	}
+}
+
+func (s *Server) newPendingRequest(req Request) *pendingRequest {
+	return &pendingRequest{
+		mode: req.Mode,
+		ipam: &wbclient.KubernetesIPAM{
+			Client:      s.client,
+			Config:      req.ipamConfig(),
+			ContainerID: req.ContainerID,
+			IfName:      req.IfName,
+			Namespace:   req.Namespace,
+		},
+		result: make(chan Response, 1),
+	}
+}
+
+// process manages a request on its own, within its own deadline.
+func (s *Server) process(req *pendingRequest) {
+	ctx, cancel := context.WithTimeout(context.Background(), types.AddTimeLimit)
+	defer cancel()
+
+	ips, err := wbclient.IPManagement(ctx, req.mode, req.ipam.Config, req.ipam)
+	req.respond(ips, err)
+}
+
+// enqueue queues a request for the given set of leases, processing the queue unless it already is. The queues of
+// distinct sets of leases are processed concurrently; the sets sharing a pool are kept consistent by the resource
+// version the pool updates are conditioned on.
+func (s *Server) enqueue(leases string, req *pendingRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	queue, processing := s.queues[leases]
+	s.queues[leases] = append(queue, req)
+	if !processing {
+		go s.processQueue(leases)
+	}
+}
+
+// processQueue serves the requests queued for a set of leases in batches, until none is left: all the requests
+// queued while a batch is processed make the next batch, which holds the leases only once.
+func (s *Server) processQueue(leases string) {
+	for {
+		s.mu.Lock()
+		batch := s.queues[leases]
+		if len(batch) == 0 {
+			delete(s.queues, leases)
+			s.mu.Unlock()
+			return
+		}
+		batch = batch[:min(len(batch), maxBatchSize)]
+		s.queues[leases] = s.queues[leases][len(batch):]
+		s.mu.Unlock()
+
+		logging.Debugf("processing %d request(s) holding leases %s", len(batch), leases)
+		s.processBatch(batch)
+	}
+}
+
+// processBatch serves a batch of requests once elected leader of their leases, within the batch's own deadline.
+func (s *Server) processBatch(batch []*pendingRequest) {
+	ctx, cancel := context.WithTimeout(context.Background(), types.AddTimeLimit)
+	defer cancel()
+
+	err := wbclient.RunAsLeader(ctx, batch[0].ipam, s.identity, func(ctx context.Context) error {
+		s.manageBatch(ctx, batch)
+		return nil
+	})
+	if err == nil {
+		err = &storage.LeaseTimeoutError{Err: fmt.Errorf("deposed before processing the requests")}
+	}
+	for _, req := range batch {
+		if !req.responded {
+			req.respond(nil, err)
+		}
+	}
+}
+
+// manageBatch manages the requests of a batch against the pools read from the informer cache, writing each updated
+// pool with a single patch. The requests whose pools changed since they were read are managed again, against the
+// pools read from the API server.
+func (s *Server) manageBatch(ctx context.Context, batch []*pendingRequest) {
+	retry := storage.DefaultRetryPolicy().NewRetrier(ctx)
+	for len(batch) > 0 && retry.Next() {
+		batch = newPoolBatch(s, retry.Attempts() > 1).manage(ctx, batch)
+		if len(batch) > 0 {
+			retry.Conflict()
+		}
+	}
+	for _, req := range batch {
+		req.respond(nil, &storage.UpdateConflictError{Err: retry.Err(), Attempts: retry.Attempts(), Conflicts: retry.Conflicts()})
+	}
+}
+
+// cachedPool returns a copy of the given IPPool, as last written by the daemon when the informer cache did not catch
+// up with it yet, or from the informer cache.
+func (s *Server) cachedPool(namespace, name string) (*v1alpha1.IPPool, error) {
+	s.mu.Lock()
+	pool, ok := s.written[namespace+"/"+name]
+	s.mu.Unlock()
+	if ok {
+		return pool.DeepCopy(), nil
+	}
+
+	pool, err := s.pools.IPPools(namespace).Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return pool.DeepCopy(), nil
+}
+
+func (s *Server) recordWritten(pool *v1alpha1.IPPool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.written[pool.Namespace+"/"+pool.Name] = pool.DeepCopy()
+}
+
+// observePool forgets the pool written by the daemon once the informer cache caught up with it.
+func (s *Server) observePool(obj interface{}) {
+	pool, ok := obj.(*v1alpha1.IPPool)
+	if !ok {
+		return
+	}
+	key := pool.Namespace + "/" + pool.Name
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if written, ok := s.written[key]; ok && written.ResourceVersion == pool.ResourceVersion {
+		delete(s.written, key)
+	}
+}
+
+func (s *Server) forgetPool(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.written, key)
+}
+
+func (r *pendingRequest) respond(ips []net.IPNet, err error) {
+	r.responded = true
+	if err != nil {
+		r.result <- errorResponse(err)
+		return
+	}
+	r.result <- Response{IPs: ips}
+}
+
+func errorResponse(err error) Response {
+	return Response{Error: storage.CNIError(err)}
+}
+
+func writeResponse(w http.ResponseWriter, resp Response) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.Errorf("failed to write the daemon response: %v", err)
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package fsck checks the consistency of the allocations held by IPPools, and repairs them.
+package fsck
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"sort"
+
+	whereaboutsv1alpha1 "github.com/k8snetworkplumbingwg/whereabouts/pkg/api/whereabouts.cni.cncf.io/v1alpha1"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/iphelpers"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+// ProblemType is the kind of inconsistency found in an IPPool
+type ProblemType string
+
+const (
+	// InvalidRange is reported for the pools whose range does not parse; their allocations are not checked.
+	InvalidRange ProblemType = "InvalidRange"
+	// InvalidOffset is reported for the allocations whose offset is not an integer; they hold no IP and are
+	// ignored by the allocations.
+	InvalidOffset ProblemType = "InvalidOffset"
+	// OffsetOutOfRange is reported for the allocations whose IP is not a usable IP of the range of the pool, or not
+	// in the offsets of the shard holding them.
+	OffsetOutOfRange ProblemType = "OffsetOutOfRange"
+	// EmptyPodRef is reported for the allocations of no pod, which are never released.
+	EmptyPodRef ProblemType = "EmptyPodRef"
+	// ExcludedIP is reported for the allocations of IPs of the exclude list of the range.
+	ExcludedIP ProblemType = "ExcludedIP"
+	// DuplicateInterface is reported for each allocation of a pod interface holding several IPs of a pool.
+	DuplicateInterface ProblemType = "DuplicateInterface"
+)
+
+// Problem is an inconsistency found in an IPPool
+type Problem struct {
+	Type      ProblemType `json:"type"`
+	Namespace string      `json:"namespace"`
+	Pool      string      `json:"pool"`
+	// Offset is the key of the allocation in the IPPool, empty for the problems of the pool itself
+	Offset  string `json:"offset,omitempty"`
+	IP      string `json:"ip,omitempty"`
+	PodRef  string `json:"podRef,omitempty"`
+	IfName  string `json:"ifName,omitempty"`
+	Message string `json:"message"`
+	// Repairable is true when the problem is repaired by removing the allocation from the pool. The duplicate
+	// allocations are not, as there is no telling which of the IPs the pod uses, and neither are the excluded IPs,
+	// which a live pod may still use, e.g. as a static IP.
+	Repairable bool `json:"repairable"`
+}
+
+// Report lists the problems found in a set of IPPools
+type Report struct {
+	Pools       int       `json:"pools"`
+	Allocations int       `json:"allocations"`
+	Problems    []Problem `json:"problems"`
+}
+
+// RangeConfiguration returns the configuration of the range of an IPPool, e.g. as found in the
+// network-attachment-definitions; only its exclude list is checked.
+type RangeConfiguration func(pool *whereaboutsv1alpha1.IPPool) types.RangeConfiguration
+
+// Check checks the allocations of the given IPPools. The shards of a pool are checked together with it, so a pod
+// interface holding IPs in several shards of a pool is reported.
+func Check(pools []whereaboutsv1alpha1.IPPool, rangeConfiguration RangeConfiguration) Report {
+	type interfaceKey struct {
+		namespace, pool, podRef, ifName string
+	}
+
+	report := Report{Problems: []Problem{}}
+	interfaceProblems := map[interfaceKey][]Problem{}
+	var interfaces []interfaceKey
+	for idx := range pools {
+		pool := &pools[idx]
+		report.Pools++
+		report.Allocations += len(pool.Spec.Allocations)
+
+		problems, allocations := checkPool(pool, rangeConfiguration(pool))
+		report.Problems = append(report.Problems, problems...)
+
+		poolName := pool.GetName()
+		if pool.Spec.Shard != nil {
+			poolName = pool.Spec.Shard.Parent
+		}
+		for _, allocation := range allocations {
+			key := interfaceKey{namespace: pool.GetNamespace(), pool: poolName, podRef: allocation.PodRef, ifName: allocation.IfName}
+			if _, ok := interfaceProblems[key]; !ok {
+				interfaces = append(interfaces, key)
+			}
+			interfaceProblems[key] = append(interfaceProblems[key], allocation)
+		}
+	}
+
+	for _, key := range interfaces {
+		duplicates := interfaceProblems[key]
+		if len(duplicates) < 2 {
+			continue
+		}
+		for _, duplicate := range duplicates {
+			duplicate.Type = DuplicateInterface
+			duplicate.Message = fmt.Sprintf("interface %s of pod %s holds %d IPs of pool %s", key.ifName, key.podRef, len(duplicates), key.pool)
+			report.Problems = append(report.Problems, duplicate)
+		}
+	}
+	return report
+}
+
+// checkPool returns the problems of the allocations of the pool, along with the allocations of pod interfaces which
+// are to be checked for duplicates, as problems lacking their type.
+func checkPool(pool *whereaboutsv1alpha1.IPPool, rangeConf types.RangeConfiguration) ([]Problem, []Problem) {
+	var problems, allocations []Problem
+	newProblem := func(problemType ProblemType, message string) Problem {
+		return Problem{Type: problemType, Namespace: pool.GetNamespace(), Pool: pool.GetName(), Message: message}
+	}
+
+	networkIP, ipNet, err := pool.ParseCIDR()
+	if err != nil {
+		return append(problems, newProblem(InvalidRange, fmt.Sprintf("invalid range %q: %v", pool.Spec.Range, err))), nil
+	}
+	var excluded []*net.IPNet
+	for _, omitRange := range rangeConf.OmitRanges {
+		if _, subnet, err := net.ParseCIDR(omitRange); err == nil {
+			excluded = append(excluded, subnet)
+		}
+	}
+
+	// the usable IPs are the offsets between the network IP and the broadcast IP; those of the ranges larger than a
+	// /64 exceed the offsets of the allocations
+	lastOffset := big.NewInt(-1)
+	if iphelpers.HasUsableIPs(*ipNet) {
+		lastUsableIP, _ := iphelpers.LastUsableIP(*ipNet)
+		lastOffset.Sub(new(big.Int).SetBytes(lastUsableIP.To16()), new(big.Int).SetBytes(networkIP.To16()))
+	}
+
+	offsets := make([]string, 0, len(pool.Spec.Allocations))
+	for offset := range pool.Spec.Allocations {
+		offsets = append(offsets, offset)
+	}
+	sort.Strings(offsets)
+
+	for _, offset := range offsets {
+		allocation := pool.Spec.Allocations[offset]
+		newAllocationProblem := func(problemType ProblemType, message string) Problem {
+			problem := newProblem(problemType, message)
+			problem.Offset = offset
+			problem.PodRef = allocation.PodRef
+			problem.IfName = allocation.IfName
+			problem.Repairable = true
+			return problem
+		}
+
+		bigOffset, ok := new(big.Int).SetString(offset, 10)
+		if !ok {
+			problems = append(problems, newAllocationProblem(InvalidOffset, fmt.Sprintf("invalid offset %q", offset)))
+			continue
+		}
+		// the offsets beyond 64 bits are not read by the allocations
+		if bigOffset.Sign() < 1 || bigOffset.Cmp(lastOffset) > 0 || !bigOffset.IsUint64() {
+			problems = append(problems, newAllocationProblem(OffsetOutOfRange,
+				fmt.Sprintf("offset %s is not a usable IP of range %s", bigOffset, pool.Spec.Range)))
+			continue
+		}
+		numOffset := bigOffset.Uint64()
+		if shard := pool.Spec.Shard; shard != nil && (numOffset > math.MaxInt64 || int64(numOffset) < shard.Start || int64(numOffset) >= shard.End) {
+			problems = append(problems, newAllocationProblem(OffsetOutOfRange,
+				fmt.Sprintf("offset %d is not one of the offsets [%d, %d) of the shard", numOffset, shard.Start, shard.End)))
+			continue
+		}
+
+		ip := iphelpers.IPAddOffset(networkIP, numOffset)
+		if allocation.PodRef == "" {
+			problem := newAllocationProblem(EmptyPodRef, fmt.Sprintf("IP %s is not allocated to any pod", ip))
+			problem.IP = ip.String()
+			problems = append(problems, problem)
+			continue
+		}
+		for _, subnet := range excluded {
+			if subnet.Contains(ip) {
+				problem := newAllocationProblem(ExcludedIP, fmt.Sprintf("IP %s is in the excluded range %s", ip, subnet))
+				problem.IP = ip.String()
+				problem.Repairable = false
+				problems = append(problems, problem)
+				break
+			}
+		}
+
+		allocationProblem := newAllocationProblem("", "")
+		allocationProblem.IP = ip.String()
+		allocationProblem.Repairable = false
+		allocations = append(allocations, allocationProblem)
+	}
+	return problems, allocations
+}
+
+// Repair returns a copy of the pool without the allocations of the repairable problems of the report, along with
+// the problems it repairs; the pool is returned unchanged when it has none.
+func Repair(pool *whereaboutsv1alpha1.IPPool, report Report) (*whereaboutsv1alpha1.IPPool, []Problem) {
+	var repaired []Problem
+	repairedPool := pool.DeepCopy()
+	for _, problem := range report.Problems {
+		if !problem.Repairable || problem.Namespace != pool.GetNamespace() || problem.Pool != pool.GetName() {
+			continue
+		}
+		if _, ok := repairedPool.Spec.Allocations[problem.Offset]; !ok {
+			continue
+		}
+		delete(repairedPool.Spec.Allocations, problem.Offset)
+		repaired = append(repaired, problem)
+	}
+	return repairedPool, repaired
+}
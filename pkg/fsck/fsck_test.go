@@ -0,0 +1,186 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package fsck
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	whereaboutsv1alpha1 "github.com/k8snetworkplumbingwg/whereabouts/pkg/api/whereabouts.cni.cncf.io/v1alpha1"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+func TestFsck(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "fsck")
+}
+
+func newPool(name, ipRange string, allocations map[string]whereaboutsv1alpha1.IPAllocation) whereaboutsv1alpha1.IPPool {
+	return whereaboutsv1alpha1.IPPool{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "kube-system"},
+		Spec:       whereaboutsv1alpha1.IPPoolSpec{Range: ipRange, Allocations: allocations},
+	}
+}
+
+func bareRange(pool *whereaboutsv1alpha1.IPPool) types.RangeConfiguration {
+	return types.RangeConfiguration{Range: pool.Spec.Range}
+}
+
+func problemTypes(report Report) map[string]ProblemType {
+	problems := map[string]ProblemType{}
+	for _, problem := range report.Problems {
+		problems[problem.Pool+"/"+problem.Offset] = problem.Type
+	}
+	return problems
+}
+
+var _ = Describe("IPPool consistency check", func() {
+	It("reports nothing for consistent pools", func() {
+		pools := []whereaboutsv1alpha1.IPPool{
+			newPool("10.0.0.0-24", "10.0.0.0/24", map[string]whereaboutsv1alpha1.IPAllocation{
+				"1":   {PodRef: "default/pod1", IfName: "eth0"},
+				"2":   {PodRef: "default/pod1", IfName: "net1"},
+				"254": {PodRef: "default/pod2", IfName: "eth0"},
+			}),
+			newPool("10.0.1.0-24", "10.0.1.0/24", map[string]whereaboutsv1alpha1.IPAllocation{
+				"1": {PodRef: "default/pod1", IfName: "eth0"},
+			}),
+		}
+
+		report := Check(pools, bareRange)
+		Expect(report.Pools).To(Equal(2))
+		Expect(report.Allocations).To(Equal(4))
+		Expect(report.Problems).To(BeEmpty())
+	})
+
+	It("reports the inconsistent allocations", func() {
+		pools := []whereaboutsv1alpha1.IPPool{
+			newPool("10.0.0.0-24", "10.0.0.0/24", map[string]whereaboutsv1alpha1.IPAllocation{
+				"one": {PodRef: "default/pod1", IfName: "eth0"},
+				"0":   {PodRef: "default/pod2", IfName: "eth0"},
+				"255": {PodRef: "default/pod3", IfName: "eth0"},
+				"-3":  {PodRef: "default/pod4", IfName: "eth0"},
+				"4":   {PodRef: "", IfName: "eth0"},
+				"5":   {PodRef: "default/pod5", IfName: "eth0"},
+				"6":   {PodRef: "default/pod6", IfName: "eth0"},
+				"7":   {PodRef: "default/pod6", IfName: "eth0"},
+			}),
+			newPool("invalid", "10.0.0.0/33", map[string]whereaboutsv1alpha1.IPAllocation{}),
+		}
+		rangeConfiguration := func(pool *whereaboutsv1alpha1.IPPool) types.RangeConfiguration {
+			return types.RangeConfiguration{Range: pool.Spec.Range, OmitRanges: []string{"10.0.0.4/31"}}
+		}
+
+		report := Check(pools, rangeConfiguration)
+		Expect(problemTypes(report)).To(Equal(map[string]ProblemType{
+			"10.0.0.0-24/one": InvalidOffset,
+			"10.0.0.0-24/0":   OffsetOutOfRange,
+			"10.0.0.0-24/255": OffsetOutOfRange,
+			"10.0.0.0-24/-3":  OffsetOutOfRange,
+			"10.0.0.0-24/4":   EmptyPodRef,
+			"10.0.0.0-24/5":   ExcludedIP,
+			"10.0.0.0-24/6":   DuplicateInterface,
+			"10.0.0.0-24/7":   DuplicateInterface,
+			"invalid/":        InvalidRange,
+		}))
+		for _, problem := range report.Problems {
+			Expect(problem.Repairable).To(Equal(problem.Type != DuplicateInterface && problem.Type != InvalidRange &&
+				problem.Type != ExcludedIP), "%+v", problem)
+			if problem.Type == ExcludedIP {
+				Expect(problem.IP).To(Equal("10.0.0.5"))
+				Expect(problem.PodRef).To(Equal("default/pod5"))
+			}
+		}
+	})
+
+	It("checks the high offsets of large IPv6 ranges", func() {
+		pools := []whereaboutsv1alpha1.IPPool{
+			newPool("fd00---64", "fd00::/64", map[string]whereaboutsv1alpha1.IPAllocation{
+				"9223372036854775813":  {PodRef: "default/pod1", IfName: "eth0"},
+				"18446744073709551614": {PodRef: "default/pod2", IfName: "eth0"},
+				"18446744073709551615": {PodRef: "default/pod3", IfName: "eth0"},
+			}),
+			newPool("fd01---48", "fd01::/48", map[string]whereaboutsv1alpha1.IPAllocation{
+				"18446744073709551615": {PodRef: "default/pod1", IfName: "eth0"},
+				"18446744073709551616": {PodRef: "default/pod2", IfName: "eth0"},
+			}),
+		}
+
+		report := Check(pools, bareRange)
+		Expect(problemTypes(report)).To(Equal(map[string]ProblemType{
+			"fd00---64/18446744073709551615": OffsetOutOfRange,
+			"fd01---48/18446744073709551616": OffsetOutOfRange,
+		}))
+	})
+
+	It("checks the shards of a pool together", func() {
+		shard := func(index int64, allocations map[string]whereaboutsv1alpha1.IPAllocation) whereaboutsv1alpha1.IPPool {
+			pool := newPool(fmt.Sprintf("10.0.0.0-24-shard-%d", index), "10.0.0.0/24", allocations)
+			pool.Spec.Shard = &whereaboutsv1alpha1.IPPoolShard{Parent: "10.0.0.0-24", Start: index * 64, End: (index + 1) * 64}
+			return pool
+		}
+		parent := newPool("10.0.0.0-24", "10.0.0.0/24", nil)
+		parent.Spec.ShardSize = 64
+		pools := []whereaboutsv1alpha1.IPPool{
+			parent,
+			shard(0, map[string]whereaboutsv1alpha1.IPAllocation{
+				"1":  {PodRef: "default/pod1", IfName: "eth0"},
+				"70": {PodRef: "default/pod2", IfName: "eth0"},
+			}),
+			shard(1, map[string]whereaboutsv1alpha1.IPAllocation{
+				"65": {PodRef: "default/pod1", IfName: "eth0"},
+			}),
+		}
+
+		Expect(problemTypes(Check(pools, bareRange))).To(Equal(map[string]ProblemType{
+			"10.0.0.0-24-shard-0/70": OffsetOutOfRange,
+			"10.0.0.0-24-shard-0/1":  DuplicateInterface,
+			"10.0.0.0-24-shard-1/65": DuplicateInterface,
+		}))
+	})
+
+	It("repairs the repairable problems of a pool", func() {
+		pools := []whereaboutsv1alpha1.IPPool{
+			newPool("10.0.0.0-24", "10.0.0.0/24", map[string]whereaboutsv1alpha1.IPAllocation{
+				"1":   {PodRef: "default/pod1", IfName: "eth0"},
+				"2":   {PodRef: "", IfName: "eth0"},
+				"300": {PodRef: "default/pod3", IfName: "eth0"},
+				"4":   {PodRef: "default/pod4", IfName: "eth0"},
+				"5":   {PodRef: "default/pod4", IfName: "eth0"},
+			}),
+			newPool("10.0.1.0-24", "10.0.1.0/24", map[string]whereaboutsv1alpha1.IPAllocation{
+				"2": {PodRef: "", IfName: "eth0"},
+			}),
+		}
+		report := Check(pools, bareRange)
+
+		repairedPool, repaired := Repair(&pools[0], report)
+		Expect(repaired).To(HaveLen(2))
+		Expect(repairedPool.Spec.Allocations).To(HaveLen(3))
+		Expect(repairedPool.Spec.Allocations).To(HaveKey("1"))
+		Expect(repairedPool.Spec.Allocations).To(HaveKey("4"))
+		Expect(repairedPool.Spec.Allocations).To(HaveKey("5"))
+		Expect(pools[0].Spec.Allocations).To(HaveLen(5))
+
+		Expect(Check([]whereaboutsv1alpha1.IPPool{*repairedPool}, bareRange).Problems).To(HaveLen(2))
+	})
+})
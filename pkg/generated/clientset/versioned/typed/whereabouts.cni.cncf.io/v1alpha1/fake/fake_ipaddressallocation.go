@@ -0,0 +1,51 @@
+/*
+Copyright 2025 The Kubernetes Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1alpha1 "github.com/k8snetworkplumbingwg/whereabouts/pkg/api/whereabouts.cni.cncf.io/v1alpha1"
+	whereaboutscnicncfiov1alpha1 "github.com/k8snetworkplumbingwg/whereabouts/pkg/generated/clientset/versioned/typed/whereabouts.cni.cncf.io/v1alpha1"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// fakeIPAddressAllocations implements IPAddressAllocationInterface
+type fakeIPAddressAllocations struct {
+	*gentype.FakeClientWithList[*v1alpha1.IPAddressAllocation, *v1alpha1.IPAddressAllocationList]
+	Fake *FakeWhereaboutsV1alpha1
+}
+
+func newFakeIPAddressAllocations(fake *FakeWhereaboutsV1alpha1, namespace string) whereaboutscnicncfiov1alpha1.IPAddressAllocationInterface {
+	return &fakeIPAddressAllocations{
+		gentype.NewFakeClientWithList[*v1alpha1.IPAddressAllocation, *v1alpha1.IPAddressAllocationList](
+			fake.Fake,
+			namespace,
+			v1alpha1.SchemeGroupVersion.WithResource("ipaddressallocations"),
+			v1alpha1.SchemeGroupVersion.WithKind("IPAddressAllocation"),
+			func() *v1alpha1.IPAddressAllocation { return &v1alpha1.IPAddressAllocation{} },
+			func() *v1alpha1.IPAddressAllocationList { return &v1alpha1.IPAddressAllocationList{} },
+			func(dst, src *v1alpha1.IPAddressAllocationList) { dst.ListMeta = src.ListMeta },
+			func(list *v1alpha1.IPAddressAllocationList) []*v1alpha1.IPAddressAllocation {
+				return gentype.ToPointerSlice(list.Items)
+			},
+			func(list *v1alpha1.IPAddressAllocationList, items []*v1alpha1.IPAddressAllocation) {
+				list.Items = gentype.FromPointerSlice(items)
+			},
+		),
+		fake,
+	}
+}
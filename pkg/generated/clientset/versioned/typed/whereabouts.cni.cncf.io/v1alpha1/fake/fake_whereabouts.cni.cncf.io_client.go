@@ -27,6 +27,10 @@ type FakeWhereaboutsV1alpha1 struct {
 	*testing.Fake
 }
 
+func (c *FakeWhereaboutsV1alpha1) IPAddressAllocations(namespace string) v1alpha1.IPAddressAllocationInterface {
+	return newFakeIPAddressAllocations(c, namespace)
+}
+
 func (c *FakeWhereaboutsV1alpha1) IPPools(namespace string) v1alpha1.IPPoolInterface {
 	return newFakeIPPools(c, namespace)
 }
@@ -17,6 +17,8 @@ limitations under the License.
 
 package v1alpha1
 
+type IPAddressAllocationExpansion interface{}
+
 type IPPoolExpansion interface{}
 
 type NodeSlicePoolExpansion interface{}
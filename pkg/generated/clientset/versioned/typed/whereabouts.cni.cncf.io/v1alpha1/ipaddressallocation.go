@@ -0,0 +1,71 @@
+/*
+Copyright 2025 The Kubernetes Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	context "context"
+
+	whereaboutscnicncfiov1alpha1 "github.com/k8snetworkplumbingwg/whereabouts/pkg/api/whereabouts.cni.cncf.io/v1alpha1"
+	scheme "github.com/k8snetworkplumbingwg/whereabouts/pkg/generated/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// IPAddressAllocationsGetter has a method to return a IPAddressAllocationInterface.
+// A group's client should implement this interface.
+type IPAddressAllocationsGetter interface {
+	IPAddressAllocations(namespace string) IPAddressAllocationInterface
+}
+
+// IPAddressAllocationInterface has methods to work with IPAddressAllocation resources.
+type IPAddressAllocationInterface interface {
+	Create(ctx context.Context, iPAddressAllocation *whereaboutscnicncfiov1alpha1.IPAddressAllocation, opts v1.CreateOptions) (*whereaboutscnicncfiov1alpha1.IPAddressAllocation, error)
+	Update(ctx context.Context, iPAddressAllocation *whereaboutscnicncfiov1alpha1.IPAddressAllocation, opts v1.UpdateOptions) (*whereaboutscnicncfiov1alpha1.IPAddressAllocation, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*whereaboutscnicncfiov1alpha1.IPAddressAllocation, error)
+	List(ctx context.Context, opts v1.ListOptions) (*whereaboutscnicncfiov1alpha1.IPAddressAllocationList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *whereaboutscnicncfiov1alpha1.IPAddressAllocation, err error)
+	IPAddressAllocationExpansion
+}
+
+// iPAddressAllocations implements IPAddressAllocationInterface
+type iPAddressAllocations struct {
+	*gentype.ClientWithList[*whereaboutscnicncfiov1alpha1.IPAddressAllocation, *whereaboutscnicncfiov1alpha1.IPAddressAllocationList]
+}
+
+// newIPAddressAllocations returns a IPAddressAllocations
+func newIPAddressAllocations(c *WhereaboutsV1alpha1Client, namespace string) *iPAddressAllocations {
+	return &iPAddressAllocations{
+		gentype.NewClientWithList[*whereaboutscnicncfiov1alpha1.IPAddressAllocation, *whereaboutscnicncfiov1alpha1.IPAddressAllocationList](
+			"ipaddressallocations",
+			c.RESTClient(),
+			scheme.ParameterCodec,
+			namespace,
+			func() *whereaboutscnicncfiov1alpha1.IPAddressAllocation {
+				return &whereaboutscnicncfiov1alpha1.IPAddressAllocation{}
+			},
+			func() *whereaboutscnicncfiov1alpha1.IPAddressAllocationList {
+				return &whereaboutscnicncfiov1alpha1.IPAddressAllocationList{}
+			},
+		),
+	}
+}
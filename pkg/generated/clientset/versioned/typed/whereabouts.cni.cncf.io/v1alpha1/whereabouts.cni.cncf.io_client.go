@@ -27,6 +27,7 @@ import (
 
 type WhereaboutsV1alpha1Interface interface {
 	RESTClient() rest.Interface
+	IPAddressAllocationsGetter
 	IPPoolsGetter
 	NodeSlicePoolsGetter
 	OverlappingRangeIPReservationsGetter
@@ -37,6 +38,10 @@ type WhereaboutsV1alpha1Client struct {
 	restClient rest.Interface
 }
 
+func (c *WhereaboutsV1alpha1Client) IPAddressAllocations(namespace string) IPAddressAllocationInterface {
+	return newIPAddressAllocations(c, namespace)
+}
+
 func (c *WhereaboutsV1alpha1Client) IPPools(namespace string) IPPoolInterface {
 	return newIPPools(c, namespace)
 }
@@ -23,6 +23,8 @@ import (
 
 // Interface provides access to all the informers in this group version.
 type Interface interface {
+	// IPAddressAllocations returns a IPAddressAllocationInformer.
+	IPAddressAllocations() IPAddressAllocationInformer
 	// IPPools returns a IPPoolInformer.
 	IPPools() IPPoolInformer
 	// NodeSlicePools returns a NodeSlicePoolInformer.
@@ -42,6 +44,11 @@ func New(f internalinterfaces.SharedInformerFactory, namespace string, tweakList
 	return &version{factory: f, namespace: namespace, tweakListOptions: tweakListOptions}
 }
 
+// IPAddressAllocations returns a IPAddressAllocationInformer.
+func (v *version) IPAddressAllocations() IPAddressAllocationInformer {
+	return &iPAddressAllocationInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
 // IPPools returns a IPPoolInformer.
 func (v *version) IPPools() IPPoolInformer {
 	return &iPPoolInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
@@ -0,0 +1,69 @@
+/*
+Copyright 2025 The Kubernetes Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	whereaboutscnicncfiov1alpha1 "github.com/k8snetworkplumbingwg/whereabouts/pkg/api/whereabouts.cni.cncf.io/v1alpha1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	listers "k8s.io/client-go/listers"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// IPAddressAllocationLister helps list IPAddressAllocations.
+// All objects returned here must be treated as read-only.
+type IPAddressAllocationLister interface {
+	// List lists all IPAddressAllocations in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*whereaboutscnicncfiov1alpha1.IPAddressAllocation, err error)
+	// IPAddressAllocations returns an object that can list and get IPAddressAllocations.
+	IPAddressAllocations(namespace string) IPAddressAllocationNamespaceLister
+	IPAddressAllocationListerExpansion
+}
+
+// iPAddressAllocationLister implements the IPAddressAllocationLister interface.
+type iPAddressAllocationLister struct {
+	listers.ResourceIndexer[*whereaboutscnicncfiov1alpha1.IPAddressAllocation]
+}
+
+// NewIPAddressAllocationLister returns a new IPAddressAllocationLister.
+func NewIPAddressAllocationLister(indexer cache.Indexer) IPAddressAllocationLister {
+	return &iPAddressAllocationLister{listers.New[*whereaboutscnicncfiov1alpha1.IPAddressAllocation](indexer, whereaboutscnicncfiov1alpha1.Resource("ipaddressallocation"))}
+}
+
+// IPAddressAllocations returns an object that can list and get IPAddressAllocations.
+func (s *iPAddressAllocationLister) IPAddressAllocations(namespace string) IPAddressAllocationNamespaceLister {
+	return iPAddressAllocationNamespaceLister{listers.NewNamespaced[*whereaboutscnicncfiov1alpha1.IPAddressAllocation](s.ResourceIndexer, namespace)}
+}
+
+// IPAddressAllocationNamespaceLister helps list and get IPAddressAllocations.
+// All objects returned here must be treated as read-only.
+type IPAddressAllocationNamespaceLister interface {
+	// List lists all IPAddressAllocations in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*whereaboutscnicncfiov1alpha1.IPAddressAllocation, err error)
+	// Get retrieves the IPAddressAllocation from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*whereaboutscnicncfiov1alpha1.IPAddressAllocation, error)
+	IPAddressAllocationNamespaceListerExpansion
+}
+
+// iPAddressAllocationNamespaceLister implements the IPAddressAllocationNamespaceLister
+// interface.
+type iPAddressAllocationNamespaceLister struct {
+	listers.ResourceIndexer[*whereaboutscnicncfiov1alpha1.IPAddressAllocation]
+}
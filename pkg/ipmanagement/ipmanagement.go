@@ -0,0 +1,374 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package ipmanagement implements the allocation and the release of the IPs of a pod interface, independently of
+// the storage backend holding the pools.
+package ipmanagement
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/allocate"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/api/whereabouts.cni.cncf.io/v1alpha1"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/iphelpers"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/logging"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+// Manage allocates, or releases, the IPs of the given pod interface in every range of ipamConf, reading and
+// updating the pools held by store. The caller is responsible for serializing the concurrent updates of the pools,
+// when the store requires it.
+func Manage(ctx context.Context, mode int, store storage.Store, ipamConf types.IPAMConfig, containerID, ifName string) ([]net.IPNet, error) {
+	logging.Debugf("IPManagement -- mode: %d / containerID: %q / podRef: %q / ifName: %q ", mode, containerID, ipamConf.GetPodRef(), ifName)
+
+	var newips []net.IPNet
+	var newip net.IPNet
+	// Skip invalid modes
+	switch mode {
+	case types.Allocate, types.Deallocate:
+	default:
+		return newips, fmt.Errorf("got an unknown mode passed to IPManagement: %v", mode)
+	}
+
+	var overlappingrangestore storage.OverlappingRangeStore
+	var pool storage.IPPool
+	var err error
+
+	requestCtx, requestCancel := context.WithTimeout(ctx, storage.RequestTimeout)
+	defer requestCancel()
+
+	// Check our connectivity first
+	if err := store.Status(requestCtx); err != nil {
+		logging.Errorf("IPAM connectivity error: %v", err)
+		return newips, err
+	}
+
+	// handle the ip add/del until successful
+	var overlappingrangeallocations []types.IPReservation
+	for _, ipRange := range ipamConf.IPRanges {
+		poolIdentifier := storage.PoolIdentifier{IpRange: ipRange.Range, NetworkName: ipamConf.NetworkName}
+		if ipamConf.NodeSliceSize != "" {
+			nodeSliceStore, ok := store.(storage.NodeSliceStore)
+			if !ok {
+				return newips, &storage.InvalidConfigError{Err: fmt.Errorf("the storage backend does not support node slices")}
+			}
+			hostname, nodeSliceRange, err := nodeSliceStore.NodeSliceRange(ctx)
+			if err != nil {
+				return newips, err
+			}
+			poolIdentifier.NodeName = hostname
+			_, ipNet, err := net.ParseCIDR(nodeSliceRange)
+			if err != nil {
+				logging.Errorf("Error parsing node slice cidr to net.IPNet: %v", err)
+				return newips, &storage.InvalidConfigError{Err: err}
+			}
+			poolIdentifier.IpRange = nodeSliceRange
+			rangeStart, err := iphelpers.FirstUsableIP(*ipNet)
+			if err != nil {
+				logging.Errorf("Error parsing node slice cidr to range start: %v", err)
+				return newips, err
+			}
+			rangeEnd, err := iphelpers.LastUsableIP(*ipNet)
+			if err != nil {
+				logging.Errorf("Error parsing node slice cidr to range start: %v", err)
+				return newips, err
+			}
+			ipRange = types.RangeConfiguration{
+				Range:      ipRange.Range,
+				RangeStart: rangeStart,
+				RangeEnd:   rangeEnd,
+			}
+		}
+		logging.Debugf("using pool identifier: %v", poolIdentifier)
+
+		// the retries of the conflicting reads and updates back off, until the deadline of the CNI request
+		retry := storage.DefaultRetryPolicy().NewRetrier(ctx)
+		var ipforoverlappingrangeupdate net.IP
+		var overlappingrangetransfer *v1alpha1.OverlappingRangeIPReservation
+		var staticoverlappingrangeips []net.IP
+		var staticoverlappingrangetransfers []overlappingRangeTransfer
+		skipOverlappingRangeUpdate := false
+	RETRYLOOP:
+		for retry.Next() {
+			// the overlapping range reservation is decided anew for the pool read by every attempt
+			ipforoverlappingrangeupdate = nil
+			overlappingrangetransfer = nil
+			staticoverlappingrangeips, staticoverlappingrangetransfers = nil, nil
+			skipOverlappingRangeUpdate = false
+			overlappingrangestore, err = store.GetOverlappingRangeStore()
+			if err != nil {
+				logging.Errorf("IPAM error getting OverlappingRangeStore: %v", err)
+				return newips, err
+			}
+			pool, err = store.GetIPPool(requestCtx, poolIdentifier)
+			if err != nil {
+				logging.Errorf("IPAM error reading pool allocations (attempt: %d): %v", retry.Attempts(), err)
+				if e, ok := err.(storage.Temporary); ok && e.Temporary() {
+					retry.Conflict()
+					continue
+				}
+				return newips, err
+			}
+
+			reservelist := releaseOverlappingRangeConflicts(pool.Allocations(), overlappingrangeallocations, ipamConf.GetPodRef(), ifName)
+			reservelist = append(reservelist, overlappingrangeallocations...)
+			staticIPs := allocate.StaticIPsInRange(ipRange, ipamConf.Addresses)
+			var updatedreservelist []types.IPReservation
+			switch mode {
+			case types.Allocate:
+				reservelist, err = allocate.ReserveStaticIPs(reservelist, staticIPs, containerID, ipamConf.GetPodRef(), ifName)
+				if err != nil {
+					logging.Errorf("Error reserving static IPs: %v", err)
+					return newips, err
+				}
+				if ipamConf.OverlappingRanges {
+					staticoverlappingrangeips, staticoverlappingrangetransfers, err = checkStaticOverlappingRanges(requestCtx,
+						overlappingrangestore, staticIPs, containerID, ipamConf.GetPodRef(), ifName, ipamConf.NetworkName)
+					if err != nil {
+						logging.Errorf("Error checking the overlapping range reservations of the static IPs: %v", err)
+						return newips, err
+					}
+				}
+				if ipamConf.OptimisticConcurrency {
+					// start from a random offset, so that concurrent allocators rarely compete for the same IP
+					newip, updatedreservelist, err = allocate.AssignIPFromOffset(ipRange, reservelist, containerID, ipamConf.GetPodRef(), ifName, rand.Uint64())
+				} else {
+					newip, updatedreservelist, err = allocate.AssignIP(ipRange, reservelist, containerID, ipamConf.GetPodRef(), ifName)
+				}
+				if err != nil {
+					logging.Errorf("Error assigning IP: %v", err)
+					return newips, err
+				}
+				// Now check if this is allocated overlappingrange wide
+				// When it's allocated overlappingrange wide, we add it to a local reserved list
+				// And we try again.
+				if ipamConf.OverlappingRanges {
+					overlappingRangeIPReservation, err := overlappingrangestore.GetOverlappingRangeIPReservation(requestCtx, newip.IP,
+						ipamConf.GetPodRef(), ipamConf.NetworkName)
+					if err != nil {
+						logging.Errorf("Error getting cluster wide IP allocation: %v", err)
+						return newips, err
+					}
+
+					if overlappingRangeIPReservation != nil {
+						if overlappingRangeIPReservation.Spec.PodRef != ipamConf.GetPodRef() {
+							logging.Debugf("Continuing loop, IP is already allocated (possibly from another range): %v", newip)
+							// We create "dummy" records here for evaluation, but, we need to filter those out later.
+							overlappingrangeallocations = append(overlappingrangeallocations, types.IPReservation{IP: newip.IP, IsAllocated: true})
+							continue
+						}
+
+						skipOverlappingRangeUpdate = true
+						if overlappingRangeIPReservation.Spec.ContainerID != containerID || overlappingRangeIPReservation.Spec.IfName != ifName {
+							// the pod allocates its IP again from another container, whose release is to find the
+							// reservation
+							overlappingrangetransfer = overlappingRangeIPReservation
+						}
+					}
+
+					ipforoverlappingrangeupdate = newip.IP
+				}
+
+			case types.Deallocate:
+				var releasedStaticIPs []net.IP
+				reservelist, releasedStaticIPs = allocate.ReleaseStaticIPs(reservelist, staticIPs, containerID, ifName)
+				staticoverlappingrangeips = releasedStaticIPs
+				updatedreservelist, ipforoverlappingrangeupdate = allocate.DeallocateIP(reservelist, containerID, ifName)
+				if ipforoverlappingrangeupdate == nil {
+					if len(releasedStaticIPs) == 0 {
+						// Do not fail if allocation was not found.
+						logging.Debugf("Failed to find allocation for container ID: %s", containerID)
+						return nil, nil
+					}
+					// only static IPs were reserved in this range; there is no overlapping range reservation to drop.
+					skipOverlappingRangeUpdate = true
+				}
+			}
+
+			// Clean out any dummy records from the reservelist...
+			var usereservelist []types.IPReservation
+			for _, rl := range updatedreservelist {
+				if !rl.IsAllocated {
+					usereservelist = append(usereservelist, rl)
+				}
+			}
+
+			// Manual race condition testing
+			if ipamConf.SleepForRace > 0 {
+				time.Sleep(time.Duration(ipamConf.SleepForRace) * time.Second)
+			}
+
+			err = pool.Update(requestCtx, usereservelist)
+			var elsewhereErr *storage.IPReservedElsewhereError
+			if mode == types.Allocate && errors.As(err, &elsewhereErr) {
+				// the IP is held outside of the pool, which the store only tells when creating its reservation: the
+				// next attempt leaves it out, and allocates another IP instead
+				logging.Debugf("IP %v is reserved outside of the pool, allocating another one", elsewhereErr.IP)
+				overlappingrangeallocations = append(overlappingrangeallocations, types.IPReservation{IP: elsewhereErr.IP, IsAllocated: true})
+				continue
+			}
+			if err != nil {
+				logging.Errorf("IPAM error updating pool (attempt: %d): %v", retry.Attempts(), err)
+				if e, ok := err.(storage.Temporary); ok && e.Temporary() {
+					retry.Conflict()
+					continue
+				}
+				break RETRYLOOP
+			}
+
+			if ipamConf.OverlappingRanges && !skipOverlappingRangeUpdate {
+				err = overlappingrangestore.UpdateOverlappingRangeAllocation(requestCtx, mode, ipforoverlappingrangeupdate, containerID,
+					ipamConf.GetPodRef(), ifName, ipamConf.NetworkName)
+				var reservedErr *storage.OverlappingRangeReservedError
+				if mode == types.Allocate && errors.As(err, &reservedErr) {
+					// another pod reserved the IP from an overlapping range since it was checked: the next attempt
+					// releases it from the pool, and allocates another IP instead
+					logging.Debugf("IP %v was reserved from an overlapping range meanwhile, allocating another one", newip)
+					overlappingrangeallocations = append(overlappingrangeallocations, types.IPReservation{IP: newip.IP, IsAllocated: true})
+					continue
+				}
+				if err != nil {
+					logging.Errorf("Error performing UpdateOverlappingRangeAllocation: %v", err)
+					return newips, err
+				}
+			}
+			if ipamConf.OverlappingRanges && overlappingrangetransfer != nil {
+				err = overlappingrangestore.TransferOverlappingRangeReservation(requestCtx, overlappingrangetransfer, newip.IP, containerID,
+					ifName, ipamConf.NetworkName)
+				if err != nil {
+					logging.Errorf("Error transferring the overlapping range reservation (attempt: %d): %v", retry.Attempts(), err)
+					if e, ok := err.(storage.Temporary); ok && e.Temporary() {
+						retry.Conflict()
+						continue
+					}
+					return newips, err
+				}
+			}
+			if ipamConf.OverlappingRanges {
+				err = updateStaticOverlappingRanges(requestCtx, overlappingrangestore, mode, staticoverlappingrangeips,
+					staticoverlappingrangetransfers, containerID, ipamConf.GetPodRef(), ifName, ipamConf.NetworkName)
+				var reservedErr *storage.OverlappingRangeReservedError
+				if errors.As(err, &reservedErr) {
+					// another pod reserved a static IP from an overlapping range since it was checked: the next
+					// attempt reports the conflict
+					logging.Debugf("Static IP %v was reserved from an overlapping range meanwhile", reservedErr.IP)
+					retry.Conflict()
+					continue
+				}
+				if err != nil {
+					logging.Errorf("Error updating the overlapping range reservations of the static IPs (attempt: %d): %v", retry.Attempts(), err)
+					if e, ok := err.(storage.Temporary); ok && e.Temporary() {
+						retry.Conflict()
+						continue
+					}
+					return newips, err
+				}
+			}
+			break RETRYLOOP
+		}
+		logging.Debugf("pool %v: %d attempt(s), %d conflict(s)", poolIdentifier, retry.Attempts(), retry.Conflicts())
+
+		if err == nil {
+			err = retry.Err()
+		}
+		if err != nil {
+			if e, ok := err.(storage.Temporary); (ok && e.Temporary()) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, storage.ErrRetriesExhausted) {
+				err = &storage.UpdateConflictError{Err: err, Attempts: retry.Attempts(), Conflicts: retry.Conflicts()}
+			}
+			return newips, err
+		}
+
+		newips = append(newips, newip)
+	}
+	return newips, err
+}
+
+// releaseOverlappingRangeConflicts drops, from the reservations of a pool, those of the pod interface for the IPs
+// found reserved from an overlapping range after they were allocated.
+func releaseOverlappingRangeConflicts(reservelist, overlappingrangeallocations []types.IPReservation, podRef, ifName string) []types.IPReservation {
+	var kept []types.IPReservation
+	for _, r := range reservelist {
+		if r.PodRef == podRef && r.IfName == ifName && !r.IsStatic && slices.ContainsFunc(overlappingrangeallocations, func(o types.IPReservation) bool {
+			return o.IP.Equal(r.IP)
+		}) {
+			logging.Debugf("Releasing IP %v, reserved from an overlapping range by another pod", r.IP)
+			continue
+		}
+		kept = append(kept, r)
+	}
+	return kept
+}
+
+// overlappingRangeTransfer is an overlapping range reservation of the pod to hand over to its current container
+// interface.
+type overlappingRangeTransfer struct {
+	ip          net.IP
+	reservation *v1alpha1.OverlappingRangeIPReservation
+}
+
+// checkStaticOverlappingRanges reads the overlapping range reservations of the static IPs of a pod interface. It fails
+// with a ConflictError when another pod holds one of them, and otherwise returns the IPs to reserve, along with the
+// reservations of the pod to hand over to the container interface.
+func checkStaticOverlappingRanges(ctx context.Context, store storage.OverlappingRangeStore, staticIPs []net.IP,
+	containerID, podRef, ifName, networkName string) ([]net.IP, []overlappingRangeTransfer, error) {
+	var reserve []net.IP
+	var transfers []overlappingRangeTransfer
+	for _, ip := range staticIPs {
+		reservation, err := store.GetOverlappingRangeIPReservation(ctx, ip, podRef, networkName)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch {
+		case reservation == nil:
+			reserve = append(reserve, ip)
+		case reservation.Spec.PodRef != podRef:
+			return nil, nil, allocate.ConflictError{IP: ip, PodRef: reservation.Spec.PodRef, IfName: reservation.Spec.IfName}
+		case reservation.Spec.ContainerID != containerID || reservation.Spec.IfName != ifName:
+			transfers = append(transfers, overlappingRangeTransfer{ip: ip, reservation: reservation})
+		}
+	}
+	return reserve, transfers, nil
+}
+
+// updateStaticOverlappingRanges reserves, or releases, the overlapping range reservations of the static IPs of a pod
+// interface, and hands over those of the pod to the container interface. The releases of missing reservations are
+// ignored, as the static IPs reserved before they were held in overlapping ranges have none.
+func updateStaticOverlappingRanges(ctx context.Context, store storage.OverlappingRangeStore, mode int, ips []net.IP,
+	transfers []overlappingRangeTransfer, containerID, podRef, ifName, networkName string) error {
+	for _, ip := range ips {
+		err := store.UpdateOverlappingRangeAllocation(ctx, mode, ip, containerID, podRef, ifName, networkName)
+		if mode == types.Deallocate && errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, transfer := range transfers {
+		err := store.TransferOverlappingRangeReservation(ctx, transfer.reservation, transfer.ip, containerID, ifName, networkName)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
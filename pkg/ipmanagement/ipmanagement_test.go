@@ -0,0 +1,274 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ipmanagement
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/allocate"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/api/whereabouts.cni.cncf.io/v1alpha1"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage/memory"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+func TestIPManagement(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "IP management")
+}
+
+var _ = Describe("Backend-neutral IP management", func() {
+	const (
+		containerID = "container1"
+		ifName      = "net1"
+	)
+
+	var store *memory.Store
+
+	ipamConfig := func(ranges ...string) types.IPAMConfig {
+		ipamConf := types.IPAMConfig{PodName: "pod1", PodNamespace: "default"}
+		for _, ipRange := range ranges {
+			ipamConf.IPRanges = append(ipamConf.IPRanges, types.RangeConfiguration{Range: ipRange})
+		}
+		return ipamConf
+	}
+
+	BeforeEach(func() {
+		store = memory.New()
+	})
+
+	It("allocates an IP from every range, then releases them", func() {
+		ipamConf := ipamConfig("192.168.0.0/24", "10.0.0.0/24")
+
+		ips, err := Manage(context.Background(), types.Allocate, store, ipamConf, containerID, ifName)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ips).To(HaveLen(2))
+		Expect(ips[0].IP.String()).To(Equal("192.168.0.1"))
+		Expect(ips[1].IP.String()).To(Equal("10.0.0.1"))
+		Expect(store.Allocations(storage.PoolIdentifier{IpRange: "192.168.0.0/24"})).To(HaveLen(1))
+		Expect(store.Allocations(storage.PoolIdentifier{IpRange: "10.0.0.0/24"})).To(HaveLen(1))
+
+		_, err = Manage(context.Background(), types.Deallocate, store, ipamConf, containerID, ifName)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(store.Allocations(storage.PoolIdentifier{IpRange: "192.168.0.0/24"})).To(BeEmpty())
+		Expect(store.Allocations(storage.PoolIdentifier{IpRange: "10.0.0.0/24"})).To(BeEmpty())
+	})
+
+	It("retries the conflicting pool updates", func() {
+		store.InjectFault(memory.OpUpdatePool, memory.FaultConflict, 3)
+
+		ips, err := Manage(context.Background(), types.Allocate, store, ipamConfig("192.168.0.0/24"), containerID, ifName)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ips).To(HaveLen(1))
+		Expect(store.Calls(memory.OpUpdatePool)).To(Equal(4))
+	})
+
+	It("reports an exhausted range", func() {
+		store.SetAllocations(storage.PoolIdentifier{IpRange: "192.168.0.0/30"}, []types.IPReservation{
+			{IP: net.ParseIP("192.168.0.1"), PodRef: "default/pod2"},
+			{IP: net.ParseIP("192.168.0.2"), PodRef: "default/pod3"},
+		})
+
+		_, err := Manage(context.Background(), types.Allocate, store, ipamConfig("192.168.0.0/30"), containerID, ifName)
+		var assignmentErr allocate.AssignmentError
+		Expect(errors.As(err, &assignmentErr)).To(BeTrue())
+	})
+
+	It("skips the IPs reserved from an overlapping range", func() {
+		ipamConf := ipamConfig("192.168.0.0/24")
+		ipamConf.OverlappingRanges = true
+		Expect(store.UpdateOverlappingRangeAllocation(context.Background(), types.Allocate, net.ParseIP("192.168.0.1"), "container2", "default/pod2", ifName, "")).To(Succeed())
+
+		ips, err := Manage(context.Background(), types.Allocate, store, ipamConf, containerID, ifName)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ips[0].IP.String()).To(Equal("192.168.0.2"))
+		Expect(store.OverlappingRangeReservations()).To(HaveKeyWithValue("/192.168.0.2", v1alpha1.OverlappingRangeIPReservationSpec{ContainerID: containerID, PodRef: "default/pod1", IfName: ifName}))
+	})
+
+	It("hands the overlapping range reservation over to the container allocating the IP of the pod again", func() {
+		ipamConf := ipamConfig("192.168.0.0/24")
+		ipamConf.OverlappingRanges = true
+
+		_, err := Manage(context.Background(), types.Allocate, store, ipamConf, containerID, ifName)
+		Expect(err).NotTo(HaveOccurred())
+		ips, err := Manage(context.Background(), types.Allocate, store, ipamConf, "container2", ifName)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ips[0].IP.String()).To(Equal("192.168.0.1"))
+		Expect(store.OverlappingRangeReservations()).To(HaveKeyWithValue("/192.168.0.1", v1alpha1.OverlappingRangeIPReservationSpec{ContainerID: "container2", PodRef: "default/pod1", IfName: ifName}))
+
+		_, err = Manage(context.Background(), types.Deallocate, store, ipamConf, "container2", ifName)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(store.Allocations(storage.PoolIdentifier{IpRange: "192.168.0.0/24"})).To(BeEmpty())
+		Expect(store.OverlappingRangeReservations()).To(BeEmpty())
+	})
+
+	It("reads the overlapping range reservation again when it changes while being handed over", func() {
+		ipamConf := ipamConfig("192.168.0.0/24")
+		ipamConf.OverlappingRanges = true
+
+		_, err := Manage(context.Background(), types.Allocate, store, ipamConf, containerID, ifName)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = Manage(context.Background(), types.Allocate, changingStore{store}, ipamConf, "container2", ifName)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(store.Calls(memory.OpUpdateOverlappingRange)).To(Equal(4))
+		Expect(store.OverlappingRangeReservations()).To(HaveKeyWithValue("/192.168.0.1", v1alpha1.OverlappingRangeIPReservationSpec{ContainerID: "container2", PodRef: "default/pod1", IfName: ifName}))
+	})
+
+	It("allocates another IP when the IP is reserved from an overlapping range meanwhile", func() {
+		ipamConf := ipamConfig("192.168.0.0/24")
+		ipamConf.OverlappingRanges = true
+
+		ips, err := Manage(context.Background(), types.Allocate, racingStore{store}, ipamConf, containerID, ifName)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ips[0].IP.String()).To(Equal("192.168.0.2"))
+		allocations := store.Allocations(storage.PoolIdentifier{IpRange: "192.168.0.0/24"})
+		Expect(allocations).To(HaveLen(1))
+		Expect(allocations[0].IP.String()).To(Equal("192.168.0.2"))
+		Expect(store.OverlappingRangeReservations()).To(Equal(map[string]v1alpha1.OverlappingRangeIPReservationSpec{
+			"/192.168.0.1": {ContainerID: "container2", PodRef: "default/pod2", IfName: ifName},
+			"/192.168.0.2": {ContainerID: containerID, PodRef: "default/pod1", IfName: ifName},
+		}))
+	})
+
+	It("releases the overlapping range reservations of the ranges following a range of static IPs", func() {
+		ipamConf := ipamConfig("192.168.0.0/24", "10.0.0.0/24")
+		ipamConf.OverlappingRanges = true
+		staticIP := net.IPNet{IP: net.ParseIP("192.168.0.10"), Mask: net.CIDRMask(24, 32)}
+		ipamConf.Addresses = []types.Address{{AddressStr: "192.168.0.10/24", Address: staticIP}}
+		store.SetAllocations(storage.PoolIdentifier{IpRange: "192.168.0.0/24"}, []types.IPReservation{
+			{IP: staticIP.IP, ContainerID: containerID, PodRef: "default/pod1", IfName: ifName, IsStatic: true},
+		})
+		store.SetAllocations(storage.PoolIdentifier{IpRange: "10.0.0.0/24"}, []types.IPReservation{
+			{IP: net.ParseIP("10.0.0.1"), ContainerID: containerID, PodRef: "default/pod1", IfName: ifName},
+		})
+		Expect(store.UpdateOverlappingRangeAllocation(context.Background(), types.Allocate, net.ParseIP("10.0.0.1"), containerID, "default/pod1", ifName, "")).To(Succeed())
+
+		_, err := Manage(context.Background(), types.Deallocate, store, ipamConf, containerID, ifName)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(store.Allocations(storage.PoolIdentifier{IpRange: "192.168.0.0/24"})).To(BeEmpty())
+		Expect(store.Allocations(storage.PoolIdentifier{IpRange: "10.0.0.0/24"})).To(BeEmpty())
+		Expect(store.OverlappingRangeReservations()).To(BeEmpty())
+	})
+
+	It("reserves the static IPs in the overlapping ranges", func() {
+		staticIP := net.IPNet{IP: net.ParseIP("192.168.0.10"), Mask: net.CIDRMask(24, 32)}
+		ipamConf := ipamConfig("192.168.0.0/24")
+		ipamConf.OverlappingRanges = true
+		ipamConf.Addresses = []types.Address{{AddressStr: "192.168.0.10/24", Address: staticIP}}
+
+		_, err := Manage(context.Background(), types.Allocate, store, ipamConf, containerID, ifName)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(store.OverlappingRangeReservations()).To(HaveKeyWithValue("/192.168.0.10", v1alpha1.OverlappingRangeIPReservationSpec{ContainerID: containerID, PodRef: "default/pod1", IfName: ifName}))
+
+		// another pod requesting the static IP from an overlapping range is refused
+		otherConf := ipamConfig("192.168.0.0/25")
+		otherConf.PodName = "pod2"
+		otherConf.OverlappingRanges = true
+		otherConf.Addresses = ipamConf.Addresses
+		_, err = Manage(context.Background(), types.Allocate, store, otherConf, "container2", ifName)
+		var conflict allocate.ConflictError
+		Expect(errors.As(err, &conflict)).To(BeTrue())
+		Expect(conflict.PodRef).To(Equal("default/pod1"))
+		Expect(store.Allocations(storage.PoolIdentifier{IpRange: "192.168.0.0/25"})).To(BeEmpty())
+
+		_, err = Manage(context.Background(), types.Deallocate, store, ipamConf, containerID, ifName)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(store.Allocations(storage.PoolIdentifier{IpRange: "192.168.0.0/24"})).To(BeEmpty())
+		Expect(store.OverlappingRangeReservations()).To(BeEmpty())
+
+		_, err = Manage(context.Background(), types.Allocate, store, otherConf, "container2", ifName)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(store.OverlappingRangeReservations()).To(HaveKeyWithValue("/192.168.0.10", v1alpha1.OverlappingRangeIPReservationSpec{ContainerID: "container2", PodRef: "default/pod2", IfName: ifName}))
+	})
+
+	It("allocates from the node slice of the node", func() {
+		store.SetNodeSlice("node1", "192.168.1.0/24")
+		ipamConf := ipamConfig("192.168.0.0/16")
+		ipamConf.NodeSliceSize = "/24"
+
+		ips, err := Manage(context.Background(), types.Allocate, store, ipamConf, containerID, ifName)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ips[0].String()).To(Equal("192.168.1.1/16"))
+		Expect(store.Allocations(storage.PoolIdentifier{IpRange: "192.168.1.0/24", NodeName: "node1"})).To(HaveLen(1))
+	})
+
+	It("refuses node slices when the backend does not support them", func() {
+		ipamConf := ipamConfig("192.168.0.0/16")
+		ipamConf.NodeSliceSize = "/24"
+
+		_, err := Manage(context.Background(), types.Allocate, noNodeSliceStore{store}, ipamConf, containerID, ifName)
+		var invalidConfigErr *storage.InvalidConfigError
+		Expect(errors.As(err, &invalidConfigErr)).To(BeTrue())
+	})
+
+	It("fails right away when the backend is unavailable", func() {
+		store.InjectFault(memory.OpStatus, memory.FaultTimeout, 1)
+
+		_, err := Manage(context.Background(), types.Allocate, store, ipamConfig("192.168.0.0/24"), containerID, ifName)
+		var unavailableErr *storage.BackendUnavailableError
+		Expect(errors.As(err, &unavailableErr)).To(BeTrue())
+	})
+})
+
+// noNodeSliceStore hides the node slice support of the store.
+type noNodeSliceStore struct {
+	storage.Store
+}
+
+// racingStore reserves every IP from an overlapping range for another pod right before the pod interface does.
+type racingStore struct {
+	*memory.Store
+}
+
+func (s racingStore) GetOverlappingRangeStore() (storage.OverlappingRangeStore, error) {
+	return s, nil
+}
+
+func (s racingStore) UpdateOverlappingRangeAllocation(ctx context.Context, mode int, ip net.IP, containerID, podRef, ifName, networkName string) error {
+	if mode == types.Allocate && len(s.OverlappingRangeReservations()) == 0 {
+		if err := s.Store.UpdateOverlappingRangeAllocation(ctx, mode, ip, "container2", "default/pod2", ifName, networkName); err != nil {
+			return err
+		}
+	}
+	return s.Store.UpdateOverlappingRangeAllocation(ctx, mode, ip, containerID, podRef, ifName, networkName)
+}
+
+// changingStore hands the overlapping range reservations over to another container of the pod right before the
+// pod interface does, as a concurrent request of the pod would.
+type changingStore struct {
+	*memory.Store
+}
+
+func (s changingStore) GetOverlappingRangeStore() (storage.OverlappingRangeStore, error) {
+	return s, nil
+}
+
+func (s changingStore) TransferOverlappingRangeReservation(ctx context.Context, reservation *v1alpha1.OverlappingRangeIPReservation, ip net.IP,
+	containerID, ifName, networkName string) error {
+	if reservation.Spec.ContainerID != "container3" {
+		if err := s.Store.TransferOverlappingRangeReservation(ctx, reservation, ip, "container3", ifName, networkName); err != nil {
+			return err
+		}
+	}
+	return s.Store.TransferOverlappingRangeReservation(ctx, reservation, ip, containerID, ifName, networkName)
+}
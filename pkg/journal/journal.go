@@ -0,0 +1,338 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package journal implements the node-local pending-release journal: the IP releases which could not be persisted
+// in the storage backend when the CNI DEL was invoked are recorded here, and drained later on, either by a later
+// CNI invocation or by the node's control loop.
+package journal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/logging"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+const (
+	// DefaultDir is where the pending-release journal is kept when the IPAM configuration does not specify a
+	// configuration path.
+	DefaultDir = "/etc/cni/net.d/whereabouts.d/" + dirName
+
+	// MaxAttempts is the number of failed attempts after which a pending release is dropped.
+	MaxAttempts = 10
+	// MaxAge is how long after its first failure a pending release is dropped. By then, the IPs of the pods which
+	// are gone are left to the reconciler.
+	MaxAge = 24 * time.Hour
+
+	dirName       = "pending-releases"
+	entrySuffix   = ".json"
+	lockFileName  = ".lock"
+	entryFileMode = 0600
+	dirMode       = 0700
+)
+
+// Entry is a release which could not be persisted in the storage backend.
+type Entry struct {
+	ContainerID string           `json:"containerID"`
+	IfName      string           `json:"ifName"`
+	Namespace   string           `json:"namespace"`
+	Config      types.IPAMConfig `json:"ipam"`
+	// OverlappingRanges is kept aside from the IPAM configuration, which omits it when disabled and enables it
+	// by default when unmarshalled.
+	OverlappingRanges bool `json:"enableOverlappingRanges"`
+	// Attempts is the number of times the release was attempted.
+	Attempts int `json:"attempts"`
+	// FirstFailure is the time the release failed for the first time.
+	FirstFailure time.Time `json:"firstFailure"`
+	// LastError is the error reported by the latest release attempt.
+	LastError string `json:"lastError"`
+}
+
+// NewEntry returns the journal entry of a release which failed with err.
+func NewEntry(containerID, ifName, namespace string, ipamConf types.IPAMConfig, err error) Entry {
+	return Entry{
+		ContainerID:       containerID,
+		IfName:            ifName,
+		Namespace:         namespace,
+		Config:            ipamConf,
+		OverlappingRanges: ipamConf.OverlappingRanges,
+		Attempts:          1,
+		FirstFailure:      time.Now().UTC(),
+		LastError:         err.Error(),
+	}
+}
+
+// ReleaseFunc releases the IPs of a journal entry.
+type ReleaseFunc func(ctx context.Context, entry Entry) error
+
+// Journal is a directory holding one file per pending release.
+type Journal struct {
+	dir string
+}
+
+// New returns a journal kept in dir.
+func New(dir string) *Journal {
+	return &Journal{dir: dir}
+}
+
+// Dir returns the directory of the pending-release journal for the given IPAM configuration: it sits next to
+// the whereabouts flat configuration file.
+func Dir(ipamConf types.IPAMConfig) string {
+	if ipamConf.ConfigurationPath == "" {
+		return DefaultDir
+	}
+	return filepath.Join(filepath.Dir(ipamConf.ConfigurationPath), dirName)
+}
+
+// Record persists a pending release; an existing entry for the same container and interface is replaced,
+// keeping track of its previous attempts.
+func (j *Journal) Record(entry Entry) error {
+	path, err := j.entryPath(entry.ContainerID, entry.IfName)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(j.dir, dirMode); err != nil {
+		return fmt.Errorf("failed to create the pending-release journal %s: %w", j.dir, err)
+	}
+
+	if previous, err := readEntry(path); err == nil {
+		entry.Attempts += previous.Attempts
+		entry.FirstFailure = previous.FirstFailure
+	} else {
+		pendingReleasesRecorded.Inc()
+	}
+
+	if err := writeEntry(path, entry); err != nil {
+		return err
+	}
+	j.updateBacklog()
+	return nil
+}
+
+// Remove deletes the pending release of the given container and interface, if any.
+func (j *Journal) Remove(containerID, ifName string) error {
+	path, err := j.entryPath(containerID, ifName)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove the pending release %s: %w", path, err)
+	}
+	j.updateBacklog()
+	return nil
+}
+
+// List returns the pending releases, skipping the entries which cannot be read.
+func (j *Journal) List() ([]Entry, error) {
+	paths, err := j.entryPaths()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, path := range paths {
+		entry, err := readEntry(path)
+		if err != nil {
+			logging.Errorf("skipping unreadable pending release %s: %v", path, err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Drain calls release for every pending release, removing the ones which were released. Entries which are
+// invalid, or whose release fails because of an invalid configuration, are dropped, since retrying them would
+// never succeed; so are the entries which failed MaxAttempts times, or for longer than MaxAge. Drain returns
+// immediately when another process is already draining the journal.
+func (j *Journal) Drain(ctx context.Context, release ReleaseFunc) error {
+	return j.DrainUpTo(ctx, 0, release)
+}
+
+// DrainUpTo drains the journal like Drain, but attempts at most limit releases when limit is positive, leaving
+// the others to a later drain.
+func (j *Journal) DrainUpTo(ctx context.Context, limit int, release ReleaseFunc) error {
+	unlock, err := j.tryLock()
+	if err != nil || unlock == nil {
+		return err
+	}
+	defer unlock()
+
+	paths, err := j.entryPaths()
+	if err != nil {
+		return err
+	}
+	defer j.updateBacklog()
+
+	attempted := 0
+	for _, path := range paths {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if limit > 0 && attempted == limit {
+			return nil
+		}
+		attempted++
+
+		entry, err := readEntry(path)
+		if err != nil {
+			logging.Errorf("dropping unreadable pending release %s: %v", path, err)
+			pendingReleasesDrained.WithLabelValues(resultDropped).Inc()
+			_ = os.Remove(path)
+			continue
+		}
+
+		logging.Debugf("releasing pending release for containerID: %q - podRef: %q - ifName: %q (attempts: %d)",
+			entry.ContainerID, entry.Config.GetPodRef(), entry.IfName, entry.Attempts)
+		err = release(ctx, entry)
+		var invalidConfigErr *storage.InvalidConfigError
+		switch {
+		case err == nil:
+			pendingReleasesDrained.WithLabelValues(resultReleased).Inc()
+			_ = os.Remove(path)
+		case errors.As(err, &invalidConfigErr):
+			logging.Errorf("dropping pending release for containerID: %q - ifName: %q: %v", entry.ContainerID, entry.IfName, err)
+			pendingReleasesDrained.WithLabelValues(resultDropped).Inc()
+			_ = os.Remove(path)
+		case ctx.Err() != nil:
+			// the drain ran out of time rather than the release failing: the entry is attempted again later on
+			return ctx.Err()
+		case entry.Attempts+1 >= MaxAttempts || time.Since(entry.FirstFailure) >= MaxAge:
+			logging.Errorf("dropping pending release for containerID: %q - ifName: %q after %d attempts since %s, "+
+				"leaving its IPs to the reconciler: %v", entry.ContainerID, entry.IfName, entry.Attempts+1,
+				entry.FirstFailure.Format(time.RFC3339), err)
+			pendingReleasesDrained.WithLabelValues(resultDropped).Inc()
+			_ = os.Remove(path)
+		default:
+			logging.Errorf("pending release for containerID: %q - ifName: %q failed: %v", entry.ContainerID, entry.IfName, err)
+			pendingReleasesDrained.WithLabelValues(resultFailed).Inc()
+			entry.Attempts++
+			entry.LastError = err.Error()
+			if err := writeEntry(path, entry); err != nil {
+				logging.Errorf("failed to update pending release %s: %v", path, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (j *Journal) entryPath(containerID, ifName string) (string, error) {
+	name := fmt.Sprintf("%s-%s%s", containerID, ifName, entrySuffix)
+	if containerID == "" || ifName == "" || strings.ContainsRune(name, os.PathSeparator) {
+		return "", fmt.Errorf("invalid pending release for containerID: %q - ifName: %q", containerID, ifName)
+	}
+	return filepath.Join(j.dir, name), nil
+}
+
+func (j *Journal) entryPaths() ([]string, error) {
+	files, err := os.ReadDir(j.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read the pending-release journal %s: %w", j.dir, err)
+	}
+
+	var paths []string
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), entrySuffix) {
+			continue
+		}
+		paths = append(paths, filepath.Join(j.dir, file.Name()))
+	}
+	return paths, nil
+}
+
+// tryLock takes an exclusive lock on the journal without blocking. It returns a nil unlock function when the
+// journal does not exist, or when it is locked by someone else.
+func (j *Journal) tryLock() (func(), error) {
+	lockFile, err := os.OpenFile(filepath.Join(j.dir, lockFileName), os.O_CREATE|os.O_RDWR, entryFileMode)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open the pending-release journal lock: %w", err)
+	}
+
+	if err := syscall.Flock(int(lockFile.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		lockFile.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			logging.Debugf("the pending-release journal %s is being drained by someone else", j.dir)
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to lock the pending-release journal: %w", err)
+	}
+
+	return func() {
+		_ = syscall.Flock(int(lockFile.Fd()), syscall.LOCK_UN)
+		lockFile.Close()
+	}, nil
+}
+
+func (j *Journal) updateBacklog() {
+	paths, err := j.entryPaths()
+	if err != nil {
+		return
+	}
+	pendingReleases.Set(float64(len(paths)))
+}
+
+func readEntry(path string) (Entry, error) {
+	var entry Entry
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return entry, err
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return entry, err
+	}
+	entry.Config.OverlappingRanges = entry.OverlappingRanges
+	return entry, nil
+}
+
+// writeEntry atomically replaces the entry stored in path.
+func writeEntry(path string, entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to serialize the pending release: %w", err)
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return fmt.Errorf("failed to write the pending release: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to write the pending release: %w", err)
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to write the pending release: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed to write the pending release: %w", err)
+	}
+	return os.Rename(tmpFile.Name(), path)
+}
@@ -0,0 +1,228 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package journal
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+func TestJournal(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "journal")
+}
+
+var _ = Describe("Pending-release journal", func() {
+	var (
+		tmpDir  string
+		journal *Journal
+	)
+
+	ipamConfig := func(podName string) types.IPAMConfig {
+		return types.IPAMConfig{
+			Type:         "whereabouts",
+			PodName:      podName,
+			PodNamespace: "default",
+			NetworkName:  "net1",
+			IPRanges: []types.RangeConfiguration{
+				{Range: "192.168.1.0/24", RangeStart: net.ParseIP("192.168.1.0")},
+			},
+			Kubernetes: types.KubernetesConfig{KubeConfigPath: "/etc/cni/net.d/whereabouts.d/whereabouts.kubeconfig"},
+		}
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = os.MkdirTemp("", "whereabouts")
+		Expect(err).NotTo(HaveOccurred())
+		journal = New(filepath.Join(tmpDir, dirName))
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	It("is located next to the flat configuration file", func() {
+		Expect(Dir(types.IPAMConfig{})).To(Equal(DefaultDir))
+		Expect(Dir(types.IPAMConfig{ConfigurationPath: "/etc/kubernetes/cni/net.d/whereabouts.d/whereabouts.conf"})).To(
+			Equal("/etc/kubernetes/cni/net.d/whereabouts.d/pending-releases"))
+	})
+
+	It("records the IPAM configuration of a failed release", func() {
+		Expect(journal.Record(NewEntry("container1", "eth0", "kube-system", ipamConfig("pod1"), fmt.Errorf("boom")))).To(Succeed())
+
+		entries, err := journal.List()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].ContainerID).To(Equal("container1"))
+		Expect(entries[0].IfName).To(Equal("eth0"))
+		Expect(entries[0].Namespace).To(Equal("kube-system"))
+		Expect(entries[0].Attempts).To(Equal(1))
+		Expect(entries[0].LastError).To(Equal("boom"))
+		Expect(entries[0].Config.GetPodRef()).To(Equal("default/pod1"))
+		Expect(entries[0].Config.NetworkName).To(Equal("net1"))
+		Expect(entries[0].Config.OverlappingRanges).To(BeFalse())
+		Expect(entries[0].Config.IPRanges).To(Equal(ipamConfig("pod1").IPRanges))
+	})
+
+	It("preserves the overlapping ranges setting", func() {
+		withOverlappingRanges := ipamConfig("pod1")
+		withOverlappingRanges.OverlappingRanges = true
+		Expect(journal.Record(NewEntry("container1", "eth0", "kube-system", withOverlappingRanges, fmt.Errorf("boom")))).To(Succeed())
+
+		entries, err := journal.List()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].Config.OverlappingRanges).To(BeTrue())
+	})
+
+	It("keeps track of the attempts of a release recorded twice", func() {
+		Expect(journal.Record(NewEntry("container1", "eth0", "kube-system", ipamConfig("pod1"), fmt.Errorf("boom")))).To(Succeed())
+		entries, err := journal.List()
+		Expect(err).NotTo(HaveOccurred())
+		firstFailure := entries[0].FirstFailure
+
+		Expect(journal.Record(NewEntry("container1", "eth0", "kube-system", ipamConfig("pod1"), fmt.Errorf("bang")))).To(Succeed())
+		entries, err = journal.List()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].Attempts).To(Equal(2))
+		Expect(entries[0].FirstFailure).To(Equal(firstFailure))
+		Expect(entries[0].LastError).To(Equal("bang"))
+	})
+
+	It("removes a release", func() {
+		Expect(journal.Record(NewEntry("container1", "eth0", "kube-system", ipamConfig("pod1"), fmt.Errorf("boom")))).To(Succeed())
+		Expect(journal.Remove("container1", "eth0")).To(Succeed())
+		Expect(journal.Remove("container1", "eth0")).To(Succeed())
+		Expect(journal.List()).To(BeEmpty())
+	})
+
+	It("refuses entries which would escape the journal directory", func() {
+		Expect(journal.Record(NewEntry("../container1", "eth0", "kube-system", ipamConfig("pod1"), fmt.Errorf("boom")))).NotTo(Succeed())
+	})
+
+	It("drains the pending releases", func() {
+		Expect(journal.Record(NewEntry("released", "eth0", "kube-system", ipamConfig("pod1"), fmt.Errorf("boom")))).To(Succeed())
+		Expect(journal.Record(NewEntry("failing", "eth0", "kube-system", ipamConfig("pod2"), fmt.Errorf("boom")))).To(Succeed())
+		Expect(journal.Record(NewEntry("invalid", "eth0", "kube-system", ipamConfig("pod3"), fmt.Errorf("boom")))).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(journal.dir, "corrupted-eth0.json"), []byte("{"), entryFileMode)).To(Succeed())
+
+		var released []string
+		Expect(journal.Drain(context.TODO(), func(_ context.Context, entry Entry) error {
+			released = append(released, entry.ContainerID)
+			switch entry.ContainerID {
+			case "failing":
+				return &storage.BackendUnavailableError{Err: fmt.Errorf("still down")}
+			case "invalid":
+				return &storage.InvalidConfigError{Err: fmt.Errorf("no pod name")}
+			}
+			return nil
+		})).To(Succeed())
+		Expect(released).To(ConsistOf("released", "failing", "invalid"))
+
+		entries, err := journal.List()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].ContainerID).To(Equal("failing"))
+		Expect(entries[0].Attempts).To(Equal(2))
+		Expect(entries[0].LastError).To(ContainSubstring("still down"))
+	})
+
+	It("drains at most the given number of pending releases", func() {
+		for _, containerID := range []string{"container1", "container2", "container3"} {
+			Expect(journal.Record(NewEntry(containerID, "eth0", "kube-system", ipamConfig("pod1"), fmt.Errorf("boom")))).To(Succeed())
+		}
+
+		released := 0
+		Expect(journal.DrainUpTo(context.TODO(), 2, func(_ context.Context, _ Entry) error {
+			released++
+			return nil
+		})).To(Succeed())
+		Expect(released).To(Equal(2))
+		Expect(journal.List()).To(HaveLen(1))
+	})
+
+	It("leaves the pending releases in place when the drain runs out of time", func() {
+		Expect(journal.Record(NewEntry("container1", "eth0", "kube-system", ipamConfig("pod1"), fmt.Errorf("boom")))).To(Succeed())
+
+		ctx, cancel := context.WithCancel(context.TODO())
+		Expect(journal.Drain(ctx, func(_ context.Context, _ Entry) error {
+			cancel()
+			return context.Canceled
+		})).To(MatchError(context.Canceled))
+
+		entries, err := journal.List()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].Attempts).To(Equal(1))
+	})
+
+	It("drops the pending releases which failed too many times or for too long", func() {
+		tooManyAttempts := NewEntry("attempts", "eth0", "kube-system", ipamConfig("pod1"), fmt.Errorf("boom"))
+		tooManyAttempts.Attempts = MaxAttempts - 1
+		tooOld := NewEntry("old", "eth0", "kube-system", ipamConfig("pod2"), fmt.Errorf("boom"))
+		tooOld.FirstFailure = time.Now().UTC().Add(-MaxAge)
+		Expect(journal.Record(tooManyAttempts)).To(Succeed())
+		Expect(journal.Record(tooOld)).To(Succeed())
+		Expect(journal.Record(NewEntry("failing", "eth0", "kube-system", ipamConfig("pod3"), fmt.Errorf("boom")))).To(Succeed())
+
+		Expect(journal.Drain(context.TODO(), func(_ context.Context, _ Entry) error {
+			return &storage.BackendUnavailableError{Err: fmt.Errorf("still down")}
+		})).To(Succeed())
+
+		entries, err := journal.List()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].ContainerID).To(Equal("failing"))
+	})
+
+	It("does not drain a journal which does not exist", func() {
+		Expect(journal.Drain(context.TODO(), func(_ context.Context, _ Entry) error {
+			Fail("no release expected")
+			return nil
+		})).To(Succeed())
+		_, err := os.Stat(journal.dir)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("does not drain a journal which is being drained by someone else", func() {
+		Expect(journal.Record(NewEntry("container1", "eth0", "kube-system", ipamConfig("pod1"), fmt.Errorf("boom")))).To(Succeed())
+
+		unlock, err := New(journal.dir).tryLock()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(unlock).NotTo(BeNil())
+		defer unlock()
+
+		Expect(journal.Drain(context.TODO(), func(_ context.Context, _ Entry) error {
+			Fail("no release expected")
+			return nil
+		})).To(Succeed())
+		Expect(journal.List()).To(HaveLen(1))
+	})
+})
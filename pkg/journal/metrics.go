@@ -0,0 +1,57 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package journal
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricsNamespace = "whereabouts"
+
+	resultReleased = "released"
+	resultFailed   = "failed"
+	resultDropped  = "dropped"
+)
+
+var (
+	pendingReleases = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "pending_releases",
+		Help:      "Number of IP releases waiting in the node's pending-release journal.",
+	})
+	pendingReleasesRecorded = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "pending_releases_recorded_total",
+		Help:      "Number of failed IP releases recorded in the node's pending-release journal.",
+	})
+	pendingReleasesDrained = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "pending_releases_drain_attempts_total",
+		Help:      "Number of attempts to drain a pending IP release, by result (released, failed, dropped).",
+	}, []string{"result"})
+)
+
+// RegisterMetrics registers the pending-release journal metrics with registerer.
+func RegisterMetrics(registerer prometheus.Registerer) error {
+	for _, collector := range []prometheus.Collector{pendingReleases, pendingReleasesRecorded, pendingReleasesDrained} {
+		if err := registerer.Register(collector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
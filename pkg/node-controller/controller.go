@@ -21,6 +21,7 @@ import (
 	nativeerrors "errors"
 	"fmt"
 	"sort"
+	"sync"
 	"time"
 
 	"golang.org/x/time/rate"
@@ -51,6 +52,7 @@ import (
 	whereaboutsInformers "github.com/k8snetworkplumbingwg/whereabouts/pkg/generated/informers/externalversions/whereabouts.cni.cncf.io/v1alpha1"
 	whereaboutsListers "github.com/k8snetworkplumbingwg/whereabouts/pkg/generated/listers/whereabouts.cni.cncf.io/v1alpha1"
 	"github.com/k8snetworkplumbingwg/whereabouts/pkg/iphelpers"
+	wbkubernetes "github.com/k8snetworkplumbingwg/whereabouts/pkg/storage/kubernetes"
 	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
 )
 
@@ -95,9 +97,13 @@ type Controller struct {
 	//For testing, sort nodes before assigning to get consistent return values
 	sortResults bool
 
-	// whereabouts namespace set from WHEREABOUTS_NAMESPACE env var, should match what's in the daemonset
+	// whereabouts namespace, as resolved by kubernetes.ResolveNamespace, should match what the CNI resolves
 	// this is where the IPPools and NodeSlicePools will be created
 	whereaboutsNamespace string
+
+	// deletedSliceNamespaces holds the namespace of the node slices of the deleted network-attachment-definitions,
+	// by key, which their configuration can no longer tell once they are gone
+	deletedSliceNamespaces sync.Map
 }
 
 // NewController returns a new sample controller
@@ -157,7 +163,7 @@ func NewController(
 			}
 			c.onNadEvent(cur)
 		},
-		DeleteFunc: c.onNadEvent,
+		DeleteFunc: c.onNadDelete,
 	})
 
 	nodeInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
@@ -193,6 +199,30 @@ func (c *Controller) onNadEvent(obj interface{}) {
 	c.workqueue.Add(key)
 }
 
+func (c *Controller) onNadDelete(obj interface{}) {
+	nad, ok := obj.(*cncfV1.NetworkAttachmentDefinition)
+	if !ok {
+		if tombstone, isTombstone := obj.(cache.DeletedFinalStateUnknown); isTombstone {
+			nad, ok = tombstone.Obj.(*cncfV1.NetworkAttachmentDefinition)
+		}
+	}
+	if ok {
+		if ipamConf, err := ipamConfiguration(nad, ""); err == nil {
+			c.deletedSliceNamespaces.Store(nad.GetNamespace()+"/"+nad.GetName(), c.sliceNamespace(ipamConf, nad.GetNamespace()))
+		}
+	}
+	c.onNadEvent(obj)
+}
+
+// sliceNamespace returns the namespace of the node slices of a network-attachment-definition of nadNamespace, the one
+// the CNI and the pool controller look for them in.
+func (c *Controller) sliceNamespace(ipamConf *types.IPAMConfig, nadNamespace string) string {
+	if !ipamConf.TenantNamespaces && ipamConf.Kubernetes.Namespace == "" {
+		return c.whereaboutsNamespace
+	}
+	return wbkubernetes.NetworkNamespace(*ipamConf, nadNamespace)
+}
+
 // TODO: we may want to require nodes to have an annotation similar to what pods have to receive a slice
 // in this case we get all applicable NADs for the node rather than requeuing all
 // same applies to other node event handlers
@@ -338,19 +368,30 @@ func (c *Controller) syncHandler(ctx context.Context, key string) error {
 		if err != nil {
 			return nil
 		}
+		// the node slices are kept in the namespace the configuration of the nad resolved; when its deletion was
+		// missed, in the whereabouts namespace or in the one of the nad
+		sliceNamespaces := map[string]bool{c.whereaboutsNamespace: true, namespace: true}
+		if sliceNamespace, ok := c.deletedSliceNamespaces.Load(key); ok {
+			sliceNamespaces = map[string]bool{sliceNamespace.(string): true}
+		}
 		for _, nodeSlice := range nodeSlices {
+			if !sliceNamespaces[nodeSlice.GetNamespace()] {
+				continue
+			}
 			if hasOwnerRef(nodeSlice, name) {
 				if len(nodeSlice.OwnerReferences) == 1 {
 					//this is the last NAD owning this so delete
-					err = c.whereaboutsclientset.WhereaboutsV1alpha1().NodeSlicePools(c.whereaboutsNamespace).Delete(ctx, name, metav1.DeleteOptions{})
+					err = c.whereaboutsclientset.WhereaboutsV1alpha1().NodeSlicePools(nodeSlice.GetNamespace()).Delete(ctx, nodeSlice.GetName(), metav1.DeleteOptions{})
 					if err != nil && !errors.IsNotFound(err) {
 						return err
 					}
 				}
 			}
 		}
+		c.deletedSliceNamespaces.Delete(key)
 		return nil
 	}
+	c.deletedSliceNamespaces.Delete(key)
 	//nad does exist so did it change node_slice_range or slice_size
 	ipamConf, err := ipamConfiguration(nad, "")
 	if err != nil {
@@ -366,10 +407,13 @@ func (c *Controller) syncHandler(ctx context.Context, key string) error {
 		return nil
 	}
 
+	// the node slices are kept in the namespace of the other whereabouts objects of the network
+	sliceNamespace := c.sliceNamespace(ipamConf, namespace)
+
 	logger.Info("About to update node slices for network-attachment-definition",
 		"network-attachment-definition", klog.KRef(namespace, name))
 
-	currentNodeSlicePool, err := c.nodeSlicePoolLister.NodeSlicePools(c.whereaboutsNamespace).Get(getSliceName(ipamConf))
+	currentNodeSlicePool, err := c.nodeSlicePoolLister.NodeSlicePools(sliceNamespace).Get(getSliceName(ipamConf))
 	if err != nil {
 		logger.Info("node slice pool does not exist, creating")
 		if !errors.IsNotFound(err) {
@@ -383,7 +427,7 @@ func (c *Controller) syncHandler(ctx context.Context, key string) error {
 			},
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      getSliceName(ipamConf),
-				Namespace: c.whereaboutsNamespace,
+				Namespace: sliceNamespace,
 				OwnerReferences: []metav1.OwnerReference{
 					*metav1.NewControllerRef(nad, cncfV1.SchemeGroupVersion.WithKind("NetworkAttachmentDefinition")),
 				},
@@ -420,7 +464,7 @@ func (c *Controller) syncHandler(ctx context.Context, key string) error {
 			Allocations: allocations,
 		}
 		logger.Info(fmt.Sprintf("final allocations: %v", allocations))
-		_, err = c.whereaboutsclientset.WhereaboutsV1alpha1().NodeSlicePools(c.whereaboutsNamespace).Create(ctx, nodeslice, metav1.CreateOptions{})
+		_, err = c.whereaboutsclientset.WhereaboutsV1alpha1().NodeSlicePools(sliceNamespace).Create(ctx, nodeslice, metav1.CreateOptions{})
 		if err != nil {
 			logger.Error(err, "failed to create nodeslicepool")
 			return err
@@ -469,7 +513,7 @@ func (c *Controller) syncHandler(ctx context.Context, key string) error {
 			nodeslice.Status = v1alpha1.NodeSlicePoolStatus{
 				Allocations: allocations,
 			}
-			_, err = c.whereaboutsclientset.WhereaboutsV1alpha1().NodeSlicePools(c.whereaboutsNamespace).Update(ctx, nodeslice, metav1.UpdateOptions{})
+			_, err = c.whereaboutsclientset.WhereaboutsV1alpha1().NodeSlicePools(sliceNamespace).Update(ctx, nodeslice, metav1.UpdateOptions{})
 			if err != nil {
 				return err
 			}
@@ -487,7 +531,7 @@ func (c *Controller) syncHandler(ctx context.Context, key string) error {
 			removeUnusedNodes(allocations, nodes)
 			nodeslice.Status.Allocations = allocations
 
-			_, err = c.whereaboutsclientset.WhereaboutsV1alpha1().NodeSlicePools(c.whereaboutsNamespace).Update(context.TODO(), nodeslice, metav1.UpdateOptions{})
+			_, err = c.whereaboutsclientset.WhereaboutsV1alpha1().NodeSlicePools(sliceNamespace).Update(context.TODO(), nodeslice, metav1.UpdateOptions{})
 			if err != nil {
 				logger.Info(fmt.Sprintf("Error updating NSP with no changes: %v", err))
 				return err
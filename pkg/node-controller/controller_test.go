@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -59,6 +60,8 @@ type fixture struct {
 	nadLister           []*k8snetplumbersv1.NetworkAttachmentDefinition
 	nodeSlicePoolLister []*v1alpha1.NodeSlicePool
 	nodeLister          []*v1.Node
+	// NADs whose deletion the controller is notified of.
+	deletedNads []*k8snetplumbersv1.NetworkAttachmentDefinition
 
 	// Actions expected to happen on the client.
 	whereaboutsactions []core.Action
@@ -187,7 +190,7 @@ func (f *fixture) newController(ctx context.Context) (*Controller, informers.Sha
 			Group:    "k8s.cni.cncf.io",
 			Version:  "v1",
 			Resource: "network-attachment-definitions",
-		}, nad, "default")
+		}, nad, nad.(*k8snetplumbersv1.NetworkAttachmentDefinition).GetNamespace())
 	}
 
 	whereaboutsInformerFactory := informers.NewSharedInformerFactory(f.whereaboutsclient, noResyncPeriodFunc())
@@ -265,6 +268,9 @@ func (f *fixture) runController(ctx context.Context, nadName string, startInform
 		kubeInformer.Start(ctx.Done())
 		nadInformer.Start(ctx.Done())
 	}
+	for _, nad := range f.deletedNads {
+		c.onNadDelete(nad)
+	}
 
 	err := c.syncHandler(ctx, nadName)
 	if !expectError && err != nil {
@@ -452,6 +458,95 @@ func TestCreatesNodeSlicePoolsWithNodes(t *testing.T) {
 	f.run(context.TODO(), getKey(nad, t))
 }
 
+// TestCreatesNodeSlicePoolsInTenantNamespace tests that the nodeslicepool of a nad with tenant namespaces is created
+// in the namespace of the nad
+func TestCreatesNodeSlicePoolsInTenantNamespace(t *testing.T) {
+	f := newFixture(t)
+	nad := newNad("test", "test", "10.0.0.0/8", "/9")
+	nad.Namespace = "tenant-a"
+	nad.Spec.Config = strings.Replace(nad.Spec.Config, `"enable_overlapping_ranges": false`,
+		`"enable_overlapping_ranges": false, "tenant_namespaces": true`, 1)
+	nodeSlicePool := newNodeSlicePool("test", "10.0.0.0/8", "/9",
+		v1alpha1.NodeSlicePoolStatus{
+			Allocations: []v1alpha1.NodeSliceAllocation{
+				{
+					NodeName:   "",
+					SliceRange: "10.0.0.0/9",
+				},
+				{
+					NodeName:   "",
+					SliceRange: "10.128.0.0/9",
+				},
+			},
+		}, nad)
+	nodeSlicePool.Namespace = "tenant-a"
+
+	f.nadLister = append(f.nadLister, nad)
+	f.nadObjects = append(f.nadObjects, nad)
+	f.expectNodeSlicePoolCreateAction(nodeSlicePool)
+
+	f.run(context.TODO(), getKey(nad, t))
+}
+
+// TestCreatesNodeSlicePoolsInKubernetesNamespace tests that the nodeslicepool of a nad setting the kubernetes
+// namespace of its ipam configuration is created in that namespace
+func TestCreatesNodeSlicePoolsInKubernetesNamespace(t *testing.T) {
+	f := newFixture(t)
+	nad := newNad("test", "test", "10.0.0.0/8", "/9")
+	nad.Spec.Config = strings.Replace(nad.Spec.Config, `"enable_overlapping_ranges": false`,
+		`"enable_overlapping_ranges": false, "kubernetes": {"namespace": "whereabouts"}`, 1)
+	nodeSlicePool := newNodeSlicePool("test", "10.0.0.0/8", "/9",
+		v1alpha1.NodeSlicePoolStatus{
+			Allocations: []v1alpha1.NodeSliceAllocation{
+				{
+					NodeName:   "",
+					SliceRange: "10.0.0.0/9",
+				},
+				{
+					NodeName:   "",
+					SliceRange: "10.128.0.0/9",
+				},
+			},
+		}, nad)
+	nodeSlicePool.Namespace = "whereabouts"
+
+	f.nadLister = append(f.nadLister, nad)
+	f.nadObjects = append(f.nadObjects, nad)
+	f.expectNodeSlicePoolCreateAction(nodeSlicePool)
+
+	f.run(context.TODO(), getKey(nad, t))
+}
+
+// TestNadDeleteInKubernetesNamespace tests the deletion of the NodeSlicePool kept in the kubernetes namespace of the
+// ipam configuration of its deleted NAD
+func TestNadDeleteInKubernetesNamespace(t *testing.T) {
+	f := newFixture(t)
+	nad := newNad("test", "test", "10.0.0.0/8", "/9")
+	nad.Spec.Config = strings.Replace(nad.Spec.Config, `"enable_overlapping_ranges": false`,
+		`"enable_overlapping_ranges": false, "kubernetes": {"namespace": "whereabouts"}`, 1)
+	nodeSlicePool := newNodeSlicePool("test", "10.0.0.0/8", "/9",
+		v1alpha1.NodeSlicePoolStatus{
+			Allocations: []v1alpha1.NodeSliceAllocation{
+				{
+					NodeName:   "",
+					SliceRange: "10.0.0.0/9",
+				},
+				{
+					NodeName:   "",
+					SliceRange: "10.128.0.0/9",
+				},
+			},
+		}, nad)
+	nodeSlicePool.Namespace = "whereabouts"
+
+	f.deletedNads = append(f.deletedNads, nad)
+	f.nodeSlicePoolLister = append(f.nodeSlicePoolLister, nodeSlicePool)
+	f.whereaboutsObjects = append(f.whereaboutsObjects, nodeSlicePool)
+	f.expectNodeSlicePoolDeleteAction(nodeSlicePool)
+
+	f.run(context.TODO(), getKey(nad, t))
+}
+
 // TestDoNothing checks for no action taken when no nad exists
 func TestDoNothing(t *testing.T) {
 	f := newFixture(t)
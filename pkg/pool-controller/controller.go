@@ -0,0 +1,477 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package pool_controller
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"sort"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+
+	nadinformers "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/client/informers/externalversions/k8s.cni.cncf.io/v1"
+	nadlisters "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/client/listers/k8s.cni.cncf.io/v1"
+
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/api/whereabouts.cni.cncf.io/v1alpha1"
+	clientset "github.com/k8snetworkplumbingwg/whereabouts/pkg/generated/clientset/versioned"
+	whereaboutsInformers "github.com/k8snetworkplumbingwg/whereabouts/pkg/generated/informers/externalversions/whereabouts.cni.cncf.io/v1alpha1"
+	whereaboutsListers "github.com/k8snetworkplumbingwg/whereabouts/pkg/generated/listers/whereabouts.cni.cncf.io/v1alpha1"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/iphelpers"
+	wbkubernetes "github.com/k8snetworkplumbingwg/whereabouts/pkg/storage/kubernetes"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+const (
+	// mountPath is where the controller mounts the CNI configuration directory of the host
+	mountPath             = "/host"
+	whereaboutsConfigPath = mountPath + "/etc/cni/net.d/whereabouts.d/whereabouts.conf"
+
+	// nearlyExhaustedPercent is the share of the available IPs of a pool under which it is nearly exhausted
+	nearlyExhaustedPercent = 10
+	// minStatusUpdateInterval is the minimum time between two updates of the status of a pool: each of them
+	// changes the resource version the allocations of the CNI are conditioned on, so a burst of allocations is
+	// only reflected in the status once it is over.
+	minStatusUpdateInterval = 10 * time.Second
+)
+
+// Controller keeps the status of the IPPools current: how many IPs of their range are usable, allocated, excluded
+// and free, and whether the pool is exhausted. The counts of a sharded pool cover all its shards. It also ties the
+// IPPools to the network-attachment-definitions of their network, keeps them from being deleted while they hold
+// allocations, and provisions the IPPools of the network-attachment-definitions before the CNI allocates from them.
+type Controller struct {
+	whereaboutsclientset clientset.Interface
+
+	ipPoolLister whereaboutsListers.IPPoolLister
+	ipPoolSynced cache.InformerSynced
+
+	nadLister nadlisters.NetworkAttachmentDefinitionLister
+	nadSynced cache.InformerSynced
+
+	nodeSlicePoolLister whereaboutsListers.NodeSlicePoolLister
+	nodeSlicePoolSynced cache.InformerSynced
+
+	// workqueue holds the namespace/name keys of the IPPools whose status is to be updated; the shards are queued
+	// as the pool they belong to.
+	workqueue workqueue.TypedRateLimitingInterface[string]
+	// nadWorkqueue holds the namespace/name keys of the network-attachment-definitions whose IPPools are to be
+	// provisioned.
+	nadWorkqueue workqueue.TypedRateLimitingInterface[string]
+}
+
+// NewController returns a new IPPool controller
+func NewController(
+	ctx context.Context,
+	whereaboutsclientset clientset.Interface,
+	ipPoolInformer whereaboutsInformers.IPPoolInformer,
+	nadInformer nadinformers.NetworkAttachmentDefinitionInformer,
+	nodeSlicePoolInformer whereaboutsInformers.NodeSlicePoolInformer,
+) *Controller {
+	logger := klog.FromContext(ctx)
+
+	ratelimiter := func() workqueue.TypedRateLimiter[string] {
+		return workqueue.NewTypedMaxOfRateLimiter(
+			workqueue.NewTypedItemExponentialFailureRateLimiter[string](5*time.Millisecond, 1000*time.Second),
+			&workqueue.TypedBucketRateLimiter[string]{Limiter: rate.NewLimiter(rate.Limit(50), 300)},
+		)
+	}
+
+	c := &Controller{
+		whereaboutsclientset: whereaboutsclientset,
+		ipPoolLister:         ipPoolInformer.Lister(),
+		ipPoolSynced:         ipPoolInformer.Informer().HasSynced,
+		nadLister:            nadInformer.Lister(),
+		nadSynced:            nadInformer.Informer().HasSynced,
+		nodeSlicePoolLister:  nodeSlicePoolInformer.Lister(),
+		nodeSlicePoolSynced:  nodeSlicePoolInformer.Informer().HasSynced,
+		workqueue:            workqueue.NewTypedRateLimitingQueue(ratelimiter()),
+		nadWorkqueue:         workqueue.NewTypedRateLimitingQueue(ratelimiter()),
+	}
+
+	logger.Info("Setting up IPPool event handlers")
+
+	ipPoolInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.onIPPoolEvent,
+		UpdateFunc: func(old, cur interface{}) {
+			if old.(*v1alpha1.IPPool).ResourceVersion == cur.(*v1alpha1.IPPool).ResourceVersion {
+				return
+			}
+			c.onIPPoolEvent(cur)
+		},
+		DeleteFunc: c.onIPPoolEvent,
+	})
+
+	// the range_start, range_end and exclude of the pools are read from the network-attachment-definitions, which
+	// also tell the pools to provision
+	nadInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			c.onNadEvent(obj)
+			c.requeueIPPools(obj)
+		},
+		UpdateFunc: func(_, cur interface{}) {
+			c.onNadEvent(cur)
+			c.requeueIPPools(cur)
+		},
+		DeleteFunc: c.requeueIPPools,
+	})
+
+	// the pools of the networks with node slices are those of the slices allocated to the nodes
+	nodeSlicePoolInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			c.requeueNads(obj)
+			c.requeueIPPools(obj)
+		},
+		UpdateFunc: func(_, cur interface{}) {
+			c.requeueNads(cur)
+			c.requeueIPPools(cur)
+		},
+		DeleteFunc: c.requeueIPPools,
+	})
+
+	return c
+}
+
+func (c *Controller) onIPPoolEvent(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	pool, ok := obj.(*v1alpha1.IPPool)
+	if !ok {
+		utilruntime.HandleError(fmt.Errorf("error decoding object, invalid type"))
+		return
+	}
+	if pool.Spec.Shard != nil {
+		c.workqueue.Add(cache.NewObjectName(pool.GetNamespace(), pool.Spec.Shard.Parent).String())
+		return
+	}
+	c.workqueue.Add(cache.NewObjectName(pool.GetNamespace(), pool.GetName()).String())
+}
+
+func (c *Controller) requeueIPPools(_ interface{}) {
+	pools, err := c.ipPoolLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("failed to list the IPPools: %v", err))
+		return
+	}
+	for _, pool := range pools {
+		if pool.Spec.Shard == nil {
+			c.onIPPoolEvent(pool)
+		}
+	}
+}
+
+// Run will set up the event handlers for types we are interested in, as well
+// as syncing informer caches and starting workers. It will block until ctx
+// is cancelled, at which point it will shutdown the workqueue and wait for
+// workers to finish processing their current work items.
+func (c *Controller) Run(ctx context.Context, workers int) error {
+	defer utilruntime.HandleCrash()
+	defer c.workqueue.ShutDown()
+	defer c.nadWorkqueue.ShutDown()
+	logger := klog.FromContext(ctx)
+
+	logger.Info("Starting IPPool controller")
+
+	logger.Info("Waiting for informer caches to sync")
+	if ok := cache.WaitForCacheSync(ctx.Done(), c.ipPoolSynced); !ok {
+		return fmt.Errorf("failed to wait for ippools caches to sync")
+	}
+	if ok := cache.WaitForCacheSync(ctx.Done(), c.nadSynced); !ok {
+		return fmt.Errorf("failed to wait for nad caches to sync")
+	}
+	if ok := cache.WaitForCacheSync(ctx.Done(), c.nodeSlicePoolSynced); !ok {
+		return fmt.Errorf("failed to wait for nodeslicepool caches to sync")
+	}
+
+	logger.Info("Starting workers", "count", workers)
+	for i := 0; i < workers; i++ {
+		go wait.UntilWithContext(ctx, func(ctx context.Context) { c.runWorker(ctx, c.workqueue, c.syncHandler) }, time.Second)
+		go wait.UntilWithContext(ctx, func(ctx context.Context) { c.runWorker(ctx, c.nadWorkqueue, c.provisionHandler) }, time.Second)
+	}
+
+	logger.Info("Started workers")
+	<-ctx.Done()
+	logger.Info("Shutting down workers")
+
+	return nil
+}
+
+func (c *Controller) runWorker(ctx context.Context, queue workqueue.TypedRateLimitingInterface[string], handler func(context.Context, string) error) {
+	for c.processNextWorkItem(ctx, queue, handler) {
+	}
+}
+
+func (c *Controller) processNextWorkItem(ctx context.Context, queue workqueue.TypedRateLimitingInterface[string], handler func(context.Context, string) error) bool {
+	key, shutdown := queue.Get()
+	if shutdown {
+		return false
+	}
+	defer queue.Done(key)
+
+	if err := handler(ctx, key); err != nil {
+		queue.AddRateLimited(key)
+		utilruntime.HandleError(fmt.Errorf("error syncing '%s': %s, requeuing", key, err.Error()))
+		return true
+	}
+	queue.Forget(key)
+	return true
+}
+
+// syncHandler keeps the IPPool and its shards protected by the finalizer while they hold allocations, ties the pool
+// to its network-attachment-definitions, deletes it once empty and of no network-attachment-definition, and updates
+// its status when the utilization computed from the informer cache changed, at most once per
+// minStatusUpdateInterval.
+func (c *Controller) syncHandler(ctx context.Context, key string) error {
+	logger := klog.LoggerWithValues(klog.FromContext(ctx), "resourceName", key)
+
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("invalid resource key: %s", key))
+		return nil
+	}
+
+	// the shards are listed even when the pool is gone, so the finalizers of the deleted shards are released
+	shards, err := c.ipPoolLister.IPPools(namespace).List(wbkubernetes.ShardSelector(name))
+	if err != nil {
+		return err
+	}
+	allocated := int64(0)
+	for _, shard := range shards {
+		if _, err := c.syncFinalizer(ctx, shard, int64(len(shard.Spec.Allocations))); err != nil {
+			return err
+		}
+		allocated += int64(len(shard.Spec.Allocations))
+	}
+
+	pool, err := c.ipPoolLister.IPPools(namespace).Get(name)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	allocated += int64(len(pool.Spec.Allocations))
+
+	if pool.GetDeletionTimestamp() != nil {
+		_, err := c.syncFinalizer(ctx, pool, allocated)
+		return err
+	}
+
+	network, rangeConf := c.poolNetwork(logger, pool)
+	if allocated == 0 && len(network.nads) == 0 {
+		requeueAfter, err := c.deleteUnownedPool(ctx, pool)
+		if requeueAfter > 0 {
+			c.workqueue.AddAfter(key, requeueAfter)
+		}
+		if err != nil || requeueAfter == 0 {
+			return err
+		}
+	}
+	if updatedPool := withOwners(pool, network); updatedPool != nil {
+		if pool, err = c.whereaboutsclientset.WhereaboutsV1alpha1().IPPools(namespace).Update(ctx, updatedPool, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		logger.V(4).Info("Updated the owners of the IPPool", "networkAttachmentDefinitions", len(network.nads))
+	}
+
+	total, excluded, err := rangeUtilization(rangeConf)
+	if err != nil {
+		// the range will not get any better by retrying
+		logger.Error(err, "failed to compute the utilization of the IPPool")
+		return nil
+	}
+
+	status := pool.Status.DeepCopy()
+	status.Total = total
+	status.Allocated = allocated
+	status.Excluded = excluded
+	status.Free = max(total-excluded-allocated, 0)
+	setConditions(status, pool.GetGeneration())
+	if equality.Semantic.DeepEqual(&pool.Status, status) {
+		return nil
+	}
+	if since := time.Since(pool.Status.LastUpdated.Time); since < minStatusUpdateInterval {
+		c.workqueue.AddAfter(key, minStatusUpdateInterval-since)
+		return nil
+	}
+	status.LastUpdated = metav1.Now()
+
+	updatedPool := pool.DeepCopy()
+	updatedPool.Status = *status
+	if _, err := c.whereaboutsclientset.WhereaboutsV1alpha1().IPPools(namespace).UpdateStatus(ctx, updatedPool, metav1.UpdateOptions{}); err != nil {
+		return err
+	}
+	logger.V(4).Info("Updated the IPPool status", "allocated", allocated, "free", status.Free)
+	return nil
+}
+
+// poolNetwork returns the network-attachment-definitions allocating from the pool, and the configuration of its range
+// in the first of them; or the bare range when no network-attachment-definition allocates from it.
+func (c *Controller) poolNetwork(logger klog.Logger, pool *v1alpha1.IPPool) (poolNetwork, types.RangeConfiguration) {
+	network := poolNetwork{}
+	rangeConf := types.RangeConfiguration{Range: pool.Spec.Range}
+	nads, err := c.nadLister.List(labels.Everything())
+	if err != nil {
+		return network, rangeConf
+	}
+	sort.Slice(nads, func(i, j int) bool {
+		return nads[i].GetNamespace()+"/"+nads[i].GetName() < nads[j].GetNamespace()+"/"+nads[j].GetName()
+	})
+	for _, nad := range nads {
+		ipamConf, err := nadIPAMConfiguration(nad)
+		if err != nil {
+			// reported when the network-attachment-definition is provisioned
+			logger.V(4).Info("Skipping a network-attachment-definition", "networkAttachmentDefinition", klog.KObj(nad), "err", err)
+			continue
+		}
+		if ipamConf == nil {
+			continue
+		}
+		// the pools of tenant namespaces only belong to the network-attachment-definitions of their namespace
+		if ipamConf.TenantNamespaces && nad.GetNamespace() != pool.GetNamespace() {
+			continue
+		}
+		for _, nadPool := range c.networkPools(wbkubernetes.NetworkNamespace(*ipamConf, nad.GetNamespace()), ipamConf) {
+			if wbkubernetes.IPPoolName(nadPool.identifier) != pool.GetName() {
+				continue
+			}
+			if len(network.nads) == 0 {
+				rangeConf = nadPool.rangeConf
+				network.networkName = ipamConf.NetworkName
+			}
+			network.nads = append(network.nads, nad)
+			break
+		}
+	}
+	return network, rangeConf
+}
+
+// rangeUtilization returns the number of usable IPs of the range, and how many of them are excluded. The counts
+// saturate at math.MaxInt64, which large IPv6 ranges exceed.
+func rangeUtilization(rangeConf types.RangeConfiguration) (int64, int64, error) {
+	_, ipNet, err := net.ParseCIDR(rangeConf.Range)
+	if err != nil {
+		return 0, 0, err
+	}
+	firstIP, lastIP, err := iphelpers.GetIPRange(*ipNet, rangeConf.RangeStart, rangeConf.RangeEnd)
+	if err != nil {
+		return 0, 0, err
+	}
+	first, last := ipToInt(firstIP), ipToInt(lastIP)
+
+	type interval struct{ start, end *big.Int }
+	var excludedIntervals []interval
+	for _, omitRange := range rangeConf.OmitRanges {
+		_, subnet, err := net.ParseCIDR(omitRange)
+		if err != nil {
+			return 0, 0, fmt.Errorf("could not parse exclude range %s: %w", omitRange, err)
+		}
+		start, end := ipToInt(iphelpers.NetworkIP(*subnet)), ipToInt(iphelpers.SubnetBroadcastIP(*subnet))
+		if start.Cmp(first) < 0 {
+			start = first
+		}
+		if end.Cmp(last) > 0 {
+			end = last
+		}
+		if start.Cmp(end) <= 0 {
+			excludedIntervals = append(excludedIntervals, interval{start, end})
+		}
+	}
+	sort.Slice(excludedIntervals, func(a, b int) bool { return excludedIntervals[a].start.Cmp(excludedIntervals[b].start) < 0 })
+
+	// the exclude ranges may overlap, so each IP is only counted once
+	excluded := new(big.Int)
+	var current *interval
+	for idx := range excludedIntervals {
+		next := excludedIntervals[idx]
+		if current != nil && next.start.Cmp(current.end) <= 0 {
+			if next.end.Cmp(current.end) > 0 {
+				current.end = next.end
+			}
+			continue
+		}
+		if current != nil {
+			excluded.Add(excluded, intervalSize(current.start, current.end))
+		}
+		current = &next
+	}
+	if current != nil {
+		excluded.Add(excluded, intervalSize(current.start, current.end))
+	}
+
+	return saturatedInt64(intervalSize(first, last)), saturatedInt64(excluded), nil
+}
+
+// setConditions sets the Exhausted and NearlyExhausted conditions from the counts of the status
+func setConditions(status *v1alpha1.IPPoolStatus, generation int64) {
+	available := status.Total - status.Excluded
+	message := fmt.Sprintf("%d of %d available IPs are free", status.Free, max(available, 0))
+
+	exhausted := metav1.Condition{
+		Type:               v1alpha1.IPPoolExhausted,
+		Status:             metav1.ConditionFalse,
+		Reason:             "IPsAvailable",
+		Message:            message,
+		ObservedGeneration: generation,
+	}
+	if status.Free == 0 {
+		exhausted.Status = metav1.ConditionTrue
+		exhausted.Reason = "NoFreeIPs"
+	}
+	meta.SetStatusCondition(&status.Conditions, exhausted)
+
+	nearlyExhausted := metav1.Condition{
+		Type:               v1alpha1.IPPoolNearlyExhausted,
+		Status:             metav1.ConditionFalse,
+		Reason:             "IPsAvailable",
+		Message:            message,
+		ObservedGeneration: generation,
+	}
+	if float64(status.Free) <= float64(available)*nearlyExhaustedPercent/100 {
+		nearlyExhausted.Status = metav1.ConditionTrue
+		nearlyExhausted.Reason = "FewFreeIPs"
+	}
+	meta.SetStatusCondition(&status.Conditions, nearlyExhausted)
+}
+
+func ipToInt(ip net.IP) *big.Int {
+	return new(big.Int).SetBytes(ip.To16())
+}
+
+func intervalSize(start, end *big.Int) *big.Int {
+	size := new(big.Int).Sub(end, start)
+	return size.Add(size, big.NewInt(1))
+}
+
+func saturatedInt64(n *big.Int) int64 {
+	if !n.IsInt64() {
+		return math.MaxInt64
+	}
+	return n.Int64()
+}
@@ -0,0 +1,241 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package pool_controller
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	core "k8s.io/client-go/testing"
+
+	k8snetplumbersv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+	k8snetplumbersv1fake "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/client/clientset/versioned/fake"
+	nadinformers "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/client/informers/externalversions"
+
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/api/whereabouts.cni.cncf.io/v1alpha1"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/generated/clientset/versioned/fake"
+	informers "github.com/k8snetworkplumbingwg/whereabouts/pkg/generated/informers/externalversions"
+	wbkubernetes "github.com/k8snetworkplumbingwg/whereabouts/pkg/storage/kubernetes"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+const poolNamespace = "kube-system"
+
+func newPool(name, ipRange string, offsets ...int) *v1alpha1.IPPool {
+	pool := &v1alpha1.IPPool{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: poolNamespace, ResourceVersion: "1"},
+		Spec:       v1alpha1.IPPoolSpec{Range: ipRange, Allocations: map[string]v1alpha1.IPAllocation{}},
+	}
+	for _, offset := range offsets {
+		pool.Spec.Allocations[fmt.Sprint(offset)] = v1alpha1.IPAllocation{PodRef: fmt.Sprintf("default/pod%d", offset)}
+	}
+	return pool
+}
+
+// newNad returns a network-attachment-definition with the given IPAM parameters, along with the flat configuration
+// file the IPAM configuration requires.
+func newNad(t *testing.T, name, ipamParameters string) *k8snetplumbersv1.NetworkAttachmentDefinition {
+	t.Helper()
+	configurationPath := filepath.Join(t.TempDir(), "whereabouts.conf")
+	flatConfig := `{"datastore": "kubernetes", "kubernetes": {"kubeconfig": "/etc/cni/net.d/whereabouts.d/whereabouts.kubeconfig"}}`
+	if err := os.WriteFile(configurationPath, []byte(flatConfig), 0644); err != nil {
+		t.Fatalf("failed to write the flat configuration file: %v", err)
+	}
+	return &k8snetplumbersv1.NetworkAttachmentDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: metav1.NamespaceDefault},
+		Spec: k8snetplumbersv1.NetworkAttachmentDefinitionSpec{
+			Config: fmt.Sprintf(`{"cniVersion": "0.3.1", "name": "%s", "type": "macvlan",
+				"ipam": {"type": "whereabouts", "configuration_path": "%s", %s}}`, name, configurationPath, ipamParameters),
+		},
+	}
+}
+
+// newTestController returns a controller whose informer caches hold the given objects, and its whereabouts client.
+func newTestController(t *testing.T, pools []*v1alpha1.IPPool, nodeSlices []*v1alpha1.NodeSlicePool, nads ...*k8snetplumbersv1.NetworkAttachmentDefinition) (*Controller, *fake.Clientset) {
+	t.Helper()
+	var objects []runtime.Object
+	for _, pool := range pools {
+		objects = append(objects, pool)
+	}
+	whereaboutsClient := fake.NewSimpleClientset(objects...)
+	nadClient := k8snetplumbersv1fake.NewSimpleClientset()
+
+	whereaboutsInformerFactory := informers.NewSharedInformerFactory(whereaboutsClient, 0)
+	nadInformerFactory := nadinformers.NewSharedInformerFactory(nadClient, 0)
+	c := NewController(context.Background(), whereaboutsClient,
+		whereaboutsInformerFactory.Whereabouts().V1alpha1().IPPools(),
+		nadInformerFactory.K8sCniCncfIo().V1().NetworkAttachmentDefinitions(),
+		whereaboutsInformerFactory.Whereabouts().V1alpha1().NodeSlicePools())
+	for _, pool := range pools {
+		if err := whereaboutsInformerFactory.Whereabouts().V1alpha1().IPPools().Informer().GetIndexer().Add(pool); err != nil {
+			t.Fatalf("failed to add the IPPool to the cache: %v", err)
+		}
+	}
+	for _, nodeSlice := range nodeSlices {
+		if err := whereaboutsInformerFactory.Whereabouts().V1alpha1().NodeSlicePools().Informer().GetIndexer().Add(nodeSlice); err != nil {
+			t.Fatalf("failed to add the NodeSlicePool to the cache: %v", err)
+		}
+	}
+	for _, nad := range nads {
+		if err := nadInformerFactory.K8sCniCncfIo().V1().NetworkAttachmentDefinitions().Informer().GetIndexer().Add(nad); err != nil {
+			t.Fatalf("failed to add the NAD to the cache: %v", err)
+		}
+	}
+	whereaboutsClient.ClearActions()
+	return c, whereaboutsClient
+}
+
+// runSync syncs the given pool, and returns the actions of the controller.
+func runSync(t *testing.T, name string, pools []*v1alpha1.IPPool, nads ...*k8snetplumbersv1.NetworkAttachmentDefinition) []core.Action {
+	t.Helper()
+	c, whereaboutsClient := newTestController(t, pools, nil, nads...)
+	if err := c.syncHandler(context.Background(), poolNamespace+"/"+name); err != nil {
+		t.Fatalf("failed to sync the IPPool: %v", err)
+	}
+	return whereaboutsClient.Actions()
+}
+
+// syncPool syncs the status of the given pool, and returns the status it was updated to, if any. The updates of
+// the metadata of the pool are left to the tests of the owners.
+func syncPool(t *testing.T, name string, pools []*v1alpha1.IPPool, nads ...*k8snetplumbersv1.NetworkAttachmentDefinition) *v1alpha1.IPPoolStatus {
+	t.Helper()
+	var status *v1alpha1.IPPoolStatus
+	for _, action := range runSync(t, name, pools, nads...) {
+		update, ok := action.(core.UpdateAction)
+		if !ok {
+			t.Errorf("unexpected action %v", action)
+			continue
+		}
+		if update.GetSubresource() == "status" {
+			status = &update.GetObject().(*v1alpha1.IPPool).Status
+		}
+	}
+	return status
+}
+
+func expectStatus(t *testing.T, status *v1alpha1.IPPoolStatus, total, allocated, excluded, free int64, exhausted, nearlyExhausted bool) {
+	t.Helper()
+	if status == nil {
+		t.Fatalf("expected the IPPool status to be updated")
+	}
+	if status.Total != total || status.Allocated != allocated || status.Excluded != excluded || status.Free != free {
+		t.Errorf("expected total %d, allocated %d, excluded %d and free %d, got %+v", total, allocated, excluded, free, status)
+	}
+	if status.LastUpdated.IsZero() {
+		t.Errorf("expected the last update time to be set")
+	}
+	if got := meta.IsStatusConditionTrue(status.Conditions, v1alpha1.IPPoolExhausted); got != exhausted {
+		t.Errorf("expected the Exhausted condition to be %t, got %v", exhausted, status.Conditions)
+	}
+	if got := meta.IsStatusConditionTrue(status.Conditions, v1alpha1.IPPoolNearlyExhausted); got != nearlyExhausted {
+		t.Errorf("expected the NearlyExhausted condition to be %t, got %v", nearlyExhausted, status.Conditions)
+	}
+}
+
+func TestPoolStatus(t *testing.T) {
+	status := syncPool(t, "10.0.0.0-24", []*v1alpha1.IPPool{newPool("10.0.0.0-24", "10.0.0.0/24", 1, 2, 3)})
+	expectStatus(t, status, 254, 3, 0, 251, false, false)
+}
+
+func TestPoolStatusUsesTheRangeConfiguration(t *testing.T) {
+	nad := newNad(t, "net1", `"network_name": "net1", "range": "10.0.0.0/24", "range_start": "10.0.0.11", "range_end": "10.0.0.30",
+		"exclude": ["10.0.0.0/28", "10.0.0.12/30", "10.0.0.28/30"]`)
+	status := syncPool(t, "net1-10.0.0.0-24", []*v1alpha1.IPPool{newPool("net1-10.0.0.0-24", "10.0.0.0/24", 16, 17)}, nad)
+	// 10.0.0.11-10.0.0.30 are usable, the overlapping 10.0.0.0/28 and 10.0.0.12/30 exclude 10.0.0.11-10.0.0.15
+	// and 10.0.0.28/30 excludes 10.0.0.28-10.0.0.30
+	expectStatus(t, status, 20, 2, 8, 10, false, false)
+}
+
+func TestExhaustedPoolStatus(t *testing.T) {
+	nad := newNad(t, "net1", `"range": "10.0.0.0/24", "exclude": ["10.0.0.0/25"]`)
+	var offsets []int
+	for offset := 128; offset < 244; offset++ {
+		offsets = append(offsets, offset)
+	}
+	status := syncPool(t, "10.0.0.0-24", []*v1alpha1.IPPool{newPool("10.0.0.0-24", "10.0.0.0/24", offsets...)}, nad)
+	expectStatus(t, status, 254, 116, 127, 11, false, true)
+
+	for offset := 244; offset < 255; offset++ {
+		offsets = append(offsets, offset)
+	}
+	status = syncPool(t, "10.0.0.0-24", []*v1alpha1.IPPool{newPool("10.0.0.0-24", "10.0.0.0/24", offsets...)}, nad)
+	expectStatus(t, status, 254, 127, 127, 0, true, true)
+}
+
+func TestShardedPoolStatus(t *testing.T) {
+	pool := newPool("10.0.0.0-24", "10.0.0.0/24")
+	pool.Spec.ShardSize = 64
+	var pools []*v1alpha1.IPPool
+	for index, offsets := range [][]int{{1, 2}, {64, 65, 66}} {
+		shard := newPool(wbkubernetes.ShardName(pool.GetName(), int64(index)), pool.Spec.Range, offsets...)
+		shard.Labels = map[string]string{wbkubernetes.ParentPoolLabel: pool.GetName()}
+		shard.Spec.Shard = &v1alpha1.IPPoolShard{Parent: pool.GetName(), Start: int64(index) * 64, End: int64(index+1) * 64}
+		pools = append(pools, shard)
+	}
+
+	status := syncPool(t, pool.GetName(), append(pools, pool))
+	expectStatus(t, status, 254, 5, 0, 249, false, false)
+}
+
+func TestUnchangedPoolStatusIsNotUpdated(t *testing.T) {
+	pool := newPool("10.0.0.0-24", "10.0.0.0/24", 1)
+	pool.Status = *syncPool(t, pool.GetName(), []*v1alpha1.IPPool{pool})
+
+	if status := syncPool(t, pool.GetName(), []*v1alpha1.IPPool{pool}); status != nil {
+		t.Errorf("expected the unchanged status not to be updated, got %+v", status)
+	}
+}
+
+func TestPoolStatusUpdatesAreDebounced(t *testing.T) {
+	const allocations = 20
+
+	pool := newPool("10.0.0.0-24", "10.0.0.0/24", 1)
+	pool.Status = *syncPool(t, pool.GetName(), []*v1alpha1.IPPool{pool})
+
+	// a burst of allocations, each of which the controller syncs as the CNI allocates the next one: a status update
+	// would change the resource version the next allocation is conditioned on, and make it conflict
+	for offset := 2; offset <= allocations; offset++ {
+		pool = pool.DeepCopy()
+		pool.Spec.Allocations[fmt.Sprint(offset)] = v1alpha1.IPAllocation{PodRef: fmt.Sprintf("default/pod%d", offset)}
+		if status := syncPool(t, pool.GetName(), []*v1alpha1.IPPool{pool}); status != nil {
+			t.Fatalf("expected no status update during the burst of allocations, got %+v", status)
+		}
+	}
+
+	// once the interval is over, the status catches up with the allocations
+	pool.Status.LastUpdated = metav1.NewTime(time.Now().Add(-minStatusUpdateInterval))
+	status := syncPool(t, pool.GetName(), []*v1alpha1.IPPool{pool})
+	expectStatus(t, status, 254, allocations, 0, 254-allocations, false, false)
+}
+
+func TestIPv6RangeUtilizationSaturates(t *testing.T) {
+	total, excluded, err := rangeUtilization(types.RangeConfiguration{Range: "fd00::/64", OmitRanges: []string{"fd00::/120"}})
+	if err != nil {
+		t.Fatalf("failed to compute the range utilization: %v", err)
+	}
+	if total != math.MaxInt64 || excluded != 255 {
+		t.Errorf("expected a saturated total and 255 excluded IPs, got %d and %d", total, excluded)
+	}
+}
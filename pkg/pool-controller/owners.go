@@ -0,0 +1,160 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package pool_controller
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	nadv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/api/whereabouts.cni.cncf.io/v1alpha1"
+	wbkubernetes "github.com/k8snetworkplumbingwg/whereabouts/pkg/storage/kubernetes"
+)
+
+const (
+	// NetworkNameLabel labels the IPPools with the network name of their network-attachment-definitions.
+	NetworkNameLabel = "whereabouts.cni.cncf.io/network-name"
+	// NetworkAttachmentDefinitionsAnnotation lists the namespace/name of the network-attachment-definitions of an
+	// IPPool, including those of other namespaces, which cannot own it.
+	NetworkAttachmentDefinitionsAnnotation = "whereabouts.cni.cncf.io/network-attachment-definitions"
+	// PoolProtectionFinalizer keeps the IPPools from being deleted while they hold allocations.
+	PoolProtectionFinalizer = "whereabouts.cni.cncf.io/ippool-protection"
+
+	// unownedPoolGracePeriod is how long an empty IPPool of no network-attachment-definition is kept, as the CNI
+	// creates the pools before allocating from them.
+	unownedPoolGracePeriod = time.Minute
+)
+
+var nadKind = nadv1.SchemeGroupVersion.WithKind("NetworkAttachmentDefinition")
+
+// poolNetwork is what the network-attachment-definitions of an IPPool configure.
+type poolNetwork struct {
+	nads        []*nadv1.NetworkAttachmentDefinition
+	networkName string
+}
+
+// withOwners returns a copy of the pool labelled with, annotated with and owned by the network-attachment-definitions
+// of its network, and protected by the finalizer; or nil when the pool is already so. Only the
+// network-attachment-definitions of the namespace of the pool own it, the others are only listed in the annotation.
+func withOwners(pool *v1alpha1.IPPool, network poolNetwork) *v1alpha1.IPPool {
+	updatedPool := pool.DeepCopy()
+
+	var ownerReferences []metav1.OwnerReference
+	for _, ownerReference := range pool.GetOwnerReferences() {
+		if ownerReference.APIVersion != nadKind.GroupVersion().String() || ownerReference.Kind != nadKind.Kind {
+			ownerReferences = append(ownerReferences, ownerReference)
+		}
+	}
+	var nadNames []string
+	for _, nad := range network.nads {
+		nadNames = append(nadNames, nad.GetNamespace()+"/"+nad.GetName())
+		if nad.GetNamespace() == pool.GetNamespace() {
+			ownerReferences = append(ownerReferences, metav1.OwnerReference{
+				APIVersion: nadKind.GroupVersion().String(),
+				Kind:       nadKind.Kind,
+				Name:       nad.GetName(),
+				UID:        nad.GetUID(),
+			})
+		}
+	}
+	sort.Strings(nadNames)
+	updatedPool.SetOwnerReferences(ownerReferences)
+
+	if len(network.nads) > 0 {
+		if updatedPool.Labels == nil {
+			updatedPool.Labels = map[string]string{}
+		}
+		updatedPool.Labels[NetworkNameLabel] = wbkubernetes.LabelValue(network.networkName)
+		if updatedPool.Annotations == nil {
+			updatedPool.Annotations = map[string]string{}
+		}
+		updatedPool.Annotations[NetworkAttachmentDefinitionsAnnotation] = strings.Join(nadNames, ",")
+	} else {
+		delete(updatedPool.Annotations, NetworkAttachmentDefinitionsAnnotation)
+	}
+
+	if !hasFinalizer(updatedPool) {
+		updatedPool.Finalizers = append(updatedPool.Finalizers, PoolProtectionFinalizer)
+	}
+
+	if equality.Semantic.DeepEqual(pool.ObjectMeta, updatedPool.ObjectMeta) {
+		return nil
+	}
+	return updatedPool
+}
+
+// syncFinalizer protects a pool, or a shard, with the finalizer, and releases it once the pool is deleted and holds
+// no allocations. It returns the pool as updated, or nil when it was not updated.
+func (c *Controller) syncFinalizer(ctx context.Context, pool *v1alpha1.IPPool, allocated int64) (*v1alpha1.IPPool, error) {
+	var updatedPool *v1alpha1.IPPool
+	switch {
+	case pool.GetDeletionTimestamp() == nil && !hasFinalizer(pool):
+		updatedPool = pool.DeepCopy()
+		updatedPool.Finalizers = append(updatedPool.Finalizers, PoolProtectionFinalizer)
+	case pool.GetDeletionTimestamp() != nil && hasFinalizer(pool) && allocated == 0:
+		updatedPool = pool.DeepCopy()
+		updatedPool.Finalizers = nil
+		for _, finalizer := range pool.GetFinalizers() {
+			if finalizer != PoolProtectionFinalizer {
+				updatedPool.Finalizers = append(updatedPool.Finalizers, finalizer)
+			}
+		}
+	default:
+		return nil, nil
+	}
+	return c.whereaboutsclientset.WhereaboutsV1alpha1().IPPools(pool.GetNamespace()).Update(ctx, updatedPool, metav1.UpdateOptions{})
+}
+
+// deleteUnownedPool deletes the pool when it is empty and of no network-attachment-definition, once it is older
+// than the grace period. It returns how long to wait before trying again, if the pool is still too young. Only the
+// pools tied to network-attachment-definitions before are deleted: the others may be those of a CNI configuration
+// which is not a network-attachment-definition. The deletion is conditioned on the pool not having changed, so an
+// allocation made meanwhile keeps it.
+func (c *Controller) deleteUnownedPool(ctx context.Context, pool *v1alpha1.IPPool) (time.Duration, error) {
+	_, labelled := pool.GetLabels()[NetworkNameLabel]
+	_, annotated := pool.GetAnnotations()[NetworkAttachmentDefinitionsAnnotation]
+	if !labelled && !annotated {
+		return 0, nil
+	}
+	if age := time.Since(pool.GetCreationTimestamp().Time); age < unownedPoolGracePeriod {
+		return unownedPoolGracePeriod - age, nil
+	}
+	err := c.whereaboutsclientset.WhereaboutsV1alpha1().IPPools(pool.GetNamespace()).Delete(ctx, pool.GetName(), metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{UID: ptr.To(pool.GetUID()), ResourceVersion: ptr.To(pool.GetResourceVersion())},
+	})
+	if errors.IsNotFound(err) || errors.IsConflict(err) {
+		return 0, nil
+	}
+	return 0, err
+}
+
+func hasFinalizer(pool *v1alpha1.IPPool) bool {
+	for _, finalizer := range pool.GetFinalizers() {
+		if finalizer == PoolProtectionFinalizer {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package pool_controller
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	core "k8s.io/client-go/testing"
+
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/api/whereabouts.cni.cncf.io/v1alpha1"
+	wbkubernetes "github.com/k8snetworkplumbingwg/whereabouts/pkg/storage/kubernetes"
+)
+
+// poolUpdates returns the IPPools the actions updated, leaving out the updates of their status.
+func poolUpdates(t *testing.T, actions []core.Action) []*v1alpha1.IPPool {
+	t.Helper()
+	var pools []*v1alpha1.IPPool
+	for _, action := range actions {
+		if update, ok := action.(core.UpdateAction); ok && update.GetSubresource() == "" {
+			pools = append(pools, update.GetObject().(*v1alpha1.IPPool))
+		}
+	}
+	return pools
+}
+
+func deletions(actions []core.Action) []core.DeleteAction {
+	var deleteActions []core.DeleteAction
+	for _, action := range actions {
+		if deleteAction, ok := action.(core.DeleteAction); ok {
+			deleteActions = append(deleteActions, deleteAction)
+		}
+	}
+	return deleteActions
+}
+
+func TestPoolIsOwnedByTheNetworkAttachmentDefinitions(t *testing.T) {
+	ipamParameters := `"network_name": "net1", "range": "10.0.0.0/24"`
+	localNad := newNad(t, "net1", ipamParameters)
+	localNad.Namespace = poolNamespace
+	localNad.UID = k8stypes.UID("local-nad-uid")
+	otherNad := newNad(t, "net1", ipamParameters)
+	pool := newPool("net1-10.0.0.0-24", "10.0.0.0/24", 1)
+	pool.OwnerReferences = []metav1.OwnerReference{{APIVersion: "v1", Kind: "ConfigMap", Name: "kept", UID: "configmap-uid"}}
+
+	updates := poolUpdates(t, runSync(t, pool.GetName(), []*v1alpha1.IPPool{pool}, localNad, otherNad))
+	if len(updates) != 1 {
+		t.Fatalf("expected the owners of the IPPool to be updated once, got %v", updates)
+	}
+	updatedPool := updates[0]
+	ownerReferences := updatedPool.GetOwnerReferences()
+	if len(ownerReferences) != 2 || ownerReferences[0].Name != "kept" ||
+		ownerReferences[1].Kind != "NetworkAttachmentDefinition" || ownerReferences[1].UID != localNad.UID {
+		t.Errorf("expected the other owners to be kept and the NAD of the namespace of the pool to be added, got %v", ownerReferences)
+	}
+	if nads := updatedPool.GetAnnotations()[NetworkAttachmentDefinitionsAnnotation]; nads != "default/net1,kube-system/net1" {
+		t.Errorf("expected the NADs of every namespace to be listed, got %q", nads)
+	}
+	if networkName := updatedPool.GetLabels()[NetworkNameLabel]; networkName != "net1" {
+		t.Errorf("expected the network name label, got %q", networkName)
+	}
+	if !hasFinalizer(updatedPool) {
+		t.Errorf("expected the finalizer to be added, got %v", updatedPool.GetFinalizers())
+	}
+
+	// the pool is only updated again when its network changes
+	if updates := poolUpdates(t, runSync(t, pool.GetName(), []*v1alpha1.IPPool{updatedPool}, localNad, otherNad)); len(updates) != 0 {
+		t.Errorf("expected the owned IPPool not to be updated, got %v", updates)
+	}
+}
+
+func TestDeletedPoolIsKeptWhileAllocated(t *testing.T) {
+	pool := newPool("10.0.0.0-24", "10.0.0.0/24", 1)
+	pool.Finalizers = []string{PoolProtectionFinalizer, "other"}
+	pool.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	if actions := runSync(t, pool.GetName(), []*v1alpha1.IPPool{pool}); len(actions) != 0 {
+		t.Errorf("expected the finalizer of the allocated IPPool to be kept, got %v", actions)
+	}
+
+	pool.Spec.Allocations = nil
+	updates := poolUpdates(t, runSync(t, pool.GetName(), []*v1alpha1.IPPool{pool}))
+	if len(updates) != 1 || len(updates[0].GetFinalizers()) != 1 || updates[0].GetFinalizers()[0] != "other" {
+		t.Errorf("expected only the finalizer of the empty IPPool to be released, got %v", updates)
+	}
+}
+
+func TestDeletedShardsAreReleasedWithTheirPool(t *testing.T) {
+	pool := newPool("10.0.0.0-24", "10.0.0.0/24")
+	pool.Spec.ShardSize = 64
+	pool.Finalizers = []string{PoolProtectionFinalizer}
+	pool.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	shard := newPool(wbkubernetes.ShardName(pool.GetName(), 0), pool.Spec.Range, 1)
+	shard.Labels = map[string]string{wbkubernetes.ParentPoolLabel: pool.GetName()}
+	shard.Spec.Shard = &v1alpha1.IPPoolShard{Parent: pool.GetName(), Start: 0, End: 64}
+	shard.Finalizers = []string{PoolProtectionFinalizer}
+	shard.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+
+	if actions := runSync(t, pool.GetName(), []*v1alpha1.IPPool{pool, shard}); len(actions) != 0 {
+		t.Errorf("expected the finalizers to be kept while a shard is allocated, got %v", actions)
+	}
+
+	shard.Spec.Allocations = nil
+	updates := poolUpdates(t, runSync(t, pool.GetName(), []*v1alpha1.IPPool{pool, shard}))
+	if len(updates) != 2 {
+		t.Fatalf("expected the finalizers of the shard and of the pool to be released, got %v", updates)
+	}
+	for _, updatedPool := range updates {
+		if hasFinalizer(updatedPool) {
+			t.Errorf("expected the finalizer of IPPool %s to be released", updatedPool.GetName())
+		}
+	}
+
+	// the shards left once their pool is gone are released too
+	if updates := poolUpdates(t, runSync(t, pool.GetName(), []*v1alpha1.IPPool{shard})); len(updates) != 1 {
+		t.Errorf("expected the finalizer of the orphaned shard to be released, got %v", updates)
+	}
+}
+
+func TestUnownedEmptyPoolIsDeleted(t *testing.T) {
+	pool := newPool("10.0.0.0-24", "10.0.0.0/24")
+	pool.UID = k8stypes.UID("pool-uid")
+	pool.CreationTimestamp = metav1.NewTime(time.Now().Add(-2 * unownedPoolGracePeriod))
+	if deleteActions := deletions(runSync(t, pool.GetName(), []*v1alpha1.IPPool{pool})); len(deleteActions) != 0 {
+		t.Errorf("expected the IPPool never tied to a NAD to be kept, got %v", deleteActions)
+	}
+
+	pool.Labels = map[string]string{NetworkNameLabel: ""}
+	pool.Annotations = map[string]string{NetworkAttachmentDefinitionsAnnotation: "default/net1"}
+	deleteActions := deletions(runSync(t, pool.GetName(), []*v1alpha1.IPPool{pool}))
+	if len(deleteActions) != 1 || deleteActions[0].GetName() != pool.GetName() {
+		t.Fatalf("expected the unowned empty IPPool to be deleted, got %v", deleteActions)
+	}
+	preconditions := deleteActions[0].GetDeleteOptions().Preconditions
+	if preconditions == nil || *preconditions.UID != pool.UID || *preconditions.ResourceVersion != pool.ResourceVersion {
+		t.Errorf("expected the deletion to be conditioned on the unchanged IPPool, got %+v", preconditions)
+	}
+
+	pool.CreationTimestamp = metav1.Now()
+	if deleteActions := deletions(runSync(t, pool.GetName(), []*v1alpha1.IPPool{pool})); len(deleteActions) != 0 {
+		t.Errorf("expected the new IPPool to be kept for the grace period, got %v", deleteActions)
+	}
+
+	pool.CreationTimestamp = metav1.NewTime(time.Now().Add(-2 * unownedPoolGracePeriod))
+	nad := newNad(t, "net1", `"range": "10.0.0.0/24"`)
+	if deleteActions := deletions(runSync(t, pool.GetName(), []*v1alpha1.IPPool{pool}, nad)); len(deleteActions) != 0 {
+		t.Errorf("expected the empty IPPool of a NAD to be kept, got %v", deleteActions)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package pool_controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	nadv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/config"
+	wbkubernetes "github.com/k8snetworkplumbingwg/whereabouts/pkg/storage/kubernetes"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+const whereaboutsIPAMType = "whereabouts"
+
+// networkPool is an IPPool a network-attachment-definition allocates from, and the configuration of its range.
+type networkPool struct {
+	identifier wbkubernetes.PoolIdentifier
+	rangeConf  types.RangeConfiguration
+}
+
+// nadIPAMConfiguration returns the whereabouts IPAM configuration of a network-attachment-definition whose pools are
+// IPPools, or nil for the other network-attachment-definitions. It fails when the configuration of a
+// network-attachment-definition using whereabouts cannot be parsed.
+func nadIPAMConfiguration(nad *nadv1.NetworkAttachmentDefinition) (*types.IPAMConfig, error) {
+	ipamConf, err := config.LoadIPAMConfiguration([]byte(nad.Spec.Config), "", whereaboutsConfigPath)
+	if err != nil {
+		if !usesWhereabouts(nad) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if ipamConf.GetDatastore() != types.DatastoreKubernetes || ipamConf.PerAddressAllocations {
+		return nil, nil
+	}
+	return ipamConf, nil
+}
+
+// usesWhereabouts returns whether a plugin of the network-attachment-definition has a whereabouts IPAM, telling
+// the configurations of whereabouts which cannot be parsed from those of other IPAMs.
+func usesWhereabouts(nad *nadv1.NetworkAttachmentDefinition) bool {
+	type plugin struct {
+		IPAM struct {
+			Type string `json:"type"`
+		} `json:"ipam"`
+	}
+	var conf struct {
+		plugin
+		Plugins []plugin `json:"plugins"`
+	}
+	if err := json.Unmarshal([]byte(nad.Spec.Config), &conf); err != nil {
+		return false
+	}
+	for _, p := range append(conf.Plugins, conf.plugin) {
+		if p.IPAM.Type == whereaboutsIPAMType {
+			return true
+		}
+	}
+	return false
+}
+
+// networkPools returns the IPPools of the network of an IPAM configuration, in the given namespace: those of its
+// ranges or, with node slices, those of the slices allocated to the nodes.
+func (c *Controller) networkPools(namespace string, ipamConf *types.IPAMConfig) []networkPool {
+	var pools []networkPool
+	if ipamConf.NodeSliceSize == "" {
+		for _, rangeConf := range ipamConf.IPRanges {
+			pools = append(pools, networkPool{
+				identifier: wbkubernetes.PoolIdentifier{IpRange: rangeConf.Range, NetworkName: ipamConf.NetworkName},
+				rangeConf:  rangeConf,
+			})
+		}
+		return pools
+	}
+
+	nodeSliceName := ipamConf.NetworkName
+	if nodeSliceName == wbkubernetes.UnnamedNetwork {
+		nodeSliceName = ipamConf.Name
+	}
+	nodeSlice, err := c.nodeSlicePoolLister.NodeSlicePools(namespace).Get(nodeSliceName)
+	if err != nil {
+		// the node slices are not allocated yet, the pools are provisioned once they are
+		return nil
+	}
+	for _, allocation := range nodeSlice.Status.Allocations {
+		if allocation.NodeName == "" {
+			continue
+		}
+		pools = append(pools, networkPool{
+			identifier: wbkubernetes.PoolIdentifier{IpRange: allocation.SliceRange, NodeName: allocation.NodeName, NetworkName: ipamConf.NetworkName},
+			rangeConf:  types.RangeConfiguration{Range: allocation.SliceRange},
+		})
+	}
+	return pools
+}
+
+func (c *Controller) onNadEvent(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	c.nadWorkqueue.Add(key)
+}
+
+// requeueNads queues every network-attachment-definition, as the node slices their pools are made of changed.
+func (c *Controller) requeueNads(_ interface{}) {
+	nads, err := c.nadLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("failed to list the network-attachment-definitions: %v", err))
+		return
+	}
+	for _, nad := range nads {
+		c.onNadEvent(nad)
+	}
+}
+
+// provisionHandler creates the missing IPPools of a network-attachment-definition, so the CNI finds them when it
+// allocates from them rather than racing with the other nodes to create them.
+func (c *Controller) provisionHandler(ctx context.Context, key string) error {
+	logger := klog.LoggerWithValues(klog.FromContext(ctx), "networkAttachmentDefinition", key)
+
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("invalid resource key: %s", key))
+		return nil
+	}
+	nad, err := c.nadLister.NetworkAttachmentDefinitions(namespace).Get(name)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	ipamConf, err := nadIPAMConfiguration(nad)
+	if err != nil {
+		// the configuration will not get any better by retrying
+		logger.Error(err, "failed to parse the IPAM configuration of the network-attachment-definition")
+		return nil
+	}
+	if ipamConf == nil {
+		return nil
+	}
+
+	poolNamespace := wbkubernetes.NetworkNamespace(*ipamConf, nad.GetNamespace())
+	for _, pool := range c.networkPools(poolNamespace, ipamConf) {
+		poolName := wbkubernetes.IPPoolName(pool.identifier)
+		if _, err := c.ipPoolLister.IPPools(poolNamespace).Get(poolName); err == nil {
+			continue
+		} else if !errors.IsNotFound(err) {
+			return err
+		}
+		newPool := wbkubernetes.NewIPPool(poolNamespace, pool.identifier, *ipamConf)
+		_, err := c.whereaboutsclientset.WhereaboutsV1alpha1().IPPools(poolNamespace).Create(ctx, newPool, metav1.CreateOptions{})
+		if errors.IsAlreadyExists(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		logger.V(4).Info("Provisioned the IPPool", "namespace", poolNamespace, "name", poolName)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package pool_controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	core "k8s.io/client-go/testing"
+
+	k8snetplumbersv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/api/whereabouts.cni.cncf.io/v1alpha1"
+	wbkubernetes "github.com/k8snetworkplumbingwg/whereabouts/pkg/storage/kubernetes"
+)
+
+// provision provisions the pools of the given network-attachment-definition, and returns the IPPools created.
+func provision(t *testing.T, nad *k8snetplumbersv1.NetworkAttachmentDefinition, pools []*v1alpha1.IPPool, nodeSlices ...*v1alpha1.NodeSlicePool) []*v1alpha1.IPPool {
+	t.Helper()
+	c, whereaboutsClient := newTestController(t, pools, nodeSlices, nad)
+	if err := c.provisionHandler(context.Background(), nad.GetNamespace()+"/"+nad.GetName()); err != nil {
+		t.Fatalf("failed to provision the IPPools: %v", err)
+	}
+	var created []*v1alpha1.IPPool
+	for _, action := range whereaboutsClient.Actions() {
+		create, ok := action.(core.CreateAction)
+		if !ok {
+			t.Errorf("unexpected action %v", action)
+			continue
+		}
+		created = append(created, create.GetObject().(*v1alpha1.IPPool))
+	}
+	return created
+}
+
+func TestProvisionsThePoolsOfTheRanges(t *testing.T) {
+	t.Setenv(wbkubernetes.NamespaceEnvVariable, "")
+	nad := newNad(t, "net1", `"network_name": "net1", "pool_shard_size": 64,
+		"ipRanges": [{"range": "10.0.0.0/24"}, {"range": "10.0.1.0/26"}, {"range": "10.0.2.0/24"}]`)
+	existingPool := newPool("net1-10.0.2.0-24", "10.0.2.0/24")
+
+	created := provision(t, nad, []*v1alpha1.IPPool{existingPool})
+	if len(created) != 2 {
+		t.Fatalf("expected the two missing IPPools to be created, got %v", created)
+	}
+	for idx, expected := range []struct {
+		name      string
+		shardSize int64
+	}{{"net1-10.0.0.0-24", 64}, {"net1-10.0.1.0-26", 0}} {
+		if created[idx].GetNamespace() != poolNamespace || created[idx].GetName() != expected.name ||
+			created[idx].Spec.ShardSize != expected.shardSize {
+			t.Errorf("expected IPPool %s/%s of shard size %d, got %s/%s of shard size %d", poolNamespace, expected.name,
+				expected.shardSize, created[idx].GetNamespace(), created[idx].GetName(), created[idx].Spec.ShardSize)
+		}
+	}
+}
+
+func TestProvisionsThePoolsOfTenantNamespaces(t *testing.T) {
+	nad := newNad(t, "net1", `"network_name": "net1", "range": "10.0.0.0/24", "tenant_namespaces": true`)
+	nad.Namespace = "tenant-a"
+
+	created := provision(t, nad, nil)
+	if len(created) != 1 || created[0].GetNamespace() != "tenant-a" || created[0].GetLabels()[wbkubernetes.TenantNamespaceLabel] != "true" {
+		t.Errorf("expected a pool of the tenant namespace, got %v", created)
+	}
+}
+
+func TestDoesNotProvisionOtherDatastores(t *testing.T) {
+	for _, ipamParameters := range []string{
+		`"range": "10.0.0.0/24", "per_address_allocations": true`,
+		`"range": "10.0.0.0/24", "datastore": "ipaddress"`,
+	} {
+		if created := provision(t, newNad(t, "net1", ipamParameters), nil); len(created) != 0 {
+			t.Errorf("expected no IPPool to be created for %s, got %v", ipamParameters, created)
+		}
+	}
+}
+
+func TestProvisionsThePoolsOfTheNodeSlices(t *testing.T) {
+	t.Setenv(wbkubernetes.NamespaceEnvVariable, "")
+	nad := newNad(t, "net1", `"network_name": "net1", "range": "10.0.0.0/16", "node_slice_size": "/24"`)
+	nodeSlice := &v1alpha1.NodeSlicePool{
+		ObjectMeta: metav1.ObjectMeta{Name: "net1", Namespace: poolNamespace},
+		Spec:       v1alpha1.NodeSlicePoolSpec{Range: "10.0.0.0/16", SliceSize: "/24"},
+		Status: v1alpha1.NodeSlicePoolStatus{Allocations: []v1alpha1.NodeSliceAllocation{
+			{NodeName: "node1", SliceRange: "10.0.0.0/24"},
+			{NodeName: "", SliceRange: "10.0.1.0/24"},
+			{NodeName: "node2", SliceRange: "10.0.2.0/24"},
+		}},
+	}
+
+	if created := provision(t, nad, nil); len(created) != 0 {
+		t.Errorf("expected no IPPool to be created before the node slices are allocated, got %v", created)
+	}
+
+	created := provision(t, nad, nil, nodeSlice)
+	if len(created) != 2 || created[0].GetName() != "net1-node1-10.0.0.0-24" || created[1].GetName() != "net1-node2-10.0.2.0-24" {
+		t.Fatalf("expected the IPPools of the allocated node slices to be created, got %v", created)
+	}
+
+	// the empty pools of the node slices belong to the network-attachment-definition, and are kept
+	pool := created[0]
+	pool.ResourceVersion = "1"
+	pool.CreationTimestamp = metav1.NewTime(time.Now().Add(-2 * unownedPoolGracePeriod))
+	c, whereaboutsClient := newTestController(t, []*v1alpha1.IPPool{pool}, []*v1alpha1.NodeSlicePool{nodeSlice}, nad)
+	if err := c.syncHandler(context.Background(), pool.GetNamespace()+"/"+pool.GetName()); err != nil {
+		t.Fatalf("failed to sync the IPPool: %v", err)
+	}
+	if deleteActions := deletions(whereaboutsClient.Actions()); len(deleteActions) != 0 {
+		t.Errorf("expected the IPPool of the node slice to be kept, got %v", deleteActions)
+	}
+	if updates := poolUpdates(t, whereaboutsClient.Actions()); len(updates) != 1 || updates[0].GetLabels()[NetworkNameLabel] != "net1" {
+		t.Errorf("expected the IPPool of the node slice to be tied to its network, got %v", updates)
+	}
+}
+
+func TestOnlyTheWhereaboutsConfigurationsFailToParse(t *testing.T) {
+	for config, fails := range map[string]bool{
+		`{"cniVersion": "0.3.1", "name": "net1", "type": "macvlan", "ipam": {"type": "whereabouts", "range": "not a range"}}`: true,
+		`{"cniVersion": "0.3.1", "name": "net1", "plugins": [{"type": "macvlan", "ipam": {"type": "whereabouts"}}]}`:          true,
+		`{"cniVersion": "0.3.1", "name": "net1", "type": "macvlan", "ipam": {"type": "host-local"}}`:                          false,
+		`{"cniVersion": "0.3.1", "name": "net1", "type": "bridge"}`:                                                           false,
+		``: false,
+	} {
+		nad := &k8snetplumbersv1.NetworkAttachmentDefinition{Spec: k8snetplumbersv1.NetworkAttachmentDefinitionSpec{Config: config}}
+		ipamConf, err := nadIPAMConfiguration(nad)
+		if ipamConf != nil || (err != nil) != fails {
+			t.Errorf("expected the configuration %q to fail to parse: %t, got %v and %v", config, fails, ipamConf, err)
+		}
+	}
+}
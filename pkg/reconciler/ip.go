@@ -48,5 +48,10 @@ func ReconcileIPs(errorChan chan error) {
 		return
 	}
 
+	if err := ipReconcileLoop.ReconcilePoolLeases(); err != nil {
+		errorChan <- err
+		return
+	}
+
 	errorChan <- nil
 }
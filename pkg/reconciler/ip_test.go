@@ -23,6 +23,7 @@ import (
 	"net"
 	"strings"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -31,6 +32,7 @@ import (
 	"github.com/k8snetworkplumbingwg/whereabouts/pkg/api/whereabouts.cni.cncf.io/v1alpha1"
 	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
 
+	coordinationv1 "k8s.io/api/coordination/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -39,7 +41,9 @@ import (
 
 	wbclient "github.com/k8snetworkplumbingwg/whereabouts/pkg/generated/clientset/versioned"
 	fakewbclient "github.com/k8snetworkplumbingwg/whereabouts/pkg/generated/clientset/versioned/fake"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage"
 	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage/kubernetes"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage/memory"
 )
 
 func TestIPReconciler(t *testing.T) {
@@ -298,6 +302,33 @@ var _ = Describe("Whereabouts IP reconciler", func() {
 		})
 	})
 
+	Context("reconciling cluster wide IPs of a named network", func() {
+		const networkName = "net-1"
+
+		var wbClient wbclient.Interface
+
+		BeforeEach(func() {
+			pod := generatePod(namespace, "pod1", ipInNetwork{ip: firstIPInRange, networkName: networkName})
+			k8sClientSet = fakek8sclient.NewSimpleClientset(pod)
+
+			// the name of the reservation is prefixed with the network name, the IP being read from its spec
+			reservation := generateClusterWideIPReservation(namespace, networkName+"-"+firstIPInRange, namespace+"/pod1")
+			reservation.Spec.IP = firstIPInRange
+			reservation.Spec.NetworkName = networkName
+			wbClient = fakewbclient.NewSimpleClientset(generateIPPoolSpec(ipRange, namespace, "pool1", pod.GetName()), reservation)
+		})
+
+		It("will not delete the IP address of a live pod", func() {
+			newReconciler, err := NewReconcileLooperWithClient(kubernetes.NewKubernetesClient(wbClient, k8sClientSet))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(newReconciler.ReconcileOverlappingIPAddresses()).To(Succeed())
+
+			clusterWideIPAllocations, err := wbClient.WhereaboutsV1alpha1().OverlappingRangeIPReservations(namespace).List(context.TODO(), metav1.ListOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(clusterWideIPAllocations.Items).To(HaveLen(1))
+		})
+	})
+
 	Context("reconciling cluster wide IPs - overlapping IPs (ipv6)", func() {
 		const (
 			numberOfPods       = 1
@@ -394,20 +425,6 @@ var _ = Describe("Whereabouts IP reconciler", func() {
 	})
 })
 
-// mock the pool
-type dummyPool struct {
-	orphans []types.IPReservation
-	pool    v1alpha1.IPPool
-}
-
-func (dp dummyPool) Allocations() []types.IPReservation {
-	return dp.orphans
-}
-
-func (dp dummyPool) Update(context.Context, []types.IPReservation) error {
-	return nil
-}
-
 var _ = Describe("IPReconciler", func() {
 	var ipReconciler *ReconcileLooper
 
@@ -443,9 +460,8 @@ var _ = Describe("IPReconciler", func() {
 			podRef := "default/pod1"
 			reservations := generateIPReservation(firstIPInRange, podRef)
 
-			pool := generateIPPool(ipCIDR, podRef)
 			orphanedIPAddr := OrphanedIPReservations{
-				Pool:        dummyPool{orphans: reservations, pool: pool},
+				Pool:        inMemoryPool(ipCIDR, reservations),
 				Allocations: reservations,
 			}
 
@@ -463,9 +479,8 @@ var _ = Describe("IPReconciler", func() {
 				podRef := "default/pod2"
 				reservations := generateIPReservation("192.168.14.2", podRef)
 
-				pool := generateIPPool(ipCIDR, podRef, "default/pod2", "default/pod3")
 				orphanedIPAddr := OrphanedIPReservations{
-					Pool:        dummyPool{orphans: reservations, pool: pool},
+					Pool:        inMemoryPool(ipCIDR, reservations),
 					Allocations: reservations,
 				}
 
@@ -481,6 +496,51 @@ var _ = Describe("IPReconciler", func() {
 	})
 })
 
+var _ = Describe("IP pool leases reconciler", func() {
+	const (
+		namespace = "kube-system"
+		ipRange   = "10.20.0.0/24"
+	)
+
+	It("deletes the released leases of deleted pools only", func() {
+		pool := generateIPPoolSpec(ipRange, namespace, "live-pool")
+		k8sClientSet := fakek8sclient.NewSimpleClientset(
+			generatePoolLease(namespace, "live-pool", ""),
+			generatePoolLease(namespace, "deleted-pool", ""),
+			generatePoolLease(namespace, "held-deleted-pool", "node1/pod1"),
+			&coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "unrelated"}})
+		wbClientSet := fakewbclient.NewSimpleClientset(pool)
+
+		reconcileLooper, err := NewReconcileLooperWithClient(kubernetes.NewKubernetesClient(wbClientSet, k8sClientSet))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reconcileLooper.ReconcilePoolLeases()).To(Succeed())
+
+		leases, err := k8sClientSet.CoordinationV1().Leases(namespace).List(context.TODO(), metav1.ListOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		var leaseNames []string
+		for _, lease := range leases.Items {
+			leaseNames = append(leaseNames, lease.GetName())
+		}
+		Expect(leaseNames).To(ConsistOf("live-pool", "held-deleted-pool", "unrelated"))
+	})
+})
+
+func generatePoolLease(namespace string, poolName string, holder string) *coordinationv1.Lease {
+	leaseDuration := int32(15)
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      poolName,
+			Labels:    map[string]string{kubernetes.PoolLeaseLabel: "true"},
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &holder,
+			LeaseDurationSeconds: &leaseDuration,
+			RenewTime:            &metav1.MicroTime{Time: time.Now()},
+		},
+	}
+}
+
 func generateIPPoolSpec(ipRange string, namespace string, poolName string, podNames ...string) *v1alpha1.IPPool {
 	allocations := map[string]v1alpha1.IPAllocation{}
 	for i, podName := range podNames {
@@ -566,18 +626,13 @@ func generatePodNetworkStatusAnnotation(ipNetworks ...ipInNetwork) string {
 	return string(networkStatusStr)
 }
 
-func generateIPPool(cidr string, podRefs ...string) v1alpha1.IPPool {
-	allocations := map[string]v1alpha1.IPAllocation{}
-	for i, podRef := range podRefs {
-		allocations[fmt.Sprintf("%d", i)] = v1alpha1.IPAllocation{PodRef: podRef}
-	}
-
-	return v1alpha1.IPPool{
-		Spec: v1alpha1.IPPoolSpec{
-			Range:       cidr,
-			Allocations: allocations,
-		},
-	}
+// inMemoryPool returns a pool of an in-memory store holding the given reservations.
+func inMemoryPool(cidr string, reservations []types.IPReservation) storage.IPPool {
+	store := memory.New()
+	store.SetAllocations(storage.PoolIdentifier{IpRange: cidr}, reservations)
+	pool, err := store.GetIPPool(context.Background(), storage.PoolIdentifier{IpRange: cidr})
+	Expect(err).NotTo(HaveOccurred())
+	return pool
 }
 
 func generateIPReservation(ip string, podRef string) []types.IPReservation {
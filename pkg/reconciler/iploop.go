@@ -23,7 +23,9 @@ import (
 	"strings"
 	"time"
 
+	coordinationv1 "k8s.io/api/coordination/v1"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 
 	whereaboutsv1alpha1 "github.com/k8snetworkplumbingwg/whereabouts/pkg/api/whereabouts.cni.cncf.io/v1alpha1"
 	"github.com/k8snetworkplumbingwg/whereabouts/pkg/logging"
@@ -37,6 +39,7 @@ type ReconcileLooper struct {
 	liveWhereaboutsPods    map[string]podWrapper
 	orphanedIPs            []OrphanedIPReservations
 	orphanedClusterWideIPs []whereaboutsv1alpha1.OverlappingRangeIPReservation
+	stalePoolLeases        []coordinationv1.Lease
 }
 
 type OrphanedIPReservations struct {
@@ -77,6 +80,10 @@ func NewReconcileLooperWithClient(k8sClient *kubernetes.Client) (*ReconcileLoope
 	if err := looper.findClusterWideIPReservations(); err != nil {
 		return nil, err
 	}
+
+	if err := looper.findStalePoolLeases(ipPools); err != nil {
+		return nil, err
+	}
 	return looper, nil
 }
 
@@ -233,11 +240,19 @@ func (rl *ReconcileLooper) findClusterWideIPReservations() error {
 	}
 
 	for _, clusterWideIPReservation := range clusterWideIPReservations {
-		ip := clusterWideIPReservation.GetName()
-		// De-normalize the IP
-		// In the UpdateOverlappingRangeAllocation function, the IP address is created with a "normalized" name to comply with the k8s api.
-		// We must denormalize here in order to properly look up the IP address in the regular format, which pods use.
-		denormalizedip := strings.ReplaceAll(ip, "-", ":")
+		denormalizedip := clusterWideIPReservation.Spec.IP
+		if denormalizedip == "" {
+			// the reservations made before the IP was recorded in their spec are named after it
+			ip := clusterWideIPReservation.GetName()
+			// De-normalize the IP
+			// In the UpdateOverlappingRangeAllocation function, the IP address is created with a "normalized" name to comply with the k8s api.
+			// We must denormalize here in order to properly look up the IP address in the regular format, which pods use.
+			denormalizedip = strings.ReplaceAll(ip, "-", ":")
+			// the reservations whose name is hashed hold their IP in an annotation
+			if annotatedIP, ok := clusterWideIPReservation.GetAnnotations()[kubernetes.IPAnnotation]; ok {
+				denormalizedip = annotatedIP
+			}
+		}
 
 		podRef := clusterWideIPReservation.Spec.PodRef
 
@@ -267,3 +282,66 @@ func (rl ReconcileLooper) ReconcileOverlappingIPAddresses() error {
 	}
 	return nil
 }
+
+// findStalePoolLeases finds the leases which protected IP pools which no longer exist, and are not held anymore.
+func (rl *ReconcileLooper) findStalePoolLeases(ipPools []storage.IPPool) error {
+	poolLeases, err := rl.k8sClient.ListPoolLeases()
+	if err != nil {
+		return logging.Errorf("failed to list the IP pool leases: %v", err)
+	}
+
+	existingPools := map[string]struct{}{}
+	for _, pool := range ipPools {
+		// the leases are named after the IPPools, sharded or not
+		if k8sPool, ok := pool.(interface {
+			Name() string
+			Namespace() string
+		}); ok {
+			existingPools[k8sPool.Namespace()+"/"+k8sPool.Name()] = struct{}{}
+		}
+	}
+
+	now := time.Now()
+	for _, lease := range poolLeases {
+		if _, ok := existingPools[lease.GetNamespace()+"/"+lease.GetName()]; ok {
+			continue
+		}
+		if isLeaseHeld(lease, now) {
+			logging.Debugf("the lease %s/%s of a deleted IP pool is still held", lease.GetNamespace(), lease.GetName())
+			continue
+		}
+		rl.stalePoolLeases = append(rl.stalePoolLeases, lease)
+	}
+	return nil
+}
+
+func isLeaseHeld(lease coordinationv1.Lease, now time.Time) bool {
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity == "" {
+		return false
+	}
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	expiry := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+	return now.Before(expiry)
+}
+
+// ReconcilePoolLeases deletes the leases of the IP pools which no longer exist.
+func (rl ReconcileLooper) ReconcilePoolLeases() error {
+	var failedLeases []string
+
+	for idx := range rl.stalePoolLeases {
+		lease := &rl.stalePoolLeases[idx]
+		if err := rl.k8sClient.DeleteLease(lease); err != nil && !apierrors.IsNotFound(err) {
+			logging.Errorf("failed to remove the IP pool lease %s/%s: %v", lease.GetNamespace(), lease.GetName(), err)
+			failedLeases = append(failedLeases, lease.GetName())
+			continue
+		}
+		logging.Verbosef("removed the lease of deleted IP pool [%s/%s]", lease.GetNamespace(), lease.GetName())
+	}
+
+	if len(failedLeases) != 0 {
+		return logging.Errorf("could not remove the IP pool leases: %v", failedLeases)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	cnitypes "github.com/containernetworking/cni/pkg/types"
+)
+
+// BackendUnavailableError is returned when the storage backend cannot be reached.
+type BackendUnavailableError struct {
+	Err error
+}
+
+func (e *BackendUnavailableError) Error() string {
+	return fmt.Sprintf("storage backend unavailable: %v", e.Err)
+}
+
+func (e *BackendUnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// CNIErrorCode tells the runtime to try again later.
+func (e *BackendUnavailableError) CNIErrorCode() uint {
+	return cnitypes.ErrTryAgainLater
+}
+
+// UpdateConflictError is returned when a pool could not be updated because of concurrent writers, after all the
+// retries were exhausted.
+type UpdateConflictError struct {
+	Err error
+	// Attempts and Conflicts count the attempts made, and how many of them conflicted, when known.
+	Attempts  int
+	Conflicts int
+}
+
+func (e *UpdateConflictError) Error() string {
+	if e.Attempts > 0 {
+		return fmt.Sprintf("could not update the pool due to concurrent updates (%d attempts, %d conflicts): %v", e.Attempts, e.Conflicts, e.Err)
+	}
+	return fmt.Sprintf("could not update the pool due to concurrent updates: %v", e.Err)
+}
+
+func (e *UpdateConflictError) Unwrap() error {
+	return e.Err
+}
+
+// CNIErrorCode tells the runtime to try again later.
+func (e *UpdateConflictError) CNIErrorCode() uint {
+	return cnitypes.ErrTryAgainLater
+}
+
+// LeaseTimeoutError is returned when the lease protecting the pools could not be acquired in time.
+type LeaseTimeoutError struct {
+	Err error
+}
+
+func (e *LeaseTimeoutError) Error() string {
+	return fmt.Sprintf("time limit exceeded while waiting to become leader: %v", e.Err)
+}
+
+func (e *LeaseTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// CNIErrorCode tells the runtime to try again later.
+func (e *LeaseTimeoutError) CNIErrorCode() uint {
+	return cnitypes.ErrTryAgainLater
+}
+
+// OverlappingRangeReservedError is returned when reserving an IP which another pod interface already reserved,
+// possibly from an overlapping range.
+type OverlappingRangeReservedError struct {
+	IP  net.IP
+	Err error
+}
+
+func (e *OverlappingRangeReservedError) Error() string {
+	return fmt.Sprintf("IP %s is already reserved from an overlapping range: %v", e.IP, e.Err)
+}
+
+func (e *OverlappingRangeReservedError) Unwrap() error {
+	return e.Err
+}
+
+// IPReservedElsewhereError is returned by a pool update when an IP is held outside of the pool, by another range of
+// the network or by another allocator altogether. The allocation loop leaves the IP out, and allocates another one.
+type IPReservedElsewhereError struct {
+	IP  net.IP
+	Err error
+}
+
+func (e *IPReservedElsewhereError) Error() string {
+	return fmt.Sprintf("IP %s is already reserved outside of the pool: %v", e.IP, e.Err)
+}
+
+func (e *IPReservedElsewhereError) Unwrap() error {
+	return e.Err
+}
+
+// OverlappingRangeChangedError is returned when handing over an overlapping range reservation which was changed, or
+// released, since it was read. It is temporary: the allocation loop reads the reservation again.
+type OverlappingRangeChangedError struct {
+	IP  net.IP
+	Err error
+}
+
+func (e *OverlappingRangeChangedError) Error() string {
+	return fmt.Sprintf("the overlapping range reservation of IP %s changed since it was read: %v", e.IP, e.Err)
+}
+
+func (e *OverlappingRangeChangedError) Unwrap() error {
+	return e.Err
+}
+
+// Temporary tells the allocation loop to retry.
+func (e *OverlappingRangeChangedError) Temporary() bool {
+	return true
+}
+
+// InvalidConfigError is returned when the IPAM configuration cannot be acted upon.
+type InvalidConfigError struct {
+	Err error
+}
+
+func (e *InvalidConfigError) Error() string {
+	return fmt.Sprintf("invalid IPAM configuration: %v", e.Err)
+}
+
+func (e *InvalidConfigError) Unwrap() error {
+	return e.Err
+}
+
+// CNIErrorCode returns the CNI error code of an invalid network configuration.
+func (e *InvalidConfigError) CNIErrorCode() uint {
+	return cnitypes.ErrInvalidNetworkConfig
+}
+
+// cniErrorCoder is implemented by the errors which map to a well known CNI error code.
+type cniErrorCoder interface {
+	CNIErrorCode() uint
+}
+
+// CNIError converts err into a CNI error carrying the error code of the first error in its chain which has one.
+// Errors without a known code are reported as internal errors.
+func CNIError(err error) *cnitypes.Error {
+	var cniErr *cnitypes.Error
+	if errors.As(err, &cniErr) {
+		return cniErr
+	}
+
+	code := cnitypes.ErrInternal
+	var coder cniErrorCoder
+	if errors.As(err, &coder) {
+		code = coder.CNIErrorCode()
+	}
+	return cnitypes.NewError(code, err.Error(), "")
+}
@@ -0,0 +1,63 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	cnitypes "github.com/containernetworking/cni/pkg/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/allocate"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+var _ = Describe("CNI error codes", func() {
+	It("asks the runtime to try again later when the backend is unavailable", func() {
+		err := fmt.Errorf("error at storage engine: %w", &BackendUnavailableError{Err: errors.New("connection refused")})
+		Expect(CNIError(err).Code).To(Equal(cnitypes.ErrTryAgainLater))
+	})
+
+	It("asks the runtime to try again later when the lease could not be acquired", func() {
+		Expect(CNIError(&LeaseTimeoutError{Err: errors.New("deadline")}).Code).To(Equal(cnitypes.ErrTryAgainLater))
+	})
+
+	It("asks the runtime to try again later when the pool update kept conflicting", func() {
+		Expect(CNIError(&UpdateConflictError{Err: errors.New("conflict")}).Code).To(Equal(cnitypes.ErrTryAgainLater))
+	})
+
+	It("reports invalid configurations", func() {
+		Expect(CNIError(&InvalidConfigError{Err: errors.New("bad")}).Code).To(Equal(cnitypes.ErrInvalidNetworkConfig))
+	})
+
+	It("reports exhausted ranges", func() {
+		err := fmt.Errorf("error at storage engine: %w", allocate.AssignmentError{Total: 2, Reserved: 2})
+		cniErr := CNIError(err)
+		Expect(cniErr.Code).To(Equal(types.ErrRangeExhausted))
+		Expect(cniErr.Msg).To(ContainSubstring("total: 2 / reserved: 2 / excluded: 0"))
+	})
+
+	It("reports IP conflicts", func() {
+		Expect(CNIError(allocate.ConflictError{}).Code).To(Equal(types.ErrIPConflict))
+	})
+
+	It("reports unknown errors as internal errors", func() {
+		Expect(CNIError(errors.New("boom")).Code).To(Equal(cnitypes.ErrInternal))
+	})
+})
@@ -0,0 +1,309 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package etcd implements the etcd storage backend: every pool, and every overlapping range reservation, is a key
+// of the etcd cluster, updated with transactions comparing its revision.
+package etcd
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"go.etcd.io/etcd/client/pkg/v3/transport"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/api/whereabouts.cni.cncf.io/v1alpha1"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/logging"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+const (
+	// KeyPrefix prefixes all the keys whereabouts keeps in etcd.
+	KeyPrefix = "/whereabouts/"
+
+	poolsPrefix             = KeyPrefix + "pools/"
+	overlappingRangesPrefix = KeyPrefix + "overlappingranges/"
+	dialTimeout             = 5 * time.Second
+)
+
+var (
+	_ storage.Store                 = &Store{}
+	_ storage.OverlappingRangeStore = &Store{}
+)
+
+// Store is a storage.Store kept in an etcd cluster.
+type Store struct {
+	client *clientv3.Client
+}
+
+// New connects to the etcd cluster given in the IPAM configuration: `etcd_host` holds a comma-separated list of
+// endpoints, the TLS client certificate and CA are used when given, as are the basic auth credentials.
+func New(ipamConf types.IPAMConfig) (*Store, error) {
+	config := clientv3.Config{
+		Endpoints:   strings.Split(ipamConf.EtcdHost, ","),
+		DialTimeout: dialTimeout,
+		Username:    ipamConf.EtcdUsername,
+		Password:    ipamConf.EtcdPassword,
+		// The CNI results are printed on stdout, the etcd client must not log anything there.
+		Logger: zap.NewNop(),
+	}
+
+	tlsConfig, err := tlsClientConfig(ipamConf)
+	if err != nil {
+		return nil, err
+	}
+	config.TLS = tlsConfig
+
+	client, err := clientv3.New(config)
+	if err != nil {
+		return nil, &storage.BackendUnavailableError{Err: fmt.Errorf("failed to connect to etcd: %w", err)}
+	}
+	return NewWithClient(client), nil
+}
+
+// NewWithClient returns a store using an existing etcd client, which is closed along with the store.
+func NewWithClient(client *clientv3.Client) *Store {
+	return &Store{client: client}
+}
+
+func tlsClientConfig(ipamConf types.IPAMConfig) (*tls.Config, error) {
+	if ipamConf.EtcdCertFile == "" && ipamConf.EtcdKeyFile == "" && ipamConf.EtcdCACertFile == "" {
+		return nil, nil
+	}
+	if (ipamConf.EtcdCertFile == "") != (ipamConf.EtcdKeyFile == "") {
+		return nil, &storage.InvalidConfigError{Err: fmt.Errorf("`etcd_cert_file` and `etcd_key_file` must be given together")}
+	}
+
+	tlsInfo := transport.TLSInfo{
+		CertFile:      ipamConf.EtcdCertFile,
+		KeyFile:       ipamConf.EtcdKeyFile,
+		TrustedCAFile: ipamConf.EtcdCACertFile,
+	}
+	tlsConfig, err := tlsInfo.ClientConfig()
+	if err != nil {
+		return nil, &storage.InvalidConfigError{Err: fmt.Errorf("invalid etcd TLS configuration: %w", err)}
+	}
+	return tlsConfig, nil
+}
+
+// GetIPPool returns a snapshot of the pool; a missing pool is empty.
+func (s *Store) GetIPPool(ctx context.Context, poolIdentifier storage.PoolIdentifier) (storage.IPPool, error) {
+	key := PoolKey(poolIdentifier)
+	resp, err := s.client.Get(ctx, key)
+	if err != nil {
+		return nil, backendError(fmt.Errorf("failed to get pool %s: %w", key, err))
+	}
+
+	var revision int64
+	var allocations []types.IPReservation
+	if len(resp.Kvs) > 0 {
+		if err := json.Unmarshal(resp.Kvs[0].Value, &allocations); err != nil {
+			return nil, fmt.Errorf("corrupted pool %s: %w", key, err)
+		}
+		revision = resp.Kvs[0].ModRevision
+	}
+	return storage.NewSnapshotIPPool(revision, allocations, func(ctx context.Context, revision int64, reservations []types.IPReservation) error {
+		return s.updatePool(ctx, key, revision, reservations)
+	}), nil
+}
+
+// GetOverlappingRangeStore returns the store itself, which also holds the overlapping range reservations.
+func (s *Store) GetOverlappingRangeStore() (storage.OverlappingRangeStore, error) {
+	return s, nil
+}
+
+// Status checks the etcd cluster answers.
+func (s *Store) Status(ctx context.Context) error {
+	if _, err := s.client.Get(ctx, KeyPrefix, clientv3.WithPrefix(), clientv3.WithCountOnly()); err != nil {
+		return backendError(err)
+	}
+	return nil
+}
+
+// Close closes the etcd client.
+func (s *Store) Close() error {
+	return s.client.Close()
+}
+
+// GetOverlappingRangeIPReservation returns the reservation of ip in the given network, or nil when there is none.
+func (s *Store) GetOverlappingRangeIPReservation(ctx context.Context, ip net.IP, _, networkName string) (*v1alpha1.OverlappingRangeIPReservation, error) {
+	key := overlappingRangeKey(ip, networkName)
+	resp, err := s.client.Get(ctx, key)
+	if err != nil {
+		return nil, backendError(fmt.Errorf("failed to get overlapping range reservation %s: %w", key, err))
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, nil
+	}
+
+	reservation := &v1alpha1.OverlappingRangeIPReservation{}
+	if err := json.Unmarshal(resp.Kvs[0].Value, &reservation.Spec); err != nil {
+		return nil, fmt.Errorf("corrupted overlapping range reservation %s: %w", key, err)
+	}
+	return reservation, nil
+}
+
+// UpdateOverlappingRangeAllocation reserves, or releases, ip in the given network.
+func (s *Store) UpdateOverlappingRangeAllocation(ctx context.Context, mode int, ip net.IP, containerID, podRef, ifName, networkName string) error {
+	key := overlappingRangeKey(ip, networkName)
+	switch mode {
+	case types.Allocate:
+		value, err := json.Marshal(v1alpha1.OverlappingRangeIPReservationSpec{ContainerID: containerID, PodRef: podRef, IfName: ifName})
+		if err != nil {
+			return err
+		}
+		resp, err := s.client.Txn(ctx).
+			If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+			Then(clientv3.OpPut(key, string(value))).
+			Commit()
+		if err != nil {
+			return backendError(fmt.Errorf("failed to reserve %s: %w", key, err))
+		}
+		if !resp.Succeeded {
+			return &storage.OverlappingRangeReservedError{IP: ip, Err: fmt.Errorf("reserved in network %q", networkName)}
+		}
+	case types.Deallocate:
+		return s.releaseOverlappingRange(ctx, key, ip, containerID, podRef, ifName)
+	}
+	return nil
+}
+
+// TransferOverlappingRangeReservation hands the reservation of ip over to another container interface of its pod.
+func (s *Store) TransferOverlappingRangeReservation(ctx context.Context, reservation *v1alpha1.OverlappingRangeIPReservation, ip net.IP,
+	containerID, ifName, networkName string) error {
+	key := overlappingRangeKey(ip, networkName)
+	read, err := json.Marshal(reservation.Spec)
+	if err != nil {
+		return err
+	}
+	spec := reservation.Spec
+	spec.ContainerID, spec.IfName = containerID, ifName
+	value, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+
+	// the reservations are written whole, so one still holding the value read was not changed since
+	resp, err := s.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.Value(key), "=", string(read))).
+		Then(clientv3.OpPut(key, string(value))).
+		Commit()
+	if err != nil {
+		return backendError(fmt.Errorf("failed to transfer %s: %w", key, err))
+	}
+	if !resp.Succeeded {
+		return &storage.OverlappingRangeChangedError{IP: ip, Err: fmt.Errorf("%s changed", key)}
+	}
+	return nil
+}
+
+// releaseOverlappingRange deletes the reservation of the given key when it belongs to the container interface, as
+// long as it was not modified since it was read.
+func (s *Store) releaseOverlappingRange(ctx context.Context, key string, ip net.IP, containerID, podRef, ifName string) error {
+	for attempt := 0; attempt < storage.DatastoreRetries; attempt++ {
+		resp, err := s.client.Get(ctx, key)
+		if err != nil {
+			return backendError(fmt.Errorf("failed to get overlapping range reservation %s: %w", key, err))
+		}
+		if len(resp.Kvs) == 0 {
+			return fmt.Errorf("overlapping range reservation of IP %s: %w", ip, storage.ErrNotFound)
+		}
+		var spec v1alpha1.OverlappingRangeIPReservationSpec
+		if err := json.Unmarshal(resp.Kvs[0].Value, &spec); err != nil {
+			return fmt.Errorf("corrupted overlapping range reservation %s: %w", key, err)
+		}
+		if !storage.IsOverlappingRangeReservationOwner(spec, containerID, podRef, ifName) {
+			return nil
+		}
+
+		txnResp, err := s.client.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(key), "=", resp.Kvs[0].ModRevision)).
+			Then(clientv3.OpDelete(key)).
+			Commit()
+		if err != nil {
+			return backendError(fmt.Errorf("failed to release %s: %w", key, err))
+		}
+		if txnResp.Succeeded {
+			return nil
+		}
+	}
+	return fmt.Errorf("overlapping range reservation %s kept changing while being released", key)
+}
+
+// updatePool writes the allocations of the pool as long as its modification revision is still the given one.
+func (s *Store) updatePool(ctx context.Context, key string, revision int64, allocations []types.IPReservation) error {
+	value, err := json.Marshal(allocations)
+	if err != nil {
+		return err
+	}
+
+	// A missing key has a zero modification revision, so creating the pool is the same compare-and-swap.
+	resp, err := s.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", revision)).
+		Then(clientv3.OpPut(key, string(value))).
+		Commit()
+	if err != nil {
+		return backendError(fmt.Errorf("failed to update pool %s: %w", key, err))
+	}
+	if !resp.Succeeded {
+		return &storage.ConflictError{Pool: key, Version: revision}
+	}
+	logging.Debugf("etcd pool %s updated at revision %d", key, resp.Header.Revision)
+	return nil
+}
+
+// PoolKey returns the etcd key of a pool, e.g. "/whereabouts/pools/192.168.1.0/24" or
+// "/whereabouts/pools/mynet/192.168.1.0/24".
+func PoolKey(poolIdentifier storage.PoolIdentifier) string {
+	key := poolsPrefix
+	if poolIdentifier.NetworkName != "" {
+		key += poolIdentifier.NetworkName + "/"
+	}
+	key += poolIdentifier.IpRange
+	if poolIdentifier.NodeName != "" {
+		key += "@" + poolIdentifier.NodeName
+	}
+	return key
+}
+
+func overlappingRangeKey(ip net.IP, networkName string) string {
+	if networkName == "" {
+		return overlappingRangesPrefix + ip.String()
+	}
+	return overlappingRangesPrefix + networkName + "/" + ip.String()
+}
+
+// backendError reports the failures to reach etcd as the backend being unavailable.
+func backendError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return &storage.BackendUnavailableError{Err: err}
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Canceled:
+		return &storage.BackendUnavailableError{Err: err}
+	}
+	return err
+}
@@ -0,0 +1,259 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package etcd
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/server/v3/embed"
+
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage/storagetest"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+const (
+	ipRange              = "192.168.2.0/24"
+	whereaboutsUser      = "whereabouts"
+	whereaboutsPassword  = "whereabouts-password"
+	rootPassword         = "root-password"
+	etcdStartTimeout     = 30 * time.Second
+	etcdOperationTimeout = 10 * time.Second
+)
+
+func TestEtcd(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "etcd storage")
+}
+
+var (
+	etcdDir    string
+	etcdServer *embed.Etcd
+	// baseConfig connects to the embedded etcd server with TLS and basic auth.
+	baseConfig types.IPAMConfig
+	rootClient *clientv3.Client
+)
+
+var _ = BeforeSuite(func() {
+	var err error
+	etcdDir, err = os.MkdirTemp("", "whereabouts-etcd")
+	Expect(err).NotTo(HaveOccurred())
+
+	caFile, certFile, keyFile := generateCertificates(etcdDir)
+	clientURL := freeLocalURL("https")
+	peerURL := freeLocalURL("http")
+
+	cfg := embed.NewConfig()
+	cfg.Name = "whereabouts-test"
+	cfg.Dir = filepath.Join(etcdDir, "data")
+	cfg.LogLevel = "error"
+	cfg.ListenClientUrls = []url.URL{clientURL}
+	cfg.AdvertiseClientUrls = []url.URL{clientURL}
+	cfg.ListenPeerUrls = []url.URL{peerURL}
+	cfg.AdvertisePeerUrls = []url.URL{peerURL}
+	cfg.InitialCluster = cfg.InitialClusterFromName(cfg.Name)
+	cfg.ClientTLSInfo.CertFile = certFile
+	cfg.ClientTLSInfo.KeyFile = keyFile
+	cfg.ClientTLSInfo.TrustedCAFile = caFile
+	cfg.ClientTLSInfo.ClientCertAuth = true
+
+	etcdServer, err = embed.StartEtcd(cfg)
+	Expect(err).NotTo(HaveOccurred())
+	select {
+	case <-etcdServer.Server.ReadyNotify():
+	case <-time.After(etcdStartTimeout):
+		Fail("the embedded etcd server did not start in time")
+	}
+
+	baseConfig = types.IPAMConfig{
+		IPRanges:          []types.RangeConfiguration{{Range: ipRange}},
+		PodNamespace:      "default",
+		OverlappingRanges: true,
+		EtcdHost:          clientURL.String(),
+		EtcdCertFile:      certFile,
+		EtcdKeyFile:       keyFile,
+		EtcdCACertFile:    caFile,
+	}
+	enableAuth(baseConfig)
+
+	rootConfig := baseConfig
+	rootConfig.EtcdUsername = "root"
+	rootConfig.EtcdPassword = rootPassword
+	root, err := New(rootConfig)
+	Expect(err).NotTo(HaveOccurred())
+	rootClient = root.client
+
+	baseConfig.EtcdUsername = whereaboutsUser
+	baseConfig.EtcdPassword = whereaboutsPassword
+})
+
+var _ = AfterSuite(func() {
+	if rootClient != nil {
+		rootClient.Close()
+	}
+	if etcdServer != nil {
+		etcdServer.Close()
+	}
+	Expect(os.RemoveAll(etcdDir)).To(Succeed())
+})
+
+var _ = Describe("etcd datastore", func() {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+		store  *Store
+	)
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithTimeout(context.Background(), etcdOperationTimeout)
+		_, err := rootClient.Delete(ctx, KeyPrefix, clientv3.WithPrefix())
+		Expect(err).NotTo(HaveOccurred())
+
+		store, err = New(baseConfig)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(store.Close()).To(Succeed())
+		cancel()
+	})
+
+	storagetest.Conformance(func() storage.Store { return store })
+
+	It("connects with TLS and basic auth", func() {
+		Expect(store.Status(ctx)).To(Succeed())
+	})
+
+	It("is refused with wrong credentials", func() {
+		wrongPassword := baseConfig
+		wrongPassword.EtcdPassword = "wrong"
+		store, err := New(wrongPassword)
+		if err == nil {
+			defer store.Close()
+			err = store.Status(ctx)
+		}
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("rejects an incomplete TLS configuration", func() {
+		noKey := baseConfig
+		noKey.EtcdKeyFile = ""
+		_, err := New(noKey)
+		var invalidConfigErr *storage.InvalidConfigError
+		Expect(errors.As(err, &invalidConfigErr)).To(BeTrue())
+	})
+
+})
+
+// enableAuth creates the root user and a whereabouts user restricted to the whereabouts keys, then enables the
+// authentication.
+func enableAuth(ipamConf types.IPAMConfig) {
+	admin, err := New(ipamConf)
+	Expect(err).NotTo(HaveOccurred())
+	defer admin.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), etcdOperationTimeout)
+	defer cancel()
+	client := admin.client
+	_, err = client.UserAdd(ctx, "root", rootPassword)
+	Expect(err).NotTo(HaveOccurred())
+	_, err = client.UserGrantRole(ctx, "root", "root")
+	Expect(err).NotTo(HaveOccurred())
+	_, err = client.UserAdd(ctx, whereaboutsUser, whereaboutsPassword)
+	Expect(err).NotTo(HaveOccurred())
+	_, err = client.RoleAdd(ctx, whereaboutsUser)
+	Expect(err).NotTo(HaveOccurred())
+	_, err = client.RoleGrantPermission(ctx, whereaboutsUser, KeyPrefix, clientv3.GetPrefixRangeEnd(KeyPrefix),
+		clientv3.PermissionType(clientv3.PermReadWrite))
+	Expect(err).NotTo(HaveOccurred())
+	_, err = client.UserGrantRole(ctx, whereaboutsUser, whereaboutsUser)
+	Expect(err).NotTo(HaveOccurred())
+	_, err = client.AuthEnable(ctx)
+	Expect(err).NotTo(HaveOccurred())
+}
+
+// generateCertificates writes a CA, and a certificate it issued for both the server and the client, in dir.
+func generateCertificates(dir string) (caFile, certFile, keyFile string) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "whereabouts-test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	Expect(err).NotTo(HaveOccurred())
+	caCert, err := x509.ParseCertificate(caDER)
+	Expect(err).NotTo(HaveOccurred())
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "whereabouts-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:     []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	Expect(err).NotTo(HaveOccurred())
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	Expect(err).NotTo(HaveOccurred())
+
+	caFile = writePEM(filepath.Join(dir, "ca.crt"), "CERTIFICATE", caDER)
+	certFile = writePEM(filepath.Join(dir, "etcd.crt"), "CERTIFICATE", der)
+	keyFile = writePEM(filepath.Join(dir, "etcd.key"), "EC PRIVATE KEY", keyDER)
+	return caFile, certFile, keyFile
+}
+
+func writePEM(path, blockType string, der []byte) string {
+	Expect(os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600)).To(Succeed())
+	return path
+}
+
+// freeLocalURL returns a URL on a local port which is free at the time of the call.
+func freeLocalURL(scheme string) url.URL {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).NotTo(HaveOccurred())
+	defer listener.Close()
+	return url.URL{Scheme: scheme, Host: listener.Addr().String()}
+}
@@ -0,0 +1,341 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package ipaddress implements a storage backend on the networking.k8s.io/v1 IPAddress API: every allocated IP is an
+// IPAddress object named after the IP, whose parent is the pod it is allocated to. The API server guarantees there is
+// a single object per IP, so allocating an IP is creating its object, rather than updating a pool holding all the
+// allocations of a range; and the secondary IPs of the pods are visible to the other tools using the API.
+package ipaddress
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/api/whereabouts.cni.cncf.io/v1alpha1"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/logging"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage"
+	wbk8s "github.com/k8snetworkplumbingwg/whereabouts/pkg/storage/kubernetes"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+const (
+	// ManagedByLabel is the well-known label telling which allocator manages an IPAddress.
+	ManagedByLabel = "ipaddress.kubernetes.io/managed-by"
+	// ManagedBy is the value of ManagedByLabel on the IPAddresses allocated by whereabouts.
+	ManagedBy = "whereabouts.cni.cncf.io"
+	// RangeLabel holds the normalized range an IPAddress was allocated from, e.g. "192.168.1.0-24".
+	RangeLabel = "whereabouts.cni.cncf.io/range"
+	// NetworkLabel holds the network name an IPAddress was allocated in; it is missing for the unnamed network.
+	NetworkLabel = "whereabouts.cni.cncf.io/network"
+
+	// ContainerIDAnnotation and IfNameAnnotation identify the attachment an IPAddress is allocated to.
+	ContainerIDAnnotation = "whereabouts.cni.cncf.io/container-id"
+	IfNameAnnotation      = "whereabouts.cni.cncf.io/ifname"
+	// StaticAnnotation marks the IPAddresses of the statically requested IPs.
+	StaticAnnotation = "whereabouts.cni.cncf.io/static"
+
+	podsResource = "pods"
+)
+
+var (
+	_ storage.Store                 = &Store{}
+	_ storage.OverlappingRangeStore = &Store{}
+	_ storage.IPPool                = &IPPool{}
+)
+
+// Store is a storage.Store keeping the allocations as IPAddress objects.
+type Store struct {
+	client kubernetes.Interface
+}
+
+// New returns a store using the kubeconfig of the IPAM configuration.
+func New(ipamConf types.IPAMConfig) (*Store, error) {
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: ipamConf.Kubernetes.KubeConfigPath},
+		&clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, &storage.InvalidConfigError{Err: fmt.Errorf("failed to load the kubeconfig: %w", err)}
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return NewWithClient(client), nil
+}
+
+// NewWithClient returns a store using an existing Kubernetes client.
+func NewWithClient(client kubernetes.Interface) *Store {
+	return &Store{client: client}
+}
+
+// GetIPPool returns a snapshot of the IPAddresses allocated from the pool.
+func (s *Store) GetIPPool(ctx context.Context, poolIdentifier storage.PoolIdentifier) (storage.IPPool, error) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, storage.RequestTimeout)
+	defer cancel()
+
+	selector, err := poolSelector(poolIdentifier)
+	if err != nil {
+		return nil, &storage.InvalidConfigError{Err: err}
+	}
+	list, err := s.client.NetworkingV1().IPAddresses().List(ctxWithTimeout, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, wbk8s.BackendError(fmt.Errorf("failed to list the IPAddresses of pool %v: %w", poolIdentifier, err))
+	}
+
+	pool := &IPPool{store: s, poolIdentifier: poolIdentifier, addresses: map[string]networkingv1.IPAddress{}}
+	for _, address := range list.Items {
+		pool.addresses[address.Name] = address
+	}
+	return pool, nil
+}
+
+// GetOverlappingRangeStore returns the store itself: the IPAddress names are unique in the cluster, so they also
+// are the overlapping range reservations.
+func (s *Store) GetOverlappingRangeStore() (storage.OverlappingRangeStore, error) {
+	return s, nil
+}
+
+// Status checks the IPAddress API is served.
+func (s *Store) Status(ctx context.Context) error {
+	if _, err := s.client.NetworkingV1().IPAddresses().List(ctx, metav1.ListOptions{Limit: 1}); err != nil {
+		return wbk8s.BackendError(fmt.Errorf("the IPAddress API is not available: %w", err))
+	}
+	return nil
+}
+
+// Close is a no-op.
+func (s *Store) Close() error {
+	return nil
+}
+
+// GetOverlappingRangeIPReservation returns the reservation held by the IPAddress of ip, whatever the range and the
+// network it was allocated in, or nil when there is none.
+func (s *Store) GetOverlappingRangeIPReservation(ctx context.Context, ip net.IP, _, _ string) (*v1alpha1.OverlappingRangeIPReservation, error) {
+	name, err := Name(ip)
+	if err != nil {
+		return nil, err
+	}
+	address, err := s.client.NetworkingV1().IPAddresses().Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, wbk8s.BackendError(fmt.Errorf("failed to get IPAddress %s: %w", name, err))
+	}
+
+	reservation := toReservation(ip, *address)
+	return &v1alpha1.OverlappingRangeIPReservation{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: v1alpha1.OverlappingRangeIPReservationSpec{
+			ContainerID: reservation.ContainerID,
+			PodRef:      reservation.PodRef,
+			IfName:      reservation.IfName,
+		},
+	}, nil
+}
+
+// UpdateOverlappingRangeAllocation is a no-op: the IPAddress created, or deleted, by the pool update is the
+// reservation.
+func (s *Store) UpdateOverlappingRangeAllocation(context.Context, int, net.IP, string, string, string, string) error {
+	return nil
+}
+
+// TransferOverlappingRangeReservation is a no-op: the pool update hands the IPAddress over.
+func (s *Store) TransferOverlappingRangeReservation(context.Context, *v1alpha1.OverlappingRangeIPReservation, net.IP, string, string, string) error {
+	return nil
+}
+
+// IPPool is a snapshot of the IPAddresses allocated from a pool.
+type IPPool struct {
+	store          *Store
+	poolIdentifier storage.PoolIdentifier
+	addresses      map[string]networkingv1.IPAddress
+}
+
+// Allocations returns the reservations of the pool, as of the snapshot.
+func (p *IPPool) Allocations() []types.IPReservation {
+	var reservations []types.IPReservation
+	for name, address := range p.addresses {
+		ip := net.ParseIP(name)
+		if ip == nil {
+			logging.Errorf("skipping IPAddress with an invalid name: %s", name)
+			continue
+		}
+		reservations = append(reservations, toReservation(ip, address))
+	}
+	return reservations
+}
+
+// Update creates the IPAddresses of the new reservations, updates the changed ones, and deletes the released ones,
+// only as long as they were not changed since the snapshot was taken. An IPAddress created concurrently for the same
+// pool fails the update with a storage.ConflictError, so that it is retried; an IPAddress held by another pool, or by
+// another allocator altogether, with a storage.IPReservedElsewhereError, so that another IP is allocated. The
+// IPAddresses created by a failed update are deleted.
+func (p *IPPool) Update(ctx context.Context, reservations []types.IPReservation) error {
+	desired := map[string]networkingv1.IPAddress{}
+	for _, reservation := range reservations {
+		address, err := p.toIPAddress(reservation)
+		if err != nil {
+			return err
+		}
+		desired[address.Name] = address
+	}
+
+	return wbk8s.ObjectPoolUpdate[networkingv1.IPAddress, *networkingv1.IPAddress]{
+		Client:  p.store.client.NetworkingV1().IPAddresses(),
+		Kind:    "IPAddress",
+		Current: p.addresses,
+		Desired: desired,
+		Merge: func(current, desired networkingv1.IPAddress) (networkingv1.IPAddress, bool) {
+			changed := !sameReservation(current, desired)
+			current.Annotations = desired.Annotations
+			current.Spec = desired.Spec
+			return current, changed
+		},
+		AlreadyExists: p.alreadyExists,
+		Conflict: func(name string, _ error) error {
+			return p.conflict(name)
+		},
+	}.Apply(ctx)
+}
+
+// alreadyExists tells apart the IPAddresses concurrently allocated from the same pool from the others.
+func (p *IPPool) alreadyExists(ctx context.Context, name string) error {
+	address, err := p.store.client.NetworkingV1().IPAddresses().Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		// released since, try again
+		return p.conflict(name)
+	} else if err != nil {
+		return wbk8s.BackendError(fmt.Errorf("failed to get IPAddress %s: %w", name, err))
+	}
+
+	selector, err := poolSelector(p.poolIdentifier)
+	if err != nil {
+		return err
+	}
+	if selector.Matches(labels.Set(address.Labels)) {
+		return p.conflict(name)
+	}
+	var parent string
+	if parentRef := address.Spec.ParentRef; parentRef != nil {
+		parent = fmt.Sprintf("%s %s/%s", parentRef.Resource, parentRef.Namespace, parentRef.Name)
+	}
+	return &storage.IPReservedElsewhereError{IP: net.ParseIP(name), Err: fmt.Errorf("IPAddress %s is allocated to %s (managed by %q)",
+		name, parent, address.Labels[ManagedByLabel])}
+}
+
+// conflict returns the temporary error of an IPAddress of the pool changed since the snapshot was taken.
+func (p *IPPool) conflict(name string) error {
+	return &storage.ConflictError{Pool: fmt.Sprintf("%v", p.poolIdentifier), Object: "IPAddress " + name}
+}
+
+func (p *IPPool) toIPAddress(reservation types.IPReservation) (networkingv1.IPAddress, error) {
+	name, err := Name(reservation.IP)
+	if err != nil {
+		return networkingv1.IPAddress{}, err
+	}
+
+	podNamespace, podName, _ := strings.Cut(reservation.PodRef, "/")
+	address := networkingv1.IPAddress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: poolLabels(p.poolIdentifier),
+			Annotations: map[string]string{
+				ContainerIDAnnotation: reservation.ContainerID,
+				IfNameAnnotation:      reservation.IfName,
+			},
+		},
+		Spec: networkingv1.IPAddressSpec{
+			ParentRef: &networkingv1.ParentReference{
+				Resource:  podsResource,
+				Namespace: podNamespace,
+				Name:      podName,
+			},
+		},
+	}
+	if reservation.IsStatic {
+		address.Annotations[StaticAnnotation] = "true"
+	}
+	return address, nil
+}
+
+func toReservation(ip net.IP, address networkingv1.IPAddress) types.IPReservation {
+	reservation := types.IPReservation{
+		IP:          ip,
+		ContainerID: address.Annotations[ContainerIDAnnotation],
+		IfName:      address.Annotations[IfNameAnnotation],
+		IsStatic:    address.Annotations[StaticAnnotation] == "true",
+	}
+	if parent := address.Spec.ParentRef; parent != nil {
+		reservation.PodRef = parent.Namespace + "/" + parent.Name
+	}
+	return reservation
+}
+
+func sameReservation(current, desired networkingv1.IPAddress) bool {
+	if current.Spec.ParentRef == nil || *current.Spec.ParentRef != *desired.Spec.ParentRef {
+		return false
+	}
+	for _, annotation := range []string{ContainerIDAnnotation, IfNameAnnotation, StaticAnnotation} {
+		if current.Annotations[annotation] != desired.Annotations[annotation] {
+			return false
+		}
+	}
+	return true
+}
+
+// Name returns the name of the IPAddress of ip: its canonical representation.
+func Name(ip net.IP) (string, error) {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return "", fmt.Errorf("invalid IP %q", ip)
+	}
+	return addr.Unmap().String(), nil
+}
+
+func poolLabels(poolIdentifier storage.PoolIdentifier) map[string]string {
+	poolLabels := map[string]string{
+		ManagedByLabel: ManagedBy,
+		RangeLabel:     wbk8s.LabelValue(wbk8s.IPPoolName(storage.PoolIdentifier{IpRange: poolIdentifier.IpRange})),
+	}
+	if poolIdentifier.NetworkName != "" {
+		poolLabels[NetworkLabel] = wbk8s.LabelValue(poolIdentifier.NetworkName)
+	}
+	return poolLabels
+}
+
+// poolSelector selects the IPAddresses of a pool; those of the unnamed network have no network label.
+func poolSelector(poolIdentifier storage.PoolIdentifier) (labels.Selector, error) {
+	selector := labels.SelectorFromSet(poolLabels(poolIdentifier))
+	if poolIdentifier.NetworkName == "" {
+		withoutNetwork, err := labels.NewRequirement(NetworkLabel, selection.DoesNotExist, nil)
+		if err != nil {
+			return nil, err
+		}
+		selector = selector.Add(*withoutNetwork)
+	}
+	return selector, nil
+}
@@ -0,0 +1,196 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ipaddress
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakek8sclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/ipmanagement"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+func TestIPAddress(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "IPAddress storage")
+}
+
+var _ = Describe("IPAddress datastore", func() {
+	const ipRange = "192.168.2.0/24"
+
+	var (
+		ctx    context.Context
+		client *fakek8sclient.Clientset
+		store  *Store
+	)
+
+	ipamConfig := func(podName, networkName string, ranges ...string) types.IPAMConfig {
+		ipamConf := types.IPAMConfig{
+			PodNamespace:      "default",
+			PodName:           podName,
+			NetworkName:       networkName,
+			OverlappingRanges: true,
+		}
+		for _, r := range ranges {
+			ipamConf.IPRanges = append(ipamConf.IPRanges, types.RangeConfiguration{Range: r})
+		}
+		return ipamConf
+	}
+
+	getIPAddress := func(name string) *networkingv1.IPAddress {
+		address, err := client.NetworkingV1().IPAddresses().Get(ctx, name, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		return address
+	}
+
+	listIPAddresses := func() []networkingv1.IPAddress {
+		list, err := client.NetworkingV1().IPAddresses().List(ctx, metav1.ListOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		return list.Items
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		client = fakek8sclient.NewSimpleClientset()
+		store = NewWithClient(client)
+	})
+
+	It("records an allocation as an IPAddress whose parent is the pod", func() {
+		ips, err := ipmanagement.Manage(ctx, types.Allocate, store, ipamConfig("pod1", "net1", ipRange, "fd00::/64"), "container1", "eth0")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ips).To(HaveLen(2))
+
+		address := getIPAddress("192.168.2.1")
+		Expect(address.Labels).To(Equal(map[string]string{
+			ManagedByLabel: ManagedBy,
+			RangeLabel:     "192.168.2.0-24",
+			NetworkLabel:   "net1",
+		}))
+		Expect(address.Annotations).To(HaveKeyWithValue(ContainerIDAnnotation, "container1"))
+		Expect(address.Annotations).To(HaveKeyWithValue(IfNameAnnotation, "eth0"))
+		Expect(*address.Spec.ParentRef).To(Equal(networkingv1.ParentReference{Resource: "pods", Namespace: "default", Name: "pod1"}))
+
+		address = getIPAddress("fd00::1")
+		Expect(address.Labels).To(HaveKeyWithValue(RangeLabel, "fd00---64"))
+	})
+
+	It("deletes the IPAddress on release", func() {
+		ipamConf := ipamConfig("pod1", "", ipRange)
+		_, err := ipmanagement.Manage(ctx, types.Allocate, store, ipamConf, "container1", "eth0")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(listIPAddresses()).To(HaveLen(1))
+
+		_, err = ipmanagement.Manage(ctx, types.Deallocate, store, ipamConf, "container1", "eth0")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(listIPAddresses()).To(BeEmpty())
+	})
+
+	It("keeps the pools of the named and unnamed networks apart", func() {
+		_, err := ipmanagement.Manage(ctx, types.Allocate, store, ipamConfig("pod1", "net1", ipRange), "container1", "eth0")
+		Expect(err).NotTo(HaveOccurred())
+
+		unnamed, err := store.GetIPPool(ctx, storage.PoolIdentifier{IpRange: ipRange})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(unnamed.Allocations()).To(BeEmpty())
+
+		named, err := store.GetIPPool(ctx, storage.PoolIdentifier{IpRange: ipRange, NetworkName: "net1"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(named.Allocations()).To(HaveLen(1))
+		Expect(named.Allocations()[0].PodRef).To(Equal("default/pod1"))
+	})
+
+	Context("when an IP is held by another allocator", func() {
+		BeforeEach(func() {
+			_, err := client.NetworkingV1().IPAddresses().Create(ctx, &networkingv1.IPAddress{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "192.168.2.1",
+					Labels: map[string]string{ManagedByLabel: "ipallocator.k8s.io"},
+				},
+				Spec: networkingv1.IPAddressSpec{
+					ParentRef: &networkingv1.ParentReference{Resource: "services", Namespace: "default", Name: "kubernetes"},
+				},
+			}, metav1.CreateOptions{})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("skips it through the overlapping range check", func() {
+			ips, err := ipmanagement.Manage(ctx, types.Allocate, store, ipamConfig("pod1", "", ipRange), "container1", "eth0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ips[0].IP.String()).To(Equal("192.168.2.2"))
+		})
+
+		It("skips it on creating its IPAddress when the overlapping range check is disabled", func() {
+			ipamConf := ipamConfig("pod1", "", ipRange)
+			ipamConf.OverlappingRanges = false
+			ips, err := ipmanagement.Manage(ctx, types.Allocate, store, ipamConf, "container1", "eth0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ips[0].IP.String()).To(Equal("192.168.2.2"))
+
+			address, err := client.NetworkingV1().IPAddresses().Get(ctx, "192.168.2.1", metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(address.Labels[ManagedByLabel]).To(Equal("ipallocator.k8s.io"))
+		})
+	})
+
+	It("gives distinct IPs to concurrent allocations", func() {
+		const allocations = 20
+
+		var wg sync.WaitGroup
+		ips := make(chan string, allocations)
+		errs := make(chan error, allocations)
+		for i := 0; i < allocations; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				newIPs, err := ipmanagement.Manage(ctx, types.Allocate, store,
+					ipamConfig(fmt.Sprintf("pod%d", i), "", ipRange), fmt.Sprintf("container%d", i), "eth0")
+				if err != nil {
+					errs <- err
+					return
+				}
+				ips <- newIPs[0].IP.String()
+			}(i)
+		}
+		wg.Wait()
+		close(ips)
+		close(errs)
+
+		Expect(errs).To(BeEmpty())
+		seen := map[string]bool{}
+		for ip := range ips {
+			Expect(seen).NotTo(HaveKey(ip))
+			seen[ip] = true
+		}
+		Expect(listIPAddresses()).To(HaveLen(allocations))
+	})
+
+	It("names the IPAddresses after the canonical IPs", func() {
+		Expect(Name(net.ParseIP("192.168.2.1"))).To(Equal("192.168.2.1"))
+		Expect(Name(net.ParseIP("FD00:0:0:0:0:0:0:1"))).To(Equal("fd00::1"))
+	})
+})
@@ -0,0 +1,270 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+
+	whereaboutsv1alpha1 "github.com/k8snetworkplumbingwg/whereabouts/pkg/api/whereabouts.cni.cncf.io/v1alpha1"
+	wbclient "github.com/k8snetworkplumbingwg/whereabouts/pkg/generated/clientset/versioned"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/logging"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage"
+	whereaboutstypes "github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+// The labels identifying the pool an IPAddressAllocation belongs to; the network and node labels are missing for
+// the unnamed network, and for the pools which are not node slices.
+const (
+	PoolRangeLabel   = "whereabouts.cni.cncf.io/range"
+	PoolNetworkLabel = "whereabouts.cni.cncf.io/network"
+	PoolNodeLabel    = "whereabouts.cni.cncf.io/node"
+)
+
+var (
+	_ storage.IPPool                = &KubernetesAllocationPool{}
+	_ storage.OverlappingRangeStore = &KubernetesAllocationStore{}
+)
+
+// KubernetesAllocationPool is a pool kept as one IPAddressAllocation per allocated IP, named after the IP and the
+// network like the OverlappingRangeIPReservations: allocating an IP creates its object, which the API server only
+// lets one allocator do, so concurrent allocations from the same range do not conflict unless they pick the same IP.
+type KubernetesAllocationPool struct {
+	client         wbclient.Interface
+	namespace      string
+	poolIdentifier PoolIdentifier
+	allocations    map[string]whereaboutsv1alpha1.IPAddressAllocation
+}
+
+func (i *KubernetesIPAM) getAllocationPool(ctx context.Context, poolIdentifier PoolIdentifier) (storage.IPPool, error) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, storage.RequestTimeout)
+	defer cancel()
+
+	selector, err := allocationPoolSelector(poolIdentifier)
+	if err != nil {
+		return nil, &storage.InvalidConfigError{Err: err}
+	}
+	list, err := i.client.WhereaboutsV1alpha1().IPAddressAllocations(i.Namespace).List(ctxWithTimeout, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, BackendError(fmt.Errorf("k8s list IPAddressAllocations error: %w", err))
+	}
+
+	pool := &KubernetesAllocationPool{
+		client:         i.client,
+		namespace:      i.Namespace,
+		poolIdentifier: poolIdentifier,
+		allocations:    map[string]whereaboutsv1alpha1.IPAddressAllocation{},
+	}
+	for _, allocation := range list.Items {
+		pool.allocations[allocation.Name] = allocation
+	}
+	return pool, nil
+}
+
+// Allocations returns the reservations of the pool, as of the listing of its IPAddressAllocations.
+func (p *KubernetesAllocationPool) Allocations() []whereaboutstypes.IPReservation {
+	reservations := []whereaboutstypes.IPReservation{}
+	for name, allocation := range p.allocations {
+		ip := net.ParseIP(allocation.Spec.IP)
+		if ip == nil {
+			logging.Errorf("Error decoding the IP of IPAddressAllocation %s: %q", name, allocation.Spec.IP)
+			continue
+		}
+		reservations = append(reservations, whereaboutstypes.IPReservation{
+			IP:          ip,
+			ContainerID: allocation.Spec.ContainerID,
+			PodRef:      allocation.Spec.PodRef,
+			IfName:      allocation.Spec.IfName,
+			IsStatic:    allocation.Spec.IsStatic,
+		})
+	}
+	return reservations
+}
+
+// Name returns the name of the pool, as if it were an IPPool
+func (p *KubernetesAllocationPool) Name() string {
+	return IPPoolName(p.poolIdentifier)
+}
+
+// Namespace returns the namespace of the IPAddressAllocations of the pool
+func (p *KubernetesAllocationPool) Namespace() string {
+	return p.namespace
+}
+
+// Update creates the IPAddressAllocations of the new reservations, updates the changed ones and deletes the
+// released ones, provided they were not changed since they were listed. An IP allocated concurrently from the same
+// pool fails the update with a temporary error, so that another IP is picked; an IP allocated from another pool of
+// the same network fails it for good. The IPAddressAllocations created by a failed update are deleted.
+func (p *KubernetesAllocationPool) Update(ctx context.Context, reservations []whereaboutstypes.IPReservation) error {
+	desired := map[string]whereaboutsv1alpha1.IPAddressAllocation{}
+	for _, reservation := range reservations {
+		allocation := p.toAllocation(reservation)
+		desired[allocation.Name] = allocation
+	}
+
+	return ObjectPoolUpdate[whereaboutsv1alpha1.IPAddressAllocation, *whereaboutsv1alpha1.IPAddressAllocation]{
+		Client:  p.client.WhereaboutsV1alpha1().IPAddressAllocations(p.namespace),
+		Kind:    "IPAddressAllocation",
+		Current: p.allocations,
+		Desired: desired,
+		Merge: func(current, desired whereaboutsv1alpha1.IPAddressAllocation) (whereaboutsv1alpha1.IPAddressAllocation, bool) {
+			changed := current.Spec != desired.Spec
+			current.Spec = desired.Spec
+			return current, changed
+		},
+		AlreadyExists: p.alreadyAllocated,
+		Conflict: func(_ string, err error) error {
+			return &temporaryError{err}
+		},
+	}.Apply(ctx)
+}
+
+// alreadyAllocated tells apart the IPs concurrently allocated from the same pool from those allocated from another
+// pool of the network.
+func (p *KubernetesAllocationPool) alreadyAllocated(ctx context.Context, name string) error {
+	allocation, err := p.client.WhereaboutsV1alpha1().IPAddressAllocations(p.namespace).Get(ctx, name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		// released since, try again
+		return &temporaryError{fmt.Errorf("IPAddressAllocation %s was released concurrently", name)}
+	} else if err != nil {
+		return BackendError(fmt.Errorf("k8s get IPAddressAllocation error: %w", err))
+	}
+
+	selector, err := allocationPoolSelector(p.poolIdentifier)
+	if err != nil {
+		return err
+	}
+	if selector.Matches(labels.Set(allocation.Labels)) {
+		return &temporaryError{fmt.Errorf("IP %s was allocated concurrently", allocation.Spec.IP)}
+	}
+	return &storage.IPReservedElsewhereError{IP: net.ParseIP(allocation.Spec.IP), Err: fmt.Errorf("allocated from range %s to %s",
+		allocation.Spec.Range, allocation.Spec.PodRef)}
+}
+
+func (p *KubernetesAllocationPool) toAllocation(reservation whereaboutstypes.IPReservation) whereaboutsv1alpha1.IPAddressAllocation {
+	objectMeta := hashedObjectMeta(normalizeIP(reservation.IP, p.poolIdentifier.NetworkName), allocationPoolLabelValues(p.poolIdentifier))
+	objectMeta.Namespace = p.namespace
+	return whereaboutsv1alpha1.IPAddressAllocation{
+		ObjectMeta: objectMeta,
+		Spec: whereaboutsv1alpha1.IPAddressAllocationSpec{
+			IP:          reservation.IP.String(),
+			Range:       p.poolIdentifier.IpRange,
+			ContainerID: reservation.ContainerID,
+			PodRef:      reservation.PodRef,
+			IfName:      reservation.IfName,
+			IsStatic:    reservation.IsStatic,
+		},
+	}
+}
+
+// allocationPoolLabels returns the labels of the IPAddressAllocations of a pool.
+func allocationPoolLabels(poolIdentifier PoolIdentifier) map[string]string {
+	poolLabels := map[string]string{}
+	for key, value := range allocationPoolLabelValues(poolIdentifier) {
+		poolLabels[key] = LabelValue(value)
+	}
+	return poolLabels
+}
+
+// allocationPoolLabelValues returns the values of the labels of the IPAddressAllocations of a pool, before they
+// are hashed.
+func allocationPoolLabelValues(poolIdentifier PoolIdentifier) map[string]string {
+	poolLabels := map[string]string{PoolRangeLabel: normalizeRange(poolIdentifier.IpRange)}
+	if poolIdentifier.NetworkName != UnnamedNetwork {
+		poolLabels[PoolNetworkLabel] = poolIdentifier.NetworkName
+	}
+	if poolIdentifier.NodeName != "" {
+		poolLabels[PoolNodeLabel] = poolIdentifier.NodeName
+	}
+	return poolLabels
+}
+
+// allocationPoolSelector selects the IPAddressAllocations of a pool, and only those: the pool of a range in the
+// unnamed network must not select the allocations of the same range in a named network.
+func allocationPoolSelector(poolIdentifier PoolIdentifier) (labels.Selector, error) {
+	selector := labels.SelectorFromSet(allocationPoolLabels(poolIdentifier))
+	for label, missing := range map[string]bool{
+		PoolNetworkLabel: poolIdentifier.NetworkName == UnnamedNetwork,
+		PoolNodeLabel:    poolIdentifier.NodeName == "",
+	} {
+		if !missing {
+			continue
+		}
+		requirement, err := labels.NewRequirement(label, selection.DoesNotExist, nil)
+		if err != nil {
+			return nil, err
+		}
+		selector = selector.Add(*requirement)
+	}
+	return selector, nil
+}
+
+// allocationPoolIdentifier returns the identifier of the pool an IPAddressAllocation belongs to.
+func allocationPoolIdentifier(allocation whereaboutsv1alpha1.IPAddressAllocation) PoolIdentifier {
+	return PoolIdentifier{
+		IpRange:     allocation.Spec.Range,
+		NetworkName: getLabel(allocation.Labels, allocation.Annotations, PoolNetworkLabel),
+		NodeName:    getLabel(allocation.Labels, allocation.Annotations, PoolNodeLabel),
+	}
+}
+
+// KubernetesAllocationStore is the OverlappingRangeStore of the pools kept as IPAddressAllocations: those are named
+// after the IP and the network, so they also are the network-wide reservations of their IPs.
+type KubernetesAllocationStore struct {
+	client    wbclient.Interface
+	namespace string
+}
+
+// GetOverlappingRangeIPReservation returns the reservation held by the IPAddressAllocation of ip in the network,
+// whatever the range it was allocated from, or nil when there is none.
+func (c *KubernetesAllocationStore) GetOverlappingRangeIPReservation(ctx context.Context, ip net.IP,
+	_, networkName string) (*whereaboutsv1alpha1.OverlappingRangeIPReservation, error) {
+	name := NormalizeIP(ip, networkName)
+	allocation, err := c.client.WhereaboutsV1alpha1().IPAddressAllocations(c.namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil && errors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, BackendError(fmt.Errorf("k8s get IPAddressAllocation error: %w", err))
+	}
+
+	return &whereaboutsv1alpha1.OverlappingRangeIPReservation{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: c.namespace},
+		Spec: whereaboutsv1alpha1.OverlappingRangeIPReservationSpec{
+			ContainerID: allocation.Spec.ContainerID,
+			PodRef:      allocation.Spec.PodRef,
+			IfName:      allocation.Spec.IfName,
+		},
+	}, nil
+}
+
+// UpdateOverlappingRangeAllocation is a no-op: the IPAddressAllocation created, or deleted, by the pool update is
+// the reservation.
+func (c *KubernetesAllocationStore) UpdateOverlappingRangeAllocation(context.Context, int, net.IP, string, string, string, string) error {
+	return nil
+}
+
+// TransferOverlappingRangeReservation is a no-op: the pool update hands the IPAddressAllocation over.
+func (c *KubernetesAllocationStore) TransferOverlappingRangeReservation(context.Context, *whereaboutsv1alpha1.OverlappingRangeIPReservation,
+	net.IP, string, string, string) error {
+	return nil
+}
@@ -0,0 +1,297 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	whereaboutsv1alpha1 "github.com/k8snetworkplumbingwg/whereabouts/pkg/api/whereabouts.cni.cncf.io/v1alpha1"
+	wbfake "github.com/k8snetworkplumbingwg/whereabouts/pkg/generated/clientset/versioned/fake"
+	whereaboutstypes "github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+const allocationsNamespace = "kube-system"
+
+func perAddressIPAMConfig(podName, networkName string, ranges ...string) whereaboutstypes.IPAMConfig {
+	ipamConf := whereaboutstypes.IPAMConfig{
+		NetworkName:           networkName,
+		PodName:               podName,
+		PodNamespace:          "default",
+		OverlappingRanges:     true,
+		PerAddressAllocations: true,
+	}
+	for _, ipRange := range ranges {
+		ipamConf.IPRanges = append(ipamConf.IPRanges, whereaboutstypes.RangeConfiguration{Range: ipRange})
+	}
+	return ipamConf
+}
+
+func perAddressIPManagement(t *testing.T, wbClientSet *wbfake.Clientset, mode int, ipamConf whereaboutstypes.IPAMConfig, containerID string) ([]string, error) {
+	t.Helper()
+	ipam := newKubernetesIPAM(containerID, "eth0", ipamConf, allocationsNamespace,
+		*NewKubernetesClient(wbClientSet, k8sfake.NewSimpleClientset()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ips, err := IPManagement(ctx, mode, ipamConf, ipam)
+	var addresses []string
+	for _, ip := range ips {
+		addresses = append(addresses, ip.IP.String())
+	}
+	return addresses, err
+}
+
+func listAllocations(t *testing.T, wbClientSet *wbfake.Clientset) []whereaboutsv1alpha1.IPAddressAllocation {
+	t.Helper()
+	list, err := wbClientSet.WhereaboutsV1alpha1().IPAddressAllocations(allocationsNamespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list the IPAddressAllocations: %v", err)
+	}
+	return list.Items
+}
+
+func TestPerAddressAllocations(t *testing.T) {
+	wbClientSet := wbfake.NewSimpleClientset()
+	ipamConf := perAddressIPAMConfig("pod1", "net1", "192.168.2.0/24", "fd00::/64")
+
+	ips, err := perAddressIPManagement(t, wbClientSet, whereaboutstypes.Allocate, ipamConf, "container1")
+	if err != nil {
+		t.Fatalf("failed to allocate: %v", err)
+	}
+	if !reflect.DeepEqual(ips, []string{"192.168.2.1", "fd00::1"}) {
+		t.Fatalf("unexpected IPs: %v", ips)
+	}
+
+	allocation, err := wbClientSet.WhereaboutsV1alpha1().IPAddressAllocations(allocationsNamespace).Get(context.Background(), "net1-192.168.2.1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get the IPAddressAllocation: %v", err)
+	}
+	expectedLabels := map[string]string{PoolRangeLabel: "192.168.2.0-24", PoolNetworkLabel: "net1"}
+	if !reflect.DeepEqual(allocation.Labels, expectedLabels) {
+		t.Errorf("expected labels %v, got %v", expectedLabels, allocation.Labels)
+	}
+	expectedSpec := whereaboutsv1alpha1.IPAddressAllocationSpec{
+		IP: "192.168.2.1", Range: "192.168.2.0/24", ContainerID: "container1", PodRef: "default/pod1", IfName: "eth0",
+	}
+	if allocation.Spec != expectedSpec {
+		t.Errorf("expected spec %+v, got %+v", expectedSpec, allocation.Spec)
+	}
+
+	for _, action := range wbClientSet.Actions() {
+		if resource := action.GetResource().Resource; resource == "ippools" && action.GetVerb() != "list" ||
+			resource == "overlappingrangeipreservations" {
+			t.Errorf("unexpected %s of %s", action.GetVerb(), resource)
+		}
+	}
+
+	if _, err := perAddressIPManagement(t, wbClientSet, whereaboutstypes.Deallocate, ipamConf, "container1"); err != nil {
+		t.Fatalf("failed to release: %v", err)
+	}
+	if allocations := listAllocations(t, wbClientSet); len(allocations) != 0 {
+		t.Errorf("expected the IPAddressAllocations to be deleted, got %v", allocations)
+	}
+}
+
+func TestPerAddressAllocationsKeepPoolsApart(t *testing.T) {
+	wbClientSet := wbfake.NewSimpleClientset()
+	if _, err := perAddressIPManagement(t, wbClientSet, whereaboutstypes.Allocate, perAddressIPAMConfig("pod1", "net1", "192.168.2.0/24"), "container1"); err != nil {
+		t.Fatalf("failed to allocate: %v", err)
+	}
+
+	ipam := newKubernetesIPAM("container2", "eth0", perAddressIPAMConfig("pod2", "", "192.168.2.0/24"), allocationsNamespace,
+		*NewKubernetesClient(wbClientSet, k8sfake.NewSimpleClientset()))
+	for _, tc := range []struct {
+		poolIdentifier PoolIdentifier
+		allocations    int
+	}{
+		{PoolIdentifier{IpRange: "192.168.2.0/24"}, 0},
+		{PoolIdentifier{IpRange: "192.168.2.0/24", NetworkName: "net1"}, 1},
+		{PoolIdentifier{IpRange: "192.168.2.0/24", NetworkName: "net1", NodeName: "node1"}, 0},
+	} {
+		pool, err := ipam.GetIPPool(context.Background(), tc.poolIdentifier)
+		if err != nil {
+			t.Fatalf("failed to get pool %v: %v", tc.poolIdentifier, err)
+		}
+		if len(pool.Allocations()) != tc.allocations {
+			t.Errorf("expected %d allocations in pool %v, got %v", tc.allocations, tc.poolIdentifier, pool.Allocations())
+		}
+	}
+}
+
+func TestPerAddressAllocationsOfOverlappingRanges(t *testing.T) {
+	wbClientSet := wbfake.NewSimpleClientset()
+	if _, err := perAddressIPManagement(t, wbClientSet, whereaboutstypes.Allocate, perAddressIPAMConfig("pod1", "net1", "192.168.2.0/24"), "container1"); err != nil {
+		t.Fatalf("failed to allocate: %v", err)
+	}
+
+	ipamConf := perAddressIPAMConfig("pod2", "net1", "192.168.2.0/25")
+	ips, err := perAddressIPManagement(t, wbClientSet, whereaboutstypes.Allocate, ipamConf, "container2")
+	if err != nil {
+		t.Fatalf("failed to allocate from the overlapping range: %v", err)
+	}
+	if !reflect.DeepEqual(ips, []string{"192.168.2.2"}) {
+		t.Errorf("expected the IP allocated from the other range to be skipped, got %v", ips)
+	}
+
+	ipamConf = perAddressIPAMConfig("pod3", "net1", "192.168.2.0/26")
+	ipamConf.OverlappingRanges = false
+	ips, err = perAddressIPManagement(t, wbClientSet, whereaboutstypes.Allocate, ipamConf, "container3")
+	if err != nil {
+		t.Fatalf("failed to allocate without the overlapping range check: %v", err)
+	}
+	if !reflect.DeepEqual(ips, []string{"192.168.2.3"}) {
+		t.Errorf("expected the IPs allocated from the other ranges to be skipped on creating their objects, got %v", ips)
+	}
+}
+
+func TestPerAddressAllocationsConcurrently(t *testing.T) {
+	const allocations = 20
+
+	wbClientSet := wbfake.NewSimpleClientset()
+	var wg sync.WaitGroup
+	results := make(chan string, allocations)
+	errs := make(chan error, allocations)
+	for i := 0; i < allocations; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ips, err := perAddressIPManagement(t, wbClientSet, whereaboutstypes.Allocate,
+				perAddressIPAMConfig(fmt.Sprintf("pod%d", i), "", "192.168.2.0/24"), fmt.Sprintf("container%d", i))
+			if err != nil {
+				errs <- err
+				return
+			}
+			results <- ips[0]
+		}(i)
+	}
+	wg.Wait()
+	close(results)
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("failed to allocate: %v", err)
+	}
+	seen := map[string]bool{}
+	for ip := range results {
+		if seen[ip] {
+			t.Errorf("IP %s allocated twice", ip)
+		}
+		seen[ip] = true
+	}
+	if got := len(listAllocations(t, wbClientSet)); got != allocations {
+		t.Errorf("expected %d IPAddressAllocations, got %d", allocations, got)
+	}
+}
+
+func TestListIPPoolsGroupsAllocations(t *testing.T) {
+	wbClientSet := wbfake.NewSimpleClientset()
+	for i, ipamConf := range []whereaboutstypes.IPAMConfig{
+		perAddressIPAMConfig("pod1", "net1", "192.168.2.0/24"),
+		perAddressIPAMConfig("pod2", "net1", "192.168.2.0/24"),
+		perAddressIPAMConfig("pod3", "", "192.168.2.0/24"),
+	} {
+		if _, err := perAddressIPManagement(t, wbClientSet, whereaboutstypes.Allocate, ipamConf, fmt.Sprintf("container%d", i)); err != nil {
+			t.Fatalf("failed to allocate: %v", err)
+		}
+	}
+
+	pools, err := NewKubernetesClient(wbClientSet, k8sfake.NewSimpleClientset()).ListIPPools()
+	if err != nil {
+		t.Fatalf("failed to list the pools: %v", err)
+	}
+	allocationsPerPool := map[string]int{}
+	for _, pool := range pools {
+		allocationsPerPool[pool.(*KubernetesAllocationPool).Name()] = len(pool.Allocations())
+	}
+	expected := map[string]int{"net1-192.168.2.0-24": 2, "192.168.2.0-24": 1}
+	if !reflect.DeepEqual(allocationsPerPool, expected) {
+		t.Fatalf("expected pools %v, got %v", expected, allocationsPerPool)
+	}
+
+	// releasing an orphaned allocation, as the reconciler does, only deletes its own object
+	for _, pool := range pools {
+		if pool.(*KubernetesAllocationPool).Name() != "net1-192.168.2.0-24" {
+			continue
+		}
+		var remaining []whereaboutstypes.IPReservation
+		for _, reservation := range pool.Allocations() {
+			if reservation.PodRef != "default/pod1" {
+				remaining = append(remaining, reservation)
+			}
+		}
+		if err := pool.Update(context.Background(), remaining); err != nil {
+			t.Fatalf("failed to update the pool: %v", err)
+		}
+	}
+	var podRefs []string
+	for _, allocation := range listAllocations(t, wbClientSet) {
+		podRefs = append(podRefs, allocation.Spec.PodRef)
+	}
+	if len(podRefs) != 2 || strings.Contains(strings.Join(podRefs, ","), "default/pod1") {
+		t.Errorf("expected the allocation of pod1 alone to be released, got %v", podRefs)
+	}
+}
+
+func TestListIPPoolsWithoutAllocations(t *testing.T) {
+	allocationsResource := schema.GroupResource{Group: whereaboutsv1alpha1.SchemeGroupVersion.Group, Resource: "ipaddressallocations"}
+	for description, listErr := range map[string]error{
+		"missing":   apierrors.NewNotFound(allocationsResource, ""),
+		"forbidden": apierrors.NewForbidden(allocationsResource, "", errors.New("not allowed")),
+		"unmapped":  &meta.NoResourceMatchError{PartialResource: allocationsResource.WithVersion("")},
+	} {
+		pool := &whereaboutsv1alpha1.IPPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "192.168.2.0-24", Namespace: allocationsNamespace},
+			Spec:       whereaboutsv1alpha1.IPPoolSpec{Range: "192.168.2.0/24", Allocations: map[string]whereaboutsv1alpha1.IPAllocation{}},
+		}
+		wbClientSet := wbfake.NewSimpleClientset(pool)
+		wbClientSet.PrependReactor("list", "ipaddressallocations", func(k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, listErr
+		})
+
+		pools, err := NewKubernetesClient(wbClientSet, k8sfake.NewSimpleClientset()).ListIPPools()
+		if err != nil {
+			t.Errorf("expected the IPPools to be listed when the IPAddressAllocations are %s, got %v", description, err)
+			continue
+		}
+		if len(pools) != 1 {
+			t.Errorf("expected the IPPool alone when the IPAddressAllocations are %s, got %v", description, pools)
+		}
+	}
+
+	wbClientSet := wbfake.NewSimpleClientset()
+	wbClientSet.PrependReactor("list", "ipaddressallocations", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewServiceUnavailable("unavailable")
+	})
+	if _, err := NewKubernetesClient(wbClientSet, k8sfake.NewSimpleClientset()).ListIPPools(); err == nil {
+		t.Error("expected the other errors listing the IPAddressAllocations to be reported")
+	}
+}
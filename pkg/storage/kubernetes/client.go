@@ -20,7 +20,10 @@ import (
 	"context"
 	"time"
 
+	coordinationv1 "k8s.io/api/coordination/v1"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -95,17 +98,52 @@ func (i *Client) ListIPPools() ([]storage.IPPool, error) {
 		return nil, err
 	}
 
-	var whereaboutsApiIPPoolList []storage.IPPool
-	for idx, pool := range ipPoolList.Items {
-		firstIP, _, err := pool.ParseCIDR()
-		if err != nil {
-			return nil, err
+	// the shards of a pool are listed as a single pool
+	whereaboutsApiIPPoolList, err := groupShards(i.client, ipPoolList.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	allocationPools, err := i.listAllocationPools(ctxWithTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return append(whereaboutsApiIPPoolList, allocationPools...), nil
+}
+
+// listAllocationPools lists the pools kept as IPAddressAllocations, in all namespaces. A cluster where the
+// IPAddressAllocation CRD is not installed, or where the reconciler is not allowed to list them, has none.
+func (i *Client) listAllocationPools(ctx context.Context) ([]storage.IPPool, error) {
+	allocationList, err := i.client.WhereaboutsV1alpha1().IPAddressAllocations(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if errors.IsNotFound(err) || errors.IsForbidden(err) || meta.IsNoMatchError(err) {
+		logging.Debugf("no per-address pools to list: %v", err)
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	type poolKey struct {
+		namespace      string
+		poolIdentifier PoolIdentifier
+	}
+	var pools []storage.IPPool
+	poolsByKey := map[poolKey]*KubernetesAllocationPool{}
+	for _, allocation := range allocationList.Items {
+		key := poolKey{namespace: allocation.GetNamespace(), poolIdentifier: allocationPoolIdentifier(allocation)}
+		pool, ok := poolsByKey[key]
+		if !ok {
+			pool = &KubernetesAllocationPool{
+				client:         i.client,
+				namespace:      key.namespace,
+				poolIdentifier: key.poolIdentifier,
+				allocations:    map[string]whereaboutsv1alpha1.IPAddressAllocation{},
+			}
+			poolsByKey[key] = pool
+			pools = append(pools, pool)
 		}
-		whereaboutsApiIPPoolList = append(
-			whereaboutsApiIPPoolList,
-			&KubernetesIPPool{client: i.client, firstIP: firstIP, pool: &ipPoolList.Items[idx]})
+		pool.allocations[allocation.GetName()] = allocation
 	}
-	return whereaboutsApiIPPoolList, nil
+	return pools, nil
 }
 
 func (i *Client) ListPods() ([]v1.Pod, error) {
@@ -153,3 +191,26 @@ func (i *Client) DeleteOverlappingIP(clusterWideIP *whereaboutsv1alpha1.Overlapp
 	return i.client.WhereaboutsV1alpha1().OverlappingRangeIPReservations(clusterWideIP.GetNamespace()).Delete(
 		ctxWithTimeout, clusterWideIP.GetName(), metav1.DeleteOptions{})
 }
+
+// ListPoolLeases lists the leases protecting the IP pools, in all namespaces.
+func (i *Client) ListPoolLeases() ([]coordinationv1.Lease, error) {
+	ctxWithTimeout, cancel := context.WithTimeout(context.Background(), listRequestTimeout)
+	defer cancel()
+
+	leaseList, err := i.clientSet.CoordinationV1().Leases(metav1.NamespaceAll).List(ctxWithTimeout, metav1.ListOptions{LabelSelector: PoolLeaseLabel})
+	if err != nil {
+		return nil, err
+	}
+
+	return leaseList.Items, nil
+}
+
+// DeleteLease deletes the given lease, provided it was not updated since it was read.
+func (i *Client) DeleteLease(lease *coordinationv1.Lease) error {
+	ctxWithTimeout, cancel := context.WithTimeout(context.Background(), storage.RequestTimeout)
+	defer cancel()
+
+	resourceVersion := lease.GetResourceVersion()
+	return i.clientSet.CoordinationV1().Leases(lease.GetNamespace()).Delete(
+		ctxWithTimeout, lease.GetName(), metav1.DeleteOptions{Preconditions: &metav1.Preconditions{ResourceVersion: &resourceVersion}})
+}
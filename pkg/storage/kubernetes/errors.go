@@ -16,6 +16,16 @@
 
 package kubernetes
 
+import (
+	stderrors "errors"
+	"net"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage"
+)
+
 type temporaryError struct {
 	error
 }
@@ -23,3 +33,16 @@ type temporaryError struct {
 func (t *temporaryError) Temporary() bool {
 	return true
 }
+
+// BackendError wraps err into a storage.BackendUnavailableError when it indicates that the API server could not be
+// reached or could not serve the request; other errors are returned untouched.
+func BackendError(err error) error {
+	var netErr net.Error
+	if errors.IsServerTimeout(err) || errors.IsTimeout(err) || errors.IsServiceUnavailable(err) ||
+		errors.IsTooManyRequests(err) || errors.IsInternalError(err) ||
+		utilnet.IsConnectionRefused(err) || utilnet.IsConnectionReset(err) || utilnet.IsProbableEOF(err) ||
+		(stderrors.As(err, &netErr) && netErr.Timeout()) {
+		return &storage.BackendUnavailableError{Err: err}
+	}
+	return err
+}
@@ -0,0 +1,119 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/logging"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage"
+	whereaboutstypes "github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+const (
+	eventComponent = "whereabouts"
+	// eventTimeout bounds the time spent recording an event, so that it never holds the CNI ADD.
+	eventTimeout       = 2 * time.Second
+	eventMaxMessageLen = 1024
+
+	reasonAllocationFailed = "IPAllocationFailed"
+	reasonRangeExhausted   = "IPRangeExhausted"
+	reasonIPConflict       = "IPConflict"
+)
+
+// RecordAllocationFailure records a Warning Event on the pod whose IP allocation failed with allocationErr,
+// giving the network, the ranges which were tried and the reason. It is best-effort: failing to record the
+// event is only logged.
+func (i *KubernetesIPAM) RecordAllocationFailure(allocationErr error) {
+	if i.Config.PodName == "" || i.Config.PodNamespace == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), eventTimeout)
+	defer cancel()
+
+	podUID := types.UID(i.Config.PodUID)
+	if podUID == "" {
+		// the pod UID is required for the event to show up in the pod description
+		if pod, err := i.clientSet.CoreV1().Pods(i.Config.PodNamespace).Get(ctx, i.Config.PodName, metav1.GetOptions{}); err == nil {
+			podUID = pod.GetUID()
+		} else {
+			logging.Debugf("failed to get pod %s to record the allocation failure: %v", i.Config.GetPodRef(), err)
+		}
+	}
+
+	networkName := i.Config.NetworkName
+	if networkName == UnnamedNetwork {
+		networkName = i.Config.Name
+	}
+	var ranges []string
+	for _, ipRange := range i.Config.IPRanges {
+		ranges = append(ranges, ipRange.Range)
+	}
+	message := fmt.Sprintf("failed to allocate an IP for interface %s on network %q from ranges [%s]: %v",
+		i.IfName, networkName, strings.Join(ranges, ", "), allocationErr)
+	if len(message) > eventMaxMessageLen {
+		message = message[:eventMaxMessageLen]
+	}
+
+	hostname, _ := getNodeName(i)
+	now := metav1.NewTime(time.Now())
+	event := &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s.%x", i.Config.PodName, now.UnixNano()),
+			Namespace: i.Config.PodNamespace,
+		},
+		InvolvedObject: v1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Pod",
+			Name:       i.Config.PodName,
+			Namespace:  i.Config.PodNamespace,
+			UID:        podUID,
+		},
+		Reason:              allocationFailureReason(allocationErr),
+		Message:             message,
+		Type:                v1.EventTypeWarning,
+		Source:              v1.EventSource{Component: eventComponent, Host: hostname},
+		FirstTimestamp:      now,
+		LastTimestamp:       now,
+		Count:               1,
+		ReportingController: eventComponent,
+		ReportingInstance:   hostname,
+	}
+
+	if _, err := i.clientSet.CoreV1().Events(i.Config.PodNamespace).Create(ctx, event, metav1.CreateOptions{}); err != nil {
+		logging.Errorf("failed to record the allocation failure event for pod %s: %v", i.Config.GetPodRef(), err)
+	}
+}
+
+func allocationFailureReason(err error) string {
+	switch storage.CNIError(err).Code {
+	case whereaboutstypes.ErrRangeExhausted:
+		return reasonRangeExhausted
+	case whereaboutstypes.ErrIPConflict:
+		return reasonIPConflict
+	default:
+		return reasonAllocationFailed
+	}
+}
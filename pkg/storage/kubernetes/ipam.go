@@ -22,6 +22,8 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -31,15 +33,13 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
-	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	"k8s.io/client-go/tools/leaderelection"
 	"k8s.io/client-go/tools/leaderelection/resourcelock"
 
-	"github.com/k8snetworkplumbingwg/whereabouts/pkg/allocate"
 	whereaboutsv1alpha1 "github.com/k8snetworkplumbingwg/whereabouts/pkg/api/whereabouts.cni.cncf.io/v1alpha1"
 	wbclient "github.com/k8snetworkplumbingwg/whereabouts/pkg/generated/clientset/versioned"
 	"github.com/k8snetworkplumbingwg/whereabouts/pkg/iphelpers"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/ipmanagement"
 	"github.com/k8snetworkplumbingwg/whereabouts/pkg/logging"
 	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage"
 	whereaboutstypes "github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
@@ -48,6 +48,14 @@ import (
 
 const UnnamedNetwork string = ""
 
+// PoolLeaseLabel marks the leases protecting the IP pools; each is named after the pool it protects.
+const PoolLeaseLabel = "whereabouts.cni.cncf.io/ip-pool-lease"
+
+var (
+	_ storage.Store          = &KubernetesIPAM{}
+	_ storage.NodeSliceStore = &KubernetesIPAM{}
+)
+
 // KubernetesIPAM manages ip blocks in an kubernetes CRD backend
 type KubernetesIPAM struct {
 	Client
@@ -55,6 +63,15 @@ type KubernetesIPAM struct {
 	Namespace   string
 	ContainerID string
 	IfName      string
+
+	// nodeSlice caches the node slice allocated to this node, so that the NodeSlicePool is fetched at most once
+	// per CNI invocation.
+	nodeSlice *nodeSliceAllocation
+}
+
+type nodeSliceAllocation struct {
+	nodeName   string
+	sliceRange string
 }
 
 func newKubernetesIPAM(containerID, ifName string, ipamConf whereaboutstypes.IPAMConfig, namespace string, kubernetesClient Client) *KubernetesIPAM {
@@ -69,20 +86,11 @@ func newKubernetesIPAM(containerID, ifName string, ipamConf whereaboutstypes.IPA
 
 // NewKubernetesIPAM returns a new KubernetesIPAM Client configured to a kubernetes CRD backend
 func NewKubernetesIPAM(containerID, ifName string, ipamConf whereaboutstypes.IPAMConfig) (*KubernetesIPAM, error) {
-	var namespace string
-	if cfg, err := clientcmd.LoadFromFile(ipamConf.Kubernetes.KubeConfigPath); err != nil {
-		return nil, err
-	} else if ctx, ok := cfg.Contexts[cfg.CurrentContext]; ok && ctx != nil {
-		namespace = wbNamespaceFromCtx(ctx)
-	} else {
-		return nil, fmt.Errorf("k8s config: namespace not present in context")
-	}
-
 	kubernetesClient, err := NewClientViaKubeconfig(ipamConf.Kubernetes.KubeConfigPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed instantiating kubernetes client: %v", err)
 	}
-	k8sIPAM := newKubernetesIPAM(containerID, ifName, ipamConf, namespace, *kubernetesClient)
+	k8sIPAM := newKubernetesIPAM(containerID, ifName, ipamConf, Namespace(ipamConf), *kubernetesClient)
 	return k8sIPAM, nil
 }
 
@@ -96,17 +104,16 @@ func NewKubernetesIPAMWithNamespace(containerID, ifName string, ipamConf whereab
 	return k8sIPAM, nil
 }
 
-type PoolIdentifier struct {
-	IpRange     string
-	NetworkName string
-	NodeName    string
-}
+// PoolIdentifier identifies an IPPool.
+type PoolIdentifier = storage.PoolIdentifier
 
 // GetIPPool returns a storage.IPPool for the given range
 func (i *KubernetesIPAM) GetIPPool(ctx context.Context, poolIdentifier PoolIdentifier) (storage.IPPool, error) {
-	name := IPPoolName(poolIdentifier)
+	if i.Config.PerAddressAllocations {
+		return i.getAllocationPool(ctx, poolIdentifier)
+	}
 
-	pool, err := i.getPool(ctx, name, poolIdentifier.IpRange)
+	pool, err := i.GetIPPoolResource(ctx, poolIdentifier)
 	if err != nil {
 		return nil, err
 	}
@@ -116,10 +123,29 @@ func (i *KubernetesIPAM) GetIPPool(ctx context.Context, poolIdentifier PoolIdent
 		return nil, err
 	}
 
+	if pool.Spec.ShardSize > 0 {
+		return i.getShardedPool(ctx, pool, firstIP)
+	}
 	return &KubernetesIPPool{i.client, firstIP, pool}, nil
 }
 
+// NewKubernetesIPPool returns the storage.IPPool of the given IPPool resource, updated with client. The pool is not
+// sharded.
+func NewKubernetesIPPool(client wbclient.Interface, pool *whereaboutsv1alpha1.IPPool) (*KubernetesIPPool, error) {
+	firstIP, _, err := pool.ParseCIDR()
+	if err != nil {
+		return nil, err
+	}
+	return &KubernetesIPPool{client, firstIP, pool}, nil
+}
+
+// IPPoolName returns the name of the IPPool of the given pool. It is made of the network name, the node name and the
+// range of the pool, or derived from those with a hash when they do not make for a valid name.
 func IPPoolName(poolIdentifier PoolIdentifier) string {
+	return objectName(ipPoolOriginalName(poolIdentifier))
+}
+
+func ipPoolOriginalName(poolIdentifier PoolIdentifier) string {
 	if poolIdentifier.NodeName != "" {
 		// fast node range naming convention
 		if poolIdentifier.NetworkName == UnnamedNetwork {
@@ -149,37 +175,65 @@ func normalizeRange(ipRange string) string {
 	return normalized
 }
 
-func (i *KubernetesIPAM) getPool(ctx context.Context, name string, iprange string) (*whereaboutsv1alpha1.IPPool, error) {
+// IPPoolRangeConfiguration returns the configuration of the range the IPPool of the given name holds the allocations
+// of, if it is one of the ranges of the IPAM configuration. The IPPools of node slices are not matched.
+func IPPoolRangeConfiguration(poolName string, ipamConf *whereaboutstypes.IPAMConfig) (whereaboutstypes.RangeConfiguration, bool) {
+	for _, rangeConf := range ipamConf.IPRanges {
+		if IPPoolName(PoolIdentifier{IpRange: rangeConf.Range, NetworkName: ipamConf.NetworkName}) == poolName {
+			return rangeConf, true
+		}
+	}
+	return whereaboutstypes.RangeConfiguration{}, false
+}
+
+// NewIPPool returns the empty IPPool of the given pool, as created by the CNI when it allocates from the pool, or by
+// the controllers provisioning the pools of the network-attachment-definitions ahead of the allocations.
+func NewIPPool(namespace string, poolIdentifier PoolIdentifier, ipamConf whereaboutstypes.IPAMConfig) *whereaboutsv1alpha1.IPPool {
+	var labels map[string]string
+	if ipamConf.TenantNamespaces {
+		labels = map[string]string{TenantNamespaceLabel: "true"}
+	}
+	pool := &whereaboutsv1alpha1.IPPool{ObjectMeta: hashedObjectMeta(ipPoolOriginalName(poolIdentifier), labels)}
+	pool.Namespace = namespace
+	pool.Spec.Range = poolIdentifier.IpRange
+	pool.Spec.Allocations = make(map[string]whereaboutsv1alpha1.IPAllocation)
+	// the pools of the ranges larger than a shard are created sharded, their allocations being held by shards
+	pool.Spec.ShardSize = shardPoolSize(poolIdentifier.IpRange, ipamConf.PoolShardSize)
+	return pool
+}
+
+// GetIPPoolResource reads the IPPool resource of the given pool, creating it when it does not exist yet.
+func (i *KubernetesIPAM) GetIPPoolResource(ctx context.Context, poolIdentifier PoolIdentifier) (*whereaboutsv1alpha1.IPPool, error) {
 	ctxWithTimeout, cancel := context.WithTimeout(ctx, storage.RequestTimeout)
 	defer cancel()
 
+	name := IPPoolName(poolIdentifier)
 	pool, err := i.client.WhereaboutsV1alpha1().IPPools(i.Namespace).Get(ctxWithTimeout, name, metav1.GetOptions{})
 	if err != nil && errors.IsNotFound(err) {
-		// pool does not exist, create it
-		newPool := &whereaboutsv1alpha1.IPPool{}
-		newPool.ObjectMeta.Name = name
-		newPool.Spec.Range = iprange
-		newPool.Spec.Allocations = make(map[string]whereaboutsv1alpha1.IPAllocation)
-		_, err = i.client.WhereaboutsV1alpha1().IPPools(i.Namespace).Create(ctxWithTimeout, newPool, metav1.CreateOptions{})
+		// pool does not exist yet, as no controller provisioned it: create it
+		pool, err = i.client.WhereaboutsV1alpha1().IPPools(i.Namespace).Create(ctxWithTimeout, NewIPPool(i.Namespace, poolIdentifier, i.Config), metav1.CreateOptions{})
 		if err != nil && errors.IsAlreadyExists(err) {
 			// the pool was just created -- allow retry
 			return nil, &temporaryError{err}
 		} else if err != nil {
-			return nil, fmt.Errorf("k8s create error: %s", err)
+			return nil, BackendError(fmt.Errorf("k8s create error: %w", err))
 		}
-		// if the pool was created for the first time, trigger another retry of the allocation loop
-		// so all of the metadata / resourceVersions are populated as necessary by the `client.Get` call
-		return nil, &temporaryError{fmt.Errorf("k8s pool initialized")}
+		// the created pool carries its resourceVersion, so it is allocated from right away
+		return pool, nil
 	} else if err != nil {
-		return nil, fmt.Errorf("k8s get error: %s", err)
+		return nil, BackendError(fmt.Errorf("k8s get error: %w", err))
 	}
 	return pool, nil
 }
 
-// Status tests connectivity to the kubernetes backend
+// Status tests connectivity to the kubernetes backend; it lists at most one IPPool, to keep the probe cheap
+// regardless of the number of pools.
 func (i *KubernetesIPAM) Status(ctx context.Context) error {
-	_, err := i.client.WhereaboutsV1alpha1().IPPools(i.Namespace).List(ctx, metav1.ListOptions{})
-	return err
+	_, err := i.client.WhereaboutsV1alpha1().IPPools(i.Namespace).List(ctx, metav1.ListOptions{Limit: 1})
+	if err != nil {
+		return BackendError(fmt.Errorf("k8s list IPPools error: %w", err))
+	}
+	return nil
 }
 
 // Close partially implements the Store interface
@@ -199,6 +253,21 @@ func (p *KubernetesIPPool) Allocations() []whereaboutstypes.IPReservation {
 	return toIPReservationList(p.pool.Spec.Allocations, p.firstIP)
 }
 
+// Name returns the name of the IPPool resource
+func (p *KubernetesIPPool) Name() string {
+	return p.pool.GetName()
+}
+
+// Namespace returns the namespace of the IPPool resource
+func (p *KubernetesIPPool) Namespace() string {
+	return p.pool.GetNamespace()
+}
+
+// IPPool returns the IPPool resource of the pool, as read or as last updated.
+func (p *KubernetesIPPool) IPPool() *whereaboutsv1alpha1.IPPool {
+	return p.pool
+}
+
 // Update sets the pool allocated IP list to the given IP reservations
 func (p *KubernetesIPPool) Update(ctx context.Context, reservations []whereaboutstypes.IPReservation) error {
 	// marshal the current pool to serve as the base for the patch creation
@@ -244,14 +313,19 @@ func (p *KubernetesIPPool) Update(ctx context.Context, reservations []whereabout
 	}
 
 	// apply the patch
-	_, err = p.client.WhereaboutsV1alpha1().IPPools(orig.GetNamespace()).Patch(ctx, orig.GetName(), types.JSONPatchType, patchData, metav1.PatchOptions{})
+	patched, err := p.client.WhereaboutsV1alpha1().IPPools(orig.GetNamespace()).Patch(ctx, orig.GetName(), types.JSONPatchType, patchData, metav1.PatchOptions{})
 	if err != nil {
 		if errors.IsInvalid(err) {
 			// expect "invalid" errors if any of the jsonpatch "test" Operations fail
 			return &temporaryError{err}
 		}
-		return err
+		if errors.IsNotFound(err) {
+			// the pool was deleted while empty, it is created again on retry
+			return &temporaryError{err}
+		}
+		return BackendError(err)
 	}
+	p.pool = patched
 
 	return nil
 }
@@ -259,15 +333,16 @@ func (p *KubernetesIPPool) Update(ctx context.Context, reservations []whereabout
 func toIPReservationList(allocations map[string]whereaboutsv1alpha1.IPAllocation, firstip net.IP) []whereaboutstypes.IPReservation {
 	reservelist := []whereaboutstypes.IPReservation{}
 	for offset, a := range allocations {
-		numOffset, err := strconv.ParseInt(offset, 10, 64)
+		numOffset, err := strconv.ParseUint(offset, 10, 64)
 		if err != nil {
-			// allocations that are invalid int64s should be ignored
+			// allocations that are invalid uint64s should be ignored, ippool-fsck reports them
 			// toAllocationMap should be the only writer of offsets, via `fmt.Sprintf("%d", ...)``
 			logging.Errorf("Error decoding ip offset (backend: kubernetes): %v", err)
 			continue
 		}
-		ip := iphelpers.IPAddOffset(firstip, uint64(numOffset))
-		reservelist = append(reservelist, whereaboutstypes.IPReservation{IP: ip, ContainerID: a.ContainerID, PodRef: a.PodRef, IfName: a.IfName})
+		ip := iphelpers.IPAddOffset(firstip, numOffset)
+		reservelist = append(reservelist, whereaboutstypes.IPReservation{IP: ip, ContainerID: a.ContainerID, PodRef: a.PodRef, IfName: a.IfName,
+			IsStatic: a.IsStatic})
 	}
 	return reservelist
 }
@@ -279,7 +354,8 @@ func toAllocationMap(reservelist []whereaboutstypes.IPReservation, firstip net.I
 		if err != nil {
 			return nil, err
 		}
-		allocations[fmt.Sprintf("%d", index)] = whereaboutsv1alpha1.IPAllocation{ContainerID: r.ContainerID, PodRef: r.PodRef, IfName: r.IfName}
+		allocations[fmt.Sprintf("%d", index)] = whereaboutsv1alpha1.IPAllocation{ContainerID: r.ContainerID, PodRef: r.PodRef, IfName: r.IfName,
+			IsStatic: r.IsStatic}
 	}
 	return allocations, nil
 }
@@ -288,11 +364,16 @@ func toAllocationMap(reservelist []whereaboutstypes.IPReservation, firstip net.I
 type KubernetesOverlappingRangeStore struct {
 	client    wbclient.Interface
 	namespace string
+	// podUID is the UID of the pod the reservations are made for, which owns them when they are in its namespace
+	podUID types.UID
 }
 
 // GetOverlappingRangeStore returns a clusterstore interface
 func (i *KubernetesIPAM) GetOverlappingRangeStore() (storage.OverlappingRangeStore, error) {
-	return &KubernetesOverlappingRangeStore{i.client, i.Namespace}, nil
+	if i.Config.PerAddressAllocations {
+		return &KubernetesAllocationStore{i.client, i.Namespace}, nil
+	}
+	return &KubernetesOverlappingRangeStore{client: i.client, namespace: i.Namespace, podUID: types.UID(i.Config.PodUID)}, nil
 }
 
 // IsAllocatedInOverlappingRange checks for IP addresses to see if they're allocated cluster wide, for overlapping
@@ -311,7 +392,7 @@ func (c *KubernetesOverlappingRangeStore) GetOverlappingRangeIPReservation(ctx c
 		return nil, nil
 	} else if err != nil {
 		logging.Errorf("k8s get OverlappingRangeIPReservation error: %s", err)
-		return nil, fmt.Errorf("k8s get OverlappingRangeIPReservation error: %s", err)
+		return nil, BackendError(fmt.Errorf("k8s get OverlappingRangeIPReservation error: %w", err))
 	}
 
 	logging.Debugf("Normalized IP is reserved; normalized IP: %q, IP: %q, networkName: %q",
@@ -319,13 +400,22 @@ func (c *KubernetesOverlappingRangeStore) GetOverlappingRangeIPReservation(ctx c
 	return r, nil
 }
 
-// UpdateOverlappingRangeAllocation updates clusterwide allocation for overlapping ranges.
+// UpdateOverlappingRangeAllocation updates clusterwide allocation for overlapping ranges. A reservation is only
+// released for the container interface it was made for, and only as it was read, so a late release of a previous
+// owner of the IP cannot delete the reservation of its current owner.
 func (c *KubernetesOverlappingRangeStore) UpdateOverlappingRangeAllocation(ctx context.Context, mode int, ip net.IP,
-	podRef, ifName, networkName string) error {
-	normalizedIP := NormalizeIP(ip, networkName)
-
+	containerID, podRef, ifName, networkName string) error {
+	labels := map[string]string{IPLabel: ip.String()}
+	if networkName != UnnamedNetwork {
+		labels[PoolNetworkLabel] = networkName
+	}
 	clusteripres := &whereaboutsv1alpha1.OverlappingRangeIPReservation{
-		ObjectMeta: metav1.ObjectMeta{Name: normalizedIP, Namespace: c.namespace},
+		ObjectMeta: hashedObjectMeta(normalizeIP(ip, networkName), labels),
+	}
+	clusteripres.Namespace = c.namespace
+	if clusteripres.Annotations != nil {
+		// the IP can't be told from the hashed name
+		clusteripres.Annotations[IPAnnotation] = ip.String()
 	}
 
 	var err error
@@ -336,29 +426,95 @@ func (c *KubernetesOverlappingRangeStore) UpdateOverlappingRangeAllocation(ctx c
 		verb = "allocate"
 
 		clusteripres.Spec = whereaboutsv1alpha1.OverlappingRangeIPReservationSpec{
-			PodRef: podRef,
-			IfName: ifName,
+			IP:          ip.String(),
+			NetworkName: networkName,
+			ContainerID: containerID,
+			PodRef:      podRef,
+			IfName:      ifName,
+		}
+		// the pod can only own the reservations of its namespace, the garbage collector deleting the dependents
+		// of owners of another namespace
+		if podNamespace, podName, ok := strings.Cut(podRef, "/"); ok && c.podUID != "" && podNamespace == c.namespace {
+			clusteripres.OwnerReferences = []metav1.OwnerReference{{
+				APIVersion: "v1",
+				Kind:       "Pod",
+				Name:       podName,
+				UID:        c.podUID,
+			}}
 		}
 
 		_, err = c.client.WhereaboutsV1alpha1().OverlappingRangeIPReservations(c.namespace).Create(
 			ctx, clusteripres, metav1.CreateOptions{})
+		if errors.IsAlreadyExists(err) {
+			return &storage.OverlappingRangeReservedError{IP: ip, Err: err}
+		}
 
 	case whereaboutstypes.Deallocate:
 		verb = "deallocate"
-		err = c.client.WhereaboutsV1alpha1().OverlappingRangeIPReservations(c.namespace).Delete(ctx, clusteripres.GetName(), metav1.DeleteOptions{})
+		err = c.releaseOverlappingRangeReservation(ctx, clusteripres.GetName(), containerID, podRef, ifName)
 	}
 
 	if err != nil {
-		return err
+		return BackendError(err)
 	}
 
 	logging.Debugf("K8s UpdateOverlappingRangeAllocation success on %v: %+v", verb, clusteripres)
 	return nil
 }
 
+// TransferOverlappingRangeReservation hands the reservation of ip over to another container interface of its pod,
+// conditioned on the resource version it was read at.
+func (c *KubernetesOverlappingRangeStore) TransferOverlappingRangeReservation(ctx context.Context,
+	reservation *whereaboutsv1alpha1.OverlappingRangeIPReservation, ip net.IP, containerID, ifName, _ string) error {
+	updated := reservation.DeepCopy()
+	updated.Spec.ContainerID = containerID
+	updated.Spec.IfName = ifName
+	_, err := c.client.WhereaboutsV1alpha1().OverlappingRangeIPReservations(c.namespace).Update(ctx, updated, metav1.UpdateOptions{})
+	if errors.IsConflict(err) || errors.IsNotFound(err) {
+		return &storage.OverlappingRangeChangedError{IP: ip, Err: err}
+	} else if err != nil {
+		return BackendError(fmt.Errorf("k8s update OverlappingRangeIPReservation error: %w", err))
+	}
+	return nil
+}
+
+// releaseOverlappingRangeReservation deletes the reservation of the given name when it belongs to the container
+// interface. The deletion is conditioned on the reservation being the one read, and is tried again when it changed
+// meanwhile.
+func (c *KubernetesOverlappingRangeStore) releaseOverlappingRangeReservation(ctx context.Context, name, containerID, podRef, ifName string) error {
+	reservations := c.client.WhereaboutsV1alpha1().OverlappingRangeIPReservations(c.namespace)
+	for attempt := 0; attempt < storage.DatastoreRetries; attempt++ {
+		reservation, err := reservations.Get(ctx, name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			// already released, or garbage collected along with its pod
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if !storage.IsOverlappingRangeReservationOwner(reservation.Spec, containerID, podRef, ifName) {
+			logging.Debugf("Overlapping range reservation %s belongs to container %s of pod %s, not releasing it for container %s",
+				name, reservation.Spec.ContainerID, reservation.Spec.PodRef, containerID)
+			return nil
+		}
+		err = reservations.Delete(ctx, name, metav1.DeleteOptions{
+			Preconditions: &metav1.Preconditions{UID: &reservation.UID, ResourceVersion: &reservation.ResourceVersion},
+		})
+		if !errors.IsConflict(err) {
+			return err
+		}
+		logging.Debugf("Overlapping range reservation %s changed while being released, reading it again", name)
+	}
+	return fmt.Errorf("overlapping range reservation %s kept changing while being released", name)
+}
+
 // NormalizeIP normalizes the IP. This is important for IPv6 which doesn't make for valid CR names. It also allows us
-// to add the network-name when it's different from the unnamed network.
+// to add the network-name when it's different from the unnamed network. The name is hashed when the network name does
+// not make for a valid name.
 func NormalizeIP(ip net.IP, networkName string) string {
+	return objectName(normalizeIP(ip, networkName))
+}
+
+func normalizeIP(ip net.IP, networkName string) string {
 	ipStr := fmt.Sprint(ip)
 	if ipStr[len(ipStr)-1] == ':' {
 		ipStr += "0"
@@ -406,7 +562,7 @@ func getNodeName(ipam *KubernetesIPAM) (string, error) {
 
 // newLeaderElector creates a new leaderelection.LeaderElector and associated
 // channels by which to observe elections and depositions.
-func newLeaderElector(ctx context.Context, clientset kubernetes.Interface, namespace string, ipamConf *KubernetesIPAM) (*leaderelection.LeaderElector, chan struct{}, chan struct{}) {
+func newLeaderElector(clientset kubernetes.Interface, namespace, leaseName string, ipamConf *KubernetesIPAM, identity string) (*leaderelection.LeaderElector, chan struct{}, chan struct{}) {
 	//log.WithField("context", "leaderelection")
 	// leaderOK will block gRPC startup until it's closed.
 	leaderOK := make(chan struct{})
@@ -414,21 +570,6 @@ func newLeaderElector(ctx context.Context, clientset kubernetes.Interface, names
 	// we are deposed as leader so that we can clean up.
 	deposed := make(chan struct{})
 
-	leaseName := "whereabouts"
-	if ipamConf.Config.NodeSliceSize != "" {
-		// we lock per IP Pool so just use the pool name for the lease name
-		hostname, err := getNodeName(ipamConf)
-		if err != nil {
-			logging.Errorf("Failed to create leader elector: %v", err)
-			return nil, leaderOK, deposed
-		}
-		nodeSliceRange, err := GetNodeSlicePoolRange(ctx, ipamConf, hostname)
-		if err != nil {
-			logging.Errorf("Failed to create leader elector: %v", err)
-			return nil, leaderOK, deposed
-		}
-		leaseName = IPPoolName(PoolIdentifier{IpRange: nodeSliceRange, NodeName: hostname, NetworkName: ipamConf.Config.NetworkName})
-	}
 	logging.Debugf("using lease with name: %v", leaseName)
 
 	var rl = &resourcelock.LeaseLock{
@@ -438,8 +579,9 @@ func newLeaderElector(ctx context.Context, clientset kubernetes.Interface, names
 		},
 		Client: clientset.CoordinationV1(),
 		LockConfig: resourcelock.ResourceLockConfig{
-			Identity: fmt.Sprintf("%s/%s", ipamConf.Config.PodNamespace, ipamConf.Config.PodName),
+			Identity: identity,
 		},
+		Labels: map[string]string{PoolLeaseLabel: "true"},
 	}
 
 	// Make the leader elector, ready to be used in the Workgroup.
@@ -470,16 +612,80 @@ func newLeaderElector(ctx context.Context, clientset kubernetes.Interface, names
 	return le, leaderOK, deposed
 }
 
+// LeaseNames returns the names of the leases protecting the pools of the given IPAM client, one per pool, in the
+// order they are to be acquired: always acquiring them in the same order prevents concurrent clients from
+// deadlocking each other.
+func LeaseNames(ctx context.Context, ipam *KubernetesIPAM) ([]string, error) {
+	if ipam.Config.NodeSliceSize != "" {
+		hostname, nodeSliceRange, err := ipam.NodeSliceRange(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return []string{IPPoolName(PoolIdentifier{IpRange: nodeSliceRange, NodeName: hostname, NetworkName: ipam.Config.NetworkName})}, nil
+	}
+
+	// we lock per IP Pool so just use the pool names for the lease names
+	var leaseNames []string
+	for _, ipRange := range ipam.Config.IPRanges {
+		leaseName := IPPoolName(PoolIdentifier{IpRange: ipRange.Range, NetworkName: ipam.Config.NetworkName})
+		if !slices.Contains(leaseNames, leaseName) {
+			leaseNames = append(leaseNames, leaseName)
+		}
+	}
+	sort.Strings(leaseNames)
+	return leaseNames, nil
+}
+
 // IPManagement manages ip allocation and deallocation from a storage perspective
 func IPManagement(ctx context.Context, mode int, ipamConf whereaboutstypes.IPAMConfig, client *KubernetesIPAM) ([]net.IPNet, error) {
 	var newips []net.IPNet
 
 	if ipamConf.PodName == "" {
-		return newips, fmt.Errorf("IPAM client initialization error: no pod name")
+		return newips, &storage.InvalidConfigError{Err: fmt.Errorf("IPAM client initialization error: no pod name")}
+	}
+
+	err := RunAsLeader(ctx, client, ipamConf.GetPodRef(), func(ctx context.Context) error {
+		var err error
+		newips, err = IPManagementKubernetesUpdate(ctx, mode, client, ipamConf)
+		return err
+	})
+	logging.Debugf("IPManagement: %v, %v", newips, err)
+	return newips, err
+}
+
+// RunAsLeader runs fn once elected leader of the leases protecting the pools of client, and steps down afterwards.
+// identity is the holder identity recorded in the leases. In optimistic concurrency mode, as with per-address
+// allocations, fn is run right away.
+func RunAsLeader(ctx context.Context, client *KubernetesIPAM, identity string, fn func(ctx context.Context) error) error {
+	if client.Config.OptimisticConcurrency {
+		// the pool updates are protected by their resourceVersion alone
+		return fn(ctx)
+	}
+	if client.Config.PerAddressAllocations {
+		// each IP is reserved by creating its own object, which only one allocator can do
+		return fn(ctx)
 	}
 
+	leaseNames, err := LeaseNames(ctx, client)
+	if err != nil {
+		return err
+	}
+	return runAsLeaderOfAll(ctx, client, leaseNames, identity, fn)
+}
+
+// runAsLeaderOfAll acquires the leases one after the other, in the given order, and runs fn once all are held.
+func runAsLeaderOfAll(ctx context.Context, client *KubernetesIPAM, leaseNames []string, identity string, fn func(ctx context.Context) error) error {
+	if len(leaseNames) == 0 {
+		return fn(ctx)
+	}
+	return runAsLeaderOf(ctx, client, leaseNames[0], identity, func(ctx context.Context) error {
+		return runAsLeaderOfAll(ctx, client, leaseNames[1:], identity, fn)
+	})
+}
+
+func runAsLeaderOf(ctx context.Context, client *KubernetesIPAM, leaseName string, identity string, fn func(ctx context.Context) error) error {
 	// setup leader election
-	le, leader, deposed := newLeaderElector(ctx, client.clientSet, client.Namespace, client)
+	le, leader, deposed := newLeaderElector(client.clientSet, client.Namespace, leaseName, client, identity)
 	var wg sync.WaitGroup
 	wg.Add(2)
 
@@ -492,12 +698,12 @@ func IPManagement(ctx context.Context, mode int, ipamConf whereaboutstypes.IPAMC
 		for {
 			select {
 			case <-ctx.Done():
-				err = fmt.Errorf("time limit exceeded while waiting to become leader")
+				err = &storage.LeaseTimeoutError{Err: ctx.Err()}
 				stopM <- struct{}{}
 				return
 			case <-leader:
 				logging.Debugf("Elected as leader, do processing")
-				newips, err = IPManagementKubernetesUpdate(ctx, mode, client, ipamConf)
+				err = fn(ctx)
 				stopM <- struct{}{}
 				return
 			case <-deposed:
@@ -528,8 +734,7 @@ func IPManagement(ctx context.Context, mode int, ipamConf whereaboutstypes.IPAMC
 	}()
 	wg.Wait()
 	close(stopM)
-	logging.Debugf("IPManagement: %v, %v", newips, err)
-	return newips, err
+	return err
 }
 
 func GetNodeSlicePoolRange(ctx context.Context, ipam *KubernetesIPAM, nodeName string) (string, error) {
@@ -549,6 +754,24 @@ func GetNodeSlicePoolRange(ctx context.Context, ipam *KubernetesIPAM, nodeName s
 	return "", fmt.Errorf("no allocated node slice for node")
 }
 
+// NodeSliceRange returns the name of this node and the range of the node slice allocated to it. The
+// NodeSlicePool is only fetched the first time, the result being reused afterwards.
+func (i *KubernetesIPAM) NodeSliceRange(ctx context.Context) (string, string, error) {
+	if i.nodeSlice == nil {
+		hostname, err := getNodeName(i)
+		if err != nil {
+			logging.Errorf("Failed to get node hostname: %v", err)
+			return "", "", err
+		}
+		sliceRange, err := GetNodeSlicePoolRange(ctx, i, hostname)
+		if err != nil {
+			return "", "", err
+		}
+		i.nodeSlice = &nodeSliceAllocation{nodeName: hostname, sliceRange: sliceRange}
+	}
+	return i.nodeSlice.nodeName, i.nodeSlice.sliceRange, nil
+}
+
 func getNodeSliceName(ipam *KubernetesIPAM) string {
 	if ipam.Config.NetworkName == UnnamedNetwork {
 		return ipam.Config.Name
@@ -558,180 +781,5 @@ func getNodeSliceName(ipam *KubernetesIPAM) string {
 
 // IPManagementKubernetesUpdate manages k8s updates
 func IPManagementKubernetesUpdate(ctx context.Context, mode int, ipam *KubernetesIPAM, ipamConf whereaboutstypes.IPAMConfig) ([]net.IPNet, error) {
-	logging.Debugf("IPManagement -- mode: %d / containerID: %q / podRef: %q / ifName: %q ", mode, ipam.ContainerID, ipamConf.GetPodRef(), ipam.IfName)
-
-	var newips []net.IPNet
-	var newip net.IPNet
-	// Skip invalid modes
-	switch mode {
-	case whereaboutstypes.Allocate, whereaboutstypes.Deallocate:
-	default:
-		return newips, fmt.Errorf("got an unknown mode passed to IPManagement: %v", mode)
-	}
-
-	var overlappingrangestore storage.OverlappingRangeStore
-	var pool storage.IPPool
-	var err error
-
-	requestCtx, requestCancel := context.WithTimeout(ctx, storage.RequestTimeout)
-	defer requestCancel()
-
-	// Check our connectivity first
-	if err := ipam.Status(requestCtx); err != nil {
-		logging.Errorf("IPAM connectivity error: %v", err)
-		return newips, err
-	}
-
-	// handle the ip add/del until successful
-	var overlappingrangeallocations []whereaboutstypes.IPReservation
-	var ipforoverlappingrangeupdate net.IP
-	skipOverlappingRangeUpdate := false
-	for _, ipRange := range ipamConf.IPRanges {
-	RETRYLOOP:
-		for j := 0; j < storage.DatastoreRetries; j++ {
-			select {
-			case <-ctx.Done():
-				break RETRYLOOP
-			default:
-				// retry the IPAM loop if the context has not been cancelled
-			}
-			overlappingrangestore, err = ipam.GetOverlappingRangeStore()
-			if err != nil {
-				logging.Errorf("IPAM error getting OverlappingRangeStore: %v", err)
-				return newips, err
-			}
-			poolIdentifier := PoolIdentifier{IpRange: ipRange.Range, NetworkName: ipamConf.NetworkName}
-			if ipamConf.NodeSliceSize != "" {
-				hostname, err := getNodeName(ipam)
-				if err != nil {
-					logging.Errorf("Failed to get node hostname: %v", err)
-					return newips, err
-				}
-				poolIdentifier.NodeName = hostname
-				nodeSliceRange, err := GetNodeSlicePoolRange(ctx, ipam, hostname)
-				if err != nil {
-					return newips, err
-				}
-				_, ipNet, err := net.ParseCIDR(nodeSliceRange)
-				if err != nil {
-					logging.Errorf("Error parsing node slice cidr to net.IPNet: %v", err)
-					return newips, err
-				}
-				poolIdentifier.IpRange = nodeSliceRange
-				rangeStart, err := iphelpers.FirstUsableIP(*ipNet)
-				if err != nil {
-					logging.Errorf("Error parsing node slice cidr to range start: %v", err)
-					return newips, err
-				}
-				rangeEnd, err := iphelpers.LastUsableIP(*ipNet)
-				if err != nil {
-					logging.Errorf("Error parsing node slice cidr to range start: %v", err)
-					return newips, err
-				}
-				ipRange = whereaboutstypes.RangeConfiguration{
-					Range:      ipRange.Range,
-					RangeStart: rangeStart,
-					RangeEnd:   rangeEnd,
-				}
-			}
-			logging.Debugf("using pool identifier: %v", poolIdentifier)
-			pool, err = ipam.GetIPPool(requestCtx, poolIdentifier)
-			if err != nil {
-				logging.Errorf("IPAM error reading pool allocations (attempt: %d): %v", j, err)
-				if e, ok := err.(storage.Temporary); ok && e.Temporary() {
-					continue
-				}
-				return newips, err
-			}
-
-			reservelist := pool.Allocations()
-			reservelist = append(reservelist, overlappingrangeallocations...)
-			var updatedreservelist []whereaboutstypes.IPReservation
-			switch mode {
-			case whereaboutstypes.Allocate:
-				newip, updatedreservelist, err = allocate.AssignIP(ipRange, reservelist, ipam.ContainerID, ipamConf.GetPodRef(), ipam.IfName)
-				if err != nil {
-					logging.Errorf("Error assigning IP: %v", err)
-					return newips, err
-				}
-				// Now check if this is allocated overlappingrange wide
-				// When it's allocated overlappingrange wide, we add it to a local reserved list
-				// And we try again.
-				if ipamConf.OverlappingRanges {
-					overlappingRangeIPReservation, err := overlappingrangestore.GetOverlappingRangeIPReservation(requestCtx, newip.IP,
-						ipamConf.GetPodRef(), ipamConf.NetworkName)
-					if err != nil {
-						logging.Errorf("Error getting cluster wide IP allocation: %v", err)
-						return newips, err
-					}
-
-					if overlappingRangeIPReservation != nil {
-						if overlappingRangeIPReservation.Spec.PodRef != ipamConf.GetPodRef() {
-							logging.Debugf("Continuing loop, IP is already allocated (possibly from another range): %v", newip)
-							// We create "dummy" records here for evaluation, but, we need to filter those out later.
-							overlappingrangeallocations = append(overlappingrangeallocations, whereaboutstypes.IPReservation{IP: newip.IP, IsAllocated: true})
-							continue
-						}
-
-						skipOverlappingRangeUpdate = true
-					}
-
-					ipforoverlappingrangeupdate = newip.IP
-				}
-
-			case whereaboutstypes.Deallocate:
-				updatedreservelist, ipforoverlappingrangeupdate = allocate.DeallocateIP(reservelist, ipam.ContainerID, ipam.IfName)
-				if ipforoverlappingrangeupdate == nil {
-					// Do not fail if allocation was not found.
-					logging.Debugf("Failed to find allocation for container ID: %s", ipam.ContainerID)
-					return nil, nil
-				}
-			}
-
-			// Clean out any dummy records from the reservelist...
-			var usereservelist []whereaboutstypes.IPReservation
-			for _, rl := range updatedreservelist {
-				if !rl.IsAllocated {
-					usereservelist = append(usereservelist, rl)
-				}
-			}
-
-			// Manual race condition testing
-			if ipamConf.SleepForRace > 0 {
-				time.Sleep(time.Duration(ipamConf.SleepForRace) * time.Second)
-			}
-
-			err = pool.Update(requestCtx, usereservelist)
-			if err != nil {
-				logging.Errorf("IPAM error updating pool (attempt: %d): %v", j, err)
-				if e, ok := err.(storage.Temporary); ok && e.Temporary() {
-					continue
-				}
-				break RETRYLOOP
-			}
-			break RETRYLOOP
-		}
-
-		if ipamConf.OverlappingRanges {
-			if !skipOverlappingRangeUpdate {
-				err = overlappingrangestore.UpdateOverlappingRangeAllocation(requestCtx, mode, ipforoverlappingrangeupdate,
-					ipamConf.GetPodRef(), ipam.IfName, ipamConf.NetworkName)
-				if err != nil {
-					logging.Errorf("Error performing UpdateOverlappingRangeAllocation: %v", err)
-					return newips, err
-				}
-			}
-		}
-
-		newips = append(newips, newip)
-	}
-	return newips, err
-}
-
-func wbNamespaceFromCtx(ctx *clientcmdapi.Context) string {
-	namespace := ctx.Namespace
-	if namespace == "" {
-		return metav1.NamespaceSystem
-	}
-	return namespace
+	return ipmanagement.Manage(ctx, mode, ipam, ipamConf, ipam.ContainerID, ipam.IfName)
 }
@@ -16,7 +16,32 @@
 
 package kubernetes
 
-import "testing"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	jsonpatch "gopkg.in/evanphx/json-patch.v4"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	whereaboutsv1alpha1 "github.com/k8snetworkplumbingwg/whereabouts/pkg/api/whereabouts.cni.cncf.io/v1alpha1"
+	wbfake "github.com/k8snetworkplumbingwg/whereabouts/pkg/generated/clientset/versioned/fake"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage"
+	whereaboutstypes "github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
 
 func TestIPPoolName(t *testing.T) {
 	cases := []struct {
@@ -68,3 +93,624 @@ func TestIPPoolName(t *testing.T) {
 		})
 	}
 }
+
+func TestIPManagementAPIRoundTrips(t *testing.T) {
+	const (
+		namespace   = "kube-system"
+		networkName = "meganet"
+		nodeName    = "hypernode"
+		sliceRange  = "10.0.0.0/28"
+	)
+
+	cases := []struct {
+		name                  string
+		nodeSliceSize         string
+		ipRanges              []string
+		pool                  PoolIdentifier
+		expectedNodeSliceGets int
+	}{
+		{
+			name:     "cluster wide pool",
+			ipRanges: []string{"10.0.0.0/24"},
+			pool:     PoolIdentifier{IpRange: "10.0.0.0/24", NetworkName: networkName},
+		},
+		{
+			name:                  "node slice pool with several ranges",
+			nodeSliceSize:         "/28",
+			ipRanges:              []string{"10.0.0.0/24", "10.0.1.0/24"},
+			pool:                  PoolIdentifier{IpRange: sliceRange, NetworkName: networkName, NodeName: nodeName},
+			expectedNodeSliceGets: 1,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("NODENAME", nodeName)
+
+			wbClientSet := wbfake.NewSimpleClientset(
+				&whereaboutsv1alpha1.IPPool{
+					ObjectMeta: metav1.ObjectMeta{Name: IPPoolName(tc.pool), Namespace: namespace, ResourceVersion: "1"},
+					Spec:       whereaboutsv1alpha1.IPPoolSpec{Range: tc.pool.IpRange, Allocations: map[string]whereaboutsv1alpha1.IPAllocation{}},
+				},
+				&whereaboutsv1alpha1.NodeSlicePool{
+					ObjectMeta: metav1.ObjectMeta{Name: networkName, Namespace: namespace},
+					Status: whereaboutsv1alpha1.NodeSlicePoolStatus{
+						Allocations: []whereaboutsv1alpha1.NodeSliceAllocation{{NodeName: nodeName, SliceRange: sliceRange}},
+					},
+				})
+
+			ipamConf := whereaboutstypes.IPAMConfig{
+				Name:                networkName,
+				NetworkName:         networkName,
+				NodeSliceSize:       tc.nodeSliceSize,
+				PodName:             "pod",
+				PodNamespace:        "default",
+				LeaderLeaseDuration: 1500,
+				LeaderRenewDeadline: 1000,
+				LeaderRetryPeriod:   500,
+			}
+			for _, ipRange := range tc.ipRanges {
+				ipamConf.IPRanges = append(ipamConf.IPRanges, whereaboutstypes.RangeConfiguration{Range: ipRange})
+			}
+			ipam := newKubernetesIPAM("container", "eth0", ipamConf, namespace,
+				*NewKubernetesClient(wbClientSet, k8sfake.NewSimpleClientset()))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if _, err := IPManagement(ctx, whereaboutstypes.Allocate, ipamConf, ipam); err != nil {
+				t.Fatalf("failed to allocate an IP: %v", err)
+			}
+
+			calls := map[string]int{}
+			for _, action := range wbClientSet.Actions() {
+				calls[action.GetVerb()+" "+action.GetResource().Resource]++
+			}
+			if calls["list ippools"] != 1 {
+				t.Errorf("expected a single connectivity probe, got %d IPPool lists", calls["list ippools"])
+			}
+			if calls["get nodeslicepools"] != tc.expectedNodeSliceGets {
+				t.Errorf("expected %d NodeSlicePool gets, got %d", tc.expectedNodeSliceGets, calls["get nodeslicepools"])
+			}
+			if calls["get ippools"] != len(tc.ipRanges) {
+				t.Errorf("expected %d IPPool gets, got %d", len(tc.ipRanges), calls["get ippools"])
+			}
+		})
+	}
+}
+
+func TestStatusOnlyReportsAnUnavailableBackend(t *testing.T) {
+	for _, tc := range []struct {
+		err         error
+		unavailable bool
+	}{
+		{err: apierrors.NewServiceUnavailable("down"), unavailable: true},
+		{err: apierrors.NewForbidden(whereaboutsv1alpha1.Resource("ippools"), "", fmt.Errorf("denied"))},
+	} {
+		wbClientSet := wbfake.NewSimpleClientset()
+		wbClientSet.PrependReactor("list", "ippools", func(k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, tc.err
+		})
+		ipam := newKubernetesIPAM("container", "eth0", whereaboutstypes.IPAMConfig{}, "kube-system",
+			*NewKubernetesClient(wbClientSet, k8sfake.NewSimpleClientset()))
+
+		err := ipam.Status(context.Background())
+		var unavailableErr *storage.BackendUnavailableError
+		if err == nil || errors.As(err, &unavailableErr) != tc.unavailable {
+			t.Errorf("expected %v to be reported as unavailable: %t, got %v", tc.err, tc.unavailable, err)
+		}
+	}
+}
+
+func TestNewPoolIsAllocatedFromRightAway(t *testing.T) {
+	wbClientSet := newShardsClientSet()
+	ipamConf := whereaboutstypes.IPAMConfig{
+		PodName:             "pod",
+		PodNamespace:        "default",
+		LeaderLeaseDuration: 1500,
+		LeaderRenewDeadline: 1000,
+		LeaderRetryPeriod:   500,
+		IPRanges:            []whereaboutstypes.RangeConfiguration{{Range: "10.0.0.0/24"}},
+	}
+	ipam := newKubernetesIPAM("container", "eth0", ipamConf, "kube-system",
+		*NewKubernetesClient(wbClientSet, k8sfake.NewSimpleClientset()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := IPManagement(ctx, whereaboutstypes.Allocate, ipamConf, ipam); err != nil {
+		t.Fatalf("failed to allocate an IP: %v", err)
+	}
+
+	// the pool no controller provisioned is allocated from as created, rather than fetched again
+	calls := map[string]int{}
+	for _, action := range wbClientSet.Actions() {
+		calls[action.GetVerb()+" "+action.GetResource().Resource]++
+	}
+	if calls["get ippools"] != 1 || calls["create ippools"] != 1 || calls["patch ippools"] != 1 {
+		t.Errorf("expected a single get, create and patch of the IPPool, got %v", calls)
+	}
+}
+
+func TestPerPoolLeases(t *testing.T) {
+	const namespace = "kube-system"
+
+	ipamConf := whereaboutstypes.IPAMConfig{
+		NetworkName:         "meganet",
+		PodName:             "pod",
+		PodNamespace:        "default",
+		LeaderLeaseDuration: 1500,
+		LeaderRenewDeadline: 1000,
+		LeaderRetryPeriod:   500,
+		IPRanges: []whereaboutstypes.RangeConfiguration{
+			{Range: "10.0.1.0/24"},
+			{Range: "10.0.0.0/24"},
+			{Range: "10.0.1.0/24", RangeStart: net.ParseIP("10.0.1.128")},
+		},
+	}
+	expectedLeaseNames := []string{"meganet-10.0.0.0-24", "meganet-10.0.1.0-24"}
+
+	wbClientSet := wbfake.NewSimpleClientset()
+	for _, ipRange := range []string{"10.0.0.0/24", "10.0.1.0/24"} {
+		pool := &whereaboutsv1alpha1.IPPool{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            IPPoolName(PoolIdentifier{IpRange: ipRange, NetworkName: ipamConf.NetworkName}),
+				Namespace:       namespace,
+				ResourceVersion: "1",
+			},
+			Spec: whereaboutsv1alpha1.IPPoolSpec{Range: ipRange, Allocations: map[string]whereaboutsv1alpha1.IPAllocation{}},
+		}
+		if err := wbClientSet.Tracker().Add(pool); err != nil {
+			t.Fatalf("failed to add the pool: %v", err)
+		}
+	}
+	k8sClientSet := k8sfake.NewSimpleClientset()
+	ipam := newKubernetesIPAM("container", "eth0", ipamConf, namespace, *NewKubernetesClient(wbClientSet, k8sClientSet))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	leaseNames, err := LeaseNames(ctx, ipam)
+	if err != nil {
+		t.Fatalf("failed to compute the lease names: %v", err)
+	}
+	if !reflect.DeepEqual(leaseNames, expectedLeaseNames) {
+		t.Errorf("expected lease names %v, got %v", expectedLeaseNames, leaseNames)
+	}
+
+	if _, err := IPManagement(ctx, whereaboutstypes.Allocate, ipamConf, ipam); err != nil {
+		t.Fatalf("failed to allocate the IPs: %v", err)
+	}
+
+	leases, err := k8sClientSet.CoordinationV1().Leases(namespace).List(ctx, metav1.ListOptions{LabelSelector: PoolLeaseLabel})
+	if err != nil {
+		t.Fatalf("failed to list the leases: %v", err)
+	}
+	var names []string
+	for _, lease := range leases.Items {
+		names = append(names, lease.GetName())
+	}
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, expectedLeaseNames) {
+		t.Errorf("expected the leases %v, got %v", expectedLeaseNames, names)
+	}
+}
+
+func TestOptimisticConcurrencyAllocation(t *testing.T) {
+	const (
+		namespace  = "kube-system"
+		ipRange    = "10.0.0.0/24"
+		allocators = 50
+	)
+
+	pool := &whereaboutsv1alpha1.IPPool{
+		ObjectMeta: metav1.ObjectMeta{Name: IPPoolName(PoolIdentifier{IpRange: ipRange}), Namespace: namespace, ResourceVersion: "1"},
+		Spec:       whereaboutsv1alpha1.IPPoolSpec{Range: ipRange, Allocations: map[string]whereaboutsv1alpha1.IPAllocation{}},
+	}
+	wbClientSet := wbfake.NewSimpleClientset(pool)
+	wbClientSet.PrependReactor("patch", "ippools", serializedJSONPatchReactor(wbClientSet.Tracker()))
+	// widen the window between reading the pool and patching it, so that the allocators do conflict
+	wbClientSet.PrependReactor("get", "ippools", func(k8stesting.Action) (bool, runtime.Object, error) {
+		time.Sleep(5 * time.Millisecond)
+		return false, nil, nil
+	})
+	k8sClientSet := k8sfake.NewSimpleClientset()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		ips = map[string]string{}
+	)
+	for i := 0; i < allocators; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			ipamConf := whereaboutstypes.IPAMConfig{
+				PodName:               fmt.Sprintf("pod%d", i),
+				PodNamespace:          "default",
+				OptimisticConcurrency: true,
+				IPRanges:              []whereaboutstypes.RangeConfiguration{{Range: ipRange}},
+			}
+			ipam := newKubernetesIPAM(fmt.Sprintf("container%d", i), "eth0", ipamConf, namespace, *NewKubernetesClient(wbClientSet, k8sClientSet))
+			newips, err := IPManagement(ctx, whereaboutstypes.Allocate, ipamConf, ipam)
+			if err != nil {
+				t.Errorf("allocator %d failed: %v", i, err)
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if owner, ok := ips[newips[0].IP.String()]; ok {
+				t.Errorf("IP %s allocated to both %s and %s", newips[0].IP, owner, ipamConf.PodName)
+			}
+			ips[newips[0].IP.String()] = ipamConf.PodName
+		}(i)
+	}
+	wg.Wait()
+
+	updatedPool, err := wbClientSet.WhereaboutsV1alpha1().IPPools(namespace).Get(ctx, pool.GetName(), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get the pool: %v", err)
+	}
+	patches := 0
+	for _, action := range wbClientSet.Actions() {
+		if action.GetVerb() == "patch" {
+			patches++
+		}
+	}
+	if patches <= allocators {
+		t.Errorf("expected the concurrent allocators to conflict, got %d patches for %d allocators", patches, allocators)
+	}
+	if len(updatedPool.Spec.Allocations) != allocators {
+		t.Errorf("expected %d allocations in the pool, got %d", allocators, len(updatedPool.Spec.Allocations))
+	}
+	for _, action := range k8sClientSet.Actions() {
+		if action.GetResource().Resource == "leases" {
+			t.Fatalf("expected no lease traffic, got %s %s", action.GetVerb(), action.GetResource().Resource)
+		}
+	}
+}
+
+func TestOptimisticConcurrencyAllocationFromA64(t *testing.T) {
+	const (
+		namespace = "kube-system"
+		ipRange   = "fd00::/64"
+		// beyond the offsets an int64 holds, as allocated from a random offset of the range
+		highOffset = "18446744073709551000"
+		highIP     = "fd00::ffff:ffff:ffff:fd98"
+	)
+
+	pool := &whereaboutsv1alpha1.IPPool{
+		ObjectMeta: metav1.ObjectMeta{Name: IPPoolName(PoolIdentifier{IpRange: ipRange}), Namespace: namespace, ResourceVersion: "1"},
+		Spec: whereaboutsv1alpha1.IPPoolSpec{Range: ipRange, Allocations: map[string]whereaboutsv1alpha1.IPAllocation{
+			highOffset: {ContainerID: "container0", PodRef: "default/pod0", IfName: "eth0"},
+		}},
+	}
+	wbClientSet := wbfake.NewSimpleClientset(pool)
+	ipamConf := whereaboutstypes.IPAMConfig{
+		PodName:               "pod1",
+		PodNamespace:          "default",
+		OptimisticConcurrency: true,
+		IPRanges:              []whereaboutstypes.RangeConfiguration{{Range: ipRange}},
+	}
+	ipam := newKubernetesIPAM("container1", "eth0", ipamConf, namespace, *NewKubernetesClient(wbClientSet, k8sfake.NewSimpleClientset()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	allocatedIPs := func() map[string]string {
+		t.Helper()
+		ipPool, err := ipam.GetIPPool(ctx, PoolIdentifier{IpRange: ipRange})
+		if err != nil {
+			t.Fatalf("failed to read the IPPool: %v", err)
+		}
+		ips := map[string]string{}
+		for _, reservation := range ipPool.Allocations() {
+			ips[reservation.IP.String()] = reservation.PodRef
+		}
+		return ips
+	}
+
+	newips, err := IPManagement(ctx, whereaboutstypes.Allocate, ipamConf, ipam)
+	if err != nil {
+		t.Fatalf("failed to allocate an IP: %v", err)
+	}
+	expectedIPs := map[string]string{highIP: "default/pod0", newips[0].IP.String(): "default/pod1"}
+	if ips := allocatedIPs(); !reflect.DeepEqual(ips, expectedIPs) {
+		t.Errorf("expected the allocated IPs %v, got %v", expectedIPs, ips)
+	}
+
+	if _, err := IPManagement(ctx, whereaboutstypes.Deallocate, ipamConf, ipam); err != nil {
+		t.Fatalf("failed to release the IP: %v", err)
+	}
+	expectedIPs = map[string]string{highIP: "default/pod0"}
+	if ips := allocatedIPs(); !reflect.DeepEqual(ips, expectedIPs) {
+		t.Errorf("expected the allocated IPs %v, got %v", expectedIPs, ips)
+	}
+}
+
+// serializedJSONPatchReactor applies JSON patches the way the API server does: atomically, failing with an Invalid
+// error when a "test" operation fails, and bumping the resourceVersion.
+func serializedJSONPatchReactor(tracker k8stesting.ObjectTracker) k8stesting.ReactionFunc {
+	var mu sync.Mutex
+	return func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patchAction := action.(k8stesting.PatchAction)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		obj, err := tracker.Get(action.GetResource(), action.GetNamespace(), patchAction.GetName())
+		if err != nil {
+			return true, nil, err
+		}
+		original, err := json.Marshal(obj)
+		if err != nil {
+			return true, nil, err
+		}
+		patch, err := jsonpatch.DecodePatch(patchAction.GetPatch())
+		if err != nil {
+			return true, nil, err
+		}
+		patched, err := patch.Apply(original)
+		if err != nil {
+			return true, nil, apierrors.NewInvalid(whereaboutsv1alpha1.SchemeGroupVersion.WithKind("IPPool").GroupKind(), patchAction.GetName(),
+				field.ErrorList{field.Invalid(field.NewPath("metadata"), nil, err.Error())})
+		}
+
+		updated := &whereaboutsv1alpha1.IPPool{}
+		if err := json.Unmarshal(patched, updated); err != nil {
+			return true, nil, err
+		}
+		resourceVersion, _ := strconv.Atoi(updated.ResourceVersion)
+		updated.ResourceVersion = strconv.Itoa(resourceVersion + 1)
+		if err := tracker.Update(action.GetResource(), updated, action.GetNamespace()); err != nil {
+			return true, nil, err
+		}
+		return true, updated, nil
+	}
+}
+
+func TestOverlappingRangeReservationIsReleasedByItsOwnerOnly(t *testing.T) {
+	const namespace = "kube-system"
+	ip := net.ParseIP("10.0.0.1")
+	wbClientSet := wbfake.NewSimpleClientset()
+	store := &KubernetesOverlappingRangeStore{client: wbClientSet, namespace: namespace}
+	ctx := context.Background()
+
+	if err := store.UpdateOverlappingRangeAllocation(ctx, whereaboutstypes.Allocate, ip, "container1", "default/pod1", "eth0", ""); err != nil {
+		t.Fatalf("failed to reserve the IP: %v", err)
+	}
+	reservation, err := store.GetOverlappingRangeIPReservation(ctx, ip, "default/pod1", "")
+	if err != nil || reservation == nil {
+		t.Fatalf("failed to get the reservation: %v", err)
+	}
+	if reservation.Spec.ContainerID != "container1" || reservation.Spec.IfName != "eth0" {
+		t.Errorf("expected the reservation to record its container interface, got %+v", reservation.Spec)
+	}
+
+	// a late DEL of a previous container of the pod, or of another interface, leaves the reservation alone
+	for _, owner := range [][2]string{{"container0", "eth0"}, {"container1", "net1"}} {
+		if err := store.UpdateOverlappingRangeAllocation(ctx, whereaboutstypes.Deallocate, ip, owner[0], "default/pod1", owner[1], ""); err != nil {
+			t.Fatalf("failed to release the IP: %v", err)
+		}
+		if reservation, err := store.GetOverlappingRangeIPReservation(ctx, ip, "default/pod1", ""); err != nil || reservation == nil {
+			t.Errorf("expected the release of %v to keep the reservation, got %v", owner, err)
+		}
+	}
+
+	wbClientSet.ClearActions()
+	if err := store.UpdateOverlappingRangeAllocation(ctx, whereaboutstypes.Deallocate, ip, "container1", "default/pod1", "eth0", ""); err != nil {
+		t.Fatalf("failed to release the IP: %v", err)
+	}
+	if reservation, err := store.GetOverlappingRangeIPReservation(ctx, ip, "default/pod1", ""); err != nil || reservation != nil {
+		t.Errorf("expected the owner to release the reservation, got %v, %v", reservation, err)
+	}
+	for _, action := range wbClientSet.Actions() {
+		if deleteAction, ok := action.(k8stesting.DeleteAction); ok {
+			if preconditions := deleteAction.GetDeleteOptions().Preconditions; preconditions == nil || preconditions.UID == nil || preconditions.ResourceVersion == nil {
+				t.Errorf("expected the deletion to be conditioned on the reservation read, got %+v", preconditions)
+			}
+		}
+	}
+}
+
+func TestStaleReleaseRacingWithANewReservation(t *testing.T) {
+	const namespace = "kube-system"
+	ip := net.ParseIP("10.0.0.1")
+	name := NormalizeIP(ip, UnnamedNetwork)
+	wbClientSet := wbfake.NewSimpleClientset(&whereaboutsv1alpha1.OverlappingRangeIPReservation{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, UID: "old-uid", ResourceVersion: "1"},
+		Spec:       whereaboutsv1alpha1.OverlappingRangeIPReservationSpec{ContainerID: "old-container", PodRef: "default/old-pod", IfName: "eth0"},
+	})
+	// the reservation of the old pod is released, and the IP reserved by a new pod, between the read of the stale
+	// DEL and its deletion; the API server refuses the deletion, as the preconditions no longer hold
+	deletes := 0
+	wbClientSet.PrependReactor("delete", "overlappingrangeipreservations", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		deletes++
+		if deletes > 1 {
+			return false, nil, nil
+		}
+		newReservation := &whereaboutsv1alpha1.OverlappingRangeIPReservation{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, UID: "new-uid", ResourceVersion: "2"},
+			Spec:       whereaboutsv1alpha1.OverlappingRangeIPReservationSpec{ContainerID: "new-container", PodRef: "default/new-pod", IfName: "eth0"},
+		}
+		if err := wbClientSet.Tracker().Update(whereaboutsv1alpha1.SchemeGroupVersion.WithResource("overlappingrangeipreservations"), newReservation, namespace); err != nil {
+			return true, nil, err
+		}
+		return true, nil, apierrors.NewConflict(whereaboutsv1alpha1.Resource("overlappingrangeipreservations"), name,
+			fmt.Errorf("the UID in the precondition does not match the UID in record"))
+	})
+
+	store := &KubernetesOverlappingRangeStore{client: wbClientSet, namespace: namespace}
+	if err := store.UpdateOverlappingRangeAllocation(context.Background(), whereaboutstypes.Deallocate, ip, "old-container", "default/old-pod", "eth0", ""); err != nil {
+		t.Fatalf("failed to release the IP: %v", err)
+	}
+	reservation, err := store.GetOverlappingRangeIPReservation(context.Background(), ip, "default/new-pod", "")
+	if err != nil || reservation == nil || reservation.Spec.ContainerID != "new-container" {
+		t.Errorf("expected the reservation of the new pod to be kept, got %v, %v", reservation, err)
+	}
+	if deletes != 1 {
+		t.Errorf("expected a single deletion attempt, got %d", deletes)
+	}
+}
+
+func TestOverlappingRangeReservationMetadata(t *testing.T) {
+	ip := net.ParseIP("fd00::1")
+	for _, tc := range []struct {
+		description   string
+		namespace     string
+		expectedOwner bool
+	}{
+		{"the pod owns the reservations of its namespace", "default", true},
+		{"the pod cannot own the reservations of other namespaces", "kube-system", false},
+	} {
+		t.Run(tc.description, func(t *testing.T) {
+			wbClientSet := wbfake.NewSimpleClientset()
+			store := &KubernetesOverlappingRangeStore{client: wbClientSet, namespace: tc.namespace, podUID: "pod-uid"}
+			if err := store.UpdateOverlappingRangeAllocation(context.Background(), whereaboutstypes.Allocate, ip, "container1", "default/pod1", "eth0", "net-1"); err != nil {
+				t.Fatalf("failed to reserve the IP: %v", err)
+			}
+			reservation, err := store.GetOverlappingRangeIPReservation(context.Background(), ip, "default/pod1", "net-1")
+			if err != nil || reservation == nil {
+				t.Fatalf("failed to get the reservation: %v", err)
+			}
+
+			if reservation.Spec.IP != "fd00::1" || reservation.Spec.NetworkName != "net-1" {
+				t.Errorf("expected the IP and the network of the reservation in its spec, got %+v", reservation.Spec)
+			}
+			if reservation.Labels[IPLabel] != LabelValue("fd00::1") || reservation.Annotations[IPAnnotation] != "fd00::1" ||
+				reservation.Labels[PoolNetworkLabel] != "net-1" {
+				t.Errorf("expected the reservation to be labelled with its IP and network, got %v and %v", reservation.Labels, reservation.Annotations)
+			}
+			ownerReferences := reservation.GetOwnerReferences()
+			if !tc.expectedOwner {
+				if len(ownerReferences) != 0 {
+					t.Errorf("expected no owner, got %v", ownerReferences)
+				}
+				return
+			}
+			if len(ownerReferences) != 1 || ownerReferences[0].Kind != "Pod" || ownerReferences[0].Name != "pod1" || ownerReferences[0].UID != "pod-uid" {
+				t.Errorf("expected the reservation to be owned by its pod, got %v", ownerReferences)
+			}
+		})
+	}
+}
+
+func TestOverlappingRangeReservationFollowsANewContainerOfThePod(t *testing.T) {
+	const (
+		namespace = "kube-system"
+		ipRange   = "10.0.0.0/24"
+	)
+
+	wbClientSet := wbfake.NewSimpleClientset(&whereaboutsv1alpha1.IPPool{
+		ObjectMeta: metav1.ObjectMeta{Name: IPPoolName(PoolIdentifier{IpRange: ipRange}), Namespace: namespace, ResourceVersion: "1"},
+		Spec:       whereaboutsv1alpha1.IPPoolSpec{Range: ipRange, Allocations: map[string]whereaboutsv1alpha1.IPAllocation{}},
+	})
+	// the fake tracker sets no resource versions
+	wbClientSet.PrependReactor("create", "overlappingrangeipreservations", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		action.(k8stesting.CreateAction).GetObject().(*whereaboutsv1alpha1.OverlappingRangeIPReservation).ResourceVersion = "1"
+		return false, nil, nil
+	})
+	ipamConf := whereaboutstypes.IPAMConfig{
+		PodName:             "pod",
+		PodNamespace:        "default",
+		OverlappingRanges:   true,
+		IPRanges:            []whereaboutstypes.RangeConfiguration{{Range: ipRange}},
+		LeaderLeaseDuration: 1500,
+		LeaderRenewDeadline: 1000,
+		LeaderRetryPeriod:   500,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	manage := func(mode int, containerID string) {
+		t.Helper()
+		ipam := newKubernetesIPAM(containerID, "eth0", ipamConf, namespace,
+			*NewKubernetesClient(wbClientSet, k8sfake.NewSimpleClientset()))
+		if _, err := IPManagement(ctx, mode, ipamConf, ipam); err != nil {
+			t.Fatalf("failed to manage the IP of %s: %v", containerID, err)
+		}
+	}
+
+	// the pod allocates its IP again from a new container, whose release must free the reservation
+	manage(whereaboutstypes.Allocate, "container1")
+	manage(whereaboutstypes.Allocate, "container2")
+
+	var handedOver bool
+	for _, action := range wbClientSet.Actions() {
+		update, ok := action.(k8stesting.UpdateAction)
+		if !ok || action.GetVerb() != "update" || action.GetResource().Resource != "overlappingrangeipreservations" {
+			continue
+		}
+		reservation := update.GetObject().(*whereaboutsv1alpha1.OverlappingRangeIPReservation)
+		if reservation.Spec.ContainerID != "container2" {
+			t.Errorf("expected the reservation handed over to container2, got %q", reservation.Spec.ContainerID)
+		}
+		if reservation.ResourceVersion == "" {
+			t.Errorf("expected the reservation updated on the resource version it was read at")
+		}
+		handedOver = true
+	}
+	if !handedOver {
+		t.Fatalf("expected the overlapping range reservation to be updated")
+	}
+
+	manage(whereaboutstypes.Deallocate, "container2")
+	reservations, err := wbClientSet.WhereaboutsV1alpha1().OverlappingRangeIPReservations(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list the overlapping range reservations: %v", err)
+	}
+	if len(reservations.Items) != 0 {
+		t.Errorf("expected no overlapping range reservation left, got %v", reservations.Items)
+	}
+}
+
+func TestStaticReservationsStayStatic(t *testing.T) {
+	const (
+		namespace = "kube-system"
+		ipRange   = "10.0.0.0/24"
+	)
+
+	for _, perAddressAllocations := range []bool{false, true} {
+		t.Run(fmt.Sprintf("per address allocations %t", perAddressAllocations), func(t *testing.T) {
+			ipamConf := whereaboutstypes.IPAMConfig{
+				PodName:               "pod",
+				PodNamespace:          "default",
+				PerAddressAllocations: perAddressAllocations,
+				IPRanges:              []whereaboutstypes.RangeConfiguration{{Range: ipRange}},
+			}
+			wbClientSet := wbfake.NewSimpleClientset(&whereaboutsv1alpha1.IPPool{
+				ObjectMeta: metav1.ObjectMeta{Name: IPPoolName(PoolIdentifier{IpRange: ipRange}), Namespace: namespace, ResourceVersion: "1"},
+				Spec:       whereaboutsv1alpha1.IPPoolSpec{Range: ipRange, Allocations: map[string]whereaboutsv1alpha1.IPAllocation{}},
+			})
+			ipam := newKubernetesIPAM("container", "eth0", ipamConf, namespace,
+				*NewKubernetesClient(wbClientSet, k8sfake.NewSimpleClientset()))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			pool, err := ipam.GetIPPool(ctx, PoolIdentifier{IpRange: ipRange})
+			if err != nil {
+				t.Fatalf("failed to read the pool: %v", err)
+			}
+			reservations := []whereaboutstypes.IPReservation{
+				{IP: net.ParseIP("10.0.0.1"), ContainerID: "container", PodRef: "default/pod", IfName: "eth0"},
+				{IP: net.ParseIP("10.0.0.2"), ContainerID: "container", PodRef: "default/pod", IfName: "eth0", IsStatic: true},
+			}
+			if err := pool.Update(ctx, reservations); err != nil {
+				t.Fatalf("failed to update the pool: %v", err)
+			}
+
+			pool, err = ipam.GetIPPool(ctx, PoolIdentifier{IpRange: ipRange})
+			if err != nil {
+				t.Fatalf("failed to read the pool again: %v", err)
+			}
+			static := map[string]bool{}
+			for _, reservation := range pool.Allocations() {
+				static[reservation.IP.String()] = reservation.IsStatic
+			}
+			if expected := map[string]bool{"10.0.0.1": false, "10.0.0.2": true}; !reflect.DeepEqual(static, expected) {
+				t.Errorf("expected the static reservations %v, got %v", expected, static)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kubernetes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// OriginalNameAnnotation holds, on the objects whose name is hashed, the name they would otherwise have had.
+	OriginalNameAnnotation = "whereabouts.cni.cncf.io/original-name"
+	// IPAnnotation holds, on the OverlappingRangeIPReservations whose name is hashed, the IP they reserve.
+	IPAnnotation = "whereabouts.cni.cncf.io/ip"
+	// IPLabel labels the OverlappingRangeIPReservations with the IP they reserve. The IPv6 addresses are not valid
+	// label values, so their label is hashed, the IP being kept in IPAnnotation.
+	IPLabel = IPAnnotation
+
+	// nameHashLength is the number of hexadecimal digits of the hash suffixing the hashed names
+	nameHashLength = 16
+)
+
+// objectName returns name when it is a valid object name, or else a valid name derived from it. The names which
+// are valid are kept as they are, so the objects created before the names were hashed keep being found.
+func objectName(name string) string {
+	if len(validation.IsDNS1123Subdomain(name)) == 0 {
+		return name
+	}
+	return hashedName(name, validation.DNS1123SubdomainMaxLength)
+}
+
+// LabelValue returns the value of a label holding the given value: the value itself when it is a valid label value,
+// or else a valid label value derived from it.
+func LabelValue(value string) string {
+	if len(validation.IsValidLabelValue(value)) == 0 {
+		return value
+	}
+	return hashedName(value, validation.LabelValueMaxLength)
+}
+
+// getLabel returns the original value of the label of an object created from hashedObjectMeta.
+func getLabel(labels, annotations map[string]string, key string) string {
+	if value, ok := annotations[key]; ok {
+		return value
+	}
+	return labels[key]
+}
+
+// hashedName returns a name made of the lowercase alphanumeric characters of name, the others being replaced by
+// dashes, truncated so it is followed by a hash of the whole name within maxLength characters. Both DNS subdomains
+// and label values accept it.
+func hashedName(name string, maxLength int) string {
+	hash := sha256.Sum256([]byte(name))
+	suffix := hex.EncodeToString(hash[:])[:nameHashLength]
+
+	prefix := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(name))
+	if maxPrefixLength := maxLength - nameHashLength - 1; len(prefix) > maxPrefixLength {
+		prefix = prefix[:maxPrefixLength]
+	}
+	prefix = strings.Trim(prefix, "-")
+	if prefix == "" {
+		return suffix
+	}
+	return prefix + "-" + suffix
+}
+
+// hashedObjectMeta returns the metadata of an object named after originalName and labelled with labelValues, once
+// hashed as needed. The values which are hashed are kept in annotations: the original name in OriginalNameAnnotation,
+// and the original value of a label in the annotation of the same key.
+func hashedObjectMeta(originalName string, labelValues map[string]string) metav1.ObjectMeta {
+	objectMeta := metav1.ObjectMeta{Name: objectName(originalName)}
+	annotations := map[string]string{}
+	if objectMeta.Name != originalName {
+		annotations[OriginalNameAnnotation] = originalName
+	}
+	for key, value := range labelValues {
+		if objectMeta.Labels == nil {
+			objectMeta.Labels = map[string]string{}
+		}
+		objectMeta.Labels[key] = LabelValue(value)
+		if objectMeta.Labels[key] != value {
+			annotations[key] = value
+		}
+	}
+	if len(annotations) > 0 {
+		objectMeta.Annotations = annotations
+	}
+	return objectMeta
+}
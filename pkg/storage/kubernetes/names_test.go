@@ -0,0 +1,163 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kubernetes
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	whereaboutstypes "github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+var longNetworkName = strings.Repeat("long-network-name-", 14)
+
+func TestValidNamesAreKept(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		expected string
+	}{
+		{IPPoolName(PoolIdentifier{IpRange: "10.0.0.0/24", NetworkName: "net1"}), "net1-10.0.0.0-24"},
+		{IPPoolName(PoolIdentifier{IpRange: "fd00::/64", NetworkName: "net1", NodeName: "node1"}), "net1-node1-fd00---64"},
+		{NormalizeIP(net.ParseIP("fd00::"), ""), "fd00--0"},
+		{ShardName("10.0.0.0-16", 3), "10.0.0.0-16-shard-3"},
+		{LabelValue("net1"), "net1"},
+	} {
+		if tc.name != tc.expected {
+			t.Errorf("expected name %s, got %s", tc.expected, tc.name)
+		}
+	}
+}
+
+func TestInvalidNamesAreHashed(t *testing.T) {
+	longName := IPPoolName(PoolIdentifier{IpRange: "fd00:1234:5678:9abc::/64", NetworkName: longNetworkName, NodeName: "node1"})
+	for _, tc := range []struct {
+		name      string
+		prefix    string
+		maxLength int
+	}{
+		{longName, "long-network-name-", validation.DNS1123SubdomainMaxLength},
+		{IPPoolName(PoolIdentifier{IpRange: "10.0.0.0/24", NetworkName: "My_Network"}), "my-network-10-0-0-0-24-", validation.DNS1123SubdomainMaxLength},
+		{NormalizeIP(net.ParseIP("::1"), ""), "1-", validation.DNS1123SubdomainMaxLength},
+		{ShardName(strings.Repeat("a", 250), 1), "aaa", validation.DNS1123SubdomainMaxLength},
+		{LabelValue(longNetworkName), "long-network-name-", validation.LabelValueMaxLength},
+	} {
+		if errs := validation.IsDNS1123Subdomain(tc.name); len(errs) > 0 {
+			t.Errorf("expected %s to be a valid name: %v", tc.name, errs)
+		}
+		if len(tc.name) > tc.maxLength || !strings.HasPrefix(tc.name, tc.prefix) {
+			t.Errorf("expected %s to start with %s within %d characters", tc.name, tc.prefix, tc.maxLength)
+		}
+	}
+
+	otherName := IPPoolName(PoolIdentifier{IpRange: "fd00:1234:5678:9abc::/64", NetworkName: longNetworkName, NodeName: "node2"})
+	if otherName == longName {
+		t.Errorf("expected the names of different pools to differ, got %s for both", longName)
+	}
+	if sameName := IPPoolName(PoolIdentifier{IpRange: "fd00:1234:5678:9abc::/64", NetworkName: longNetworkName, NodeName: "node1"}); sameName != longName {
+		t.Errorf("expected the hashed names to be deterministic, got %s and %s", longName, sameName)
+	}
+}
+
+func TestAllocationsOfLongNetworkNames(t *testing.T) {
+	wbClientSet := newShardsClientSet()
+	ipamConf := whereaboutstypes.IPAMConfig{
+		NetworkName:         longNetworkName,
+		PodName:             "pod1",
+		PodNamespace:        "default",
+		OverlappingRanges:   true,
+		LeaderLeaseDuration: 1500,
+		LeaderRenewDeadline: 1000,
+		LeaderRetryPeriod:   500,
+		IPRanges:            []whereaboutstypes.RangeConfiguration{{Range: "fd00:1234:5678:9abc::/64"}},
+	}
+	ipManagement := func(mode int) []net.IPNet {
+		t.Helper()
+		ipam := newKubernetesIPAM("container1", "eth0", ipamConf, shardsNamespace,
+			*NewKubernetesClient(wbClientSet, k8sfake.NewSimpleClientset()))
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		ips, err := IPManagement(ctx, mode, ipamConf, ipam)
+		if err != nil {
+			t.Fatalf("IP management failed: %v", err)
+		}
+		return ips
+	}
+
+	ips := ipManagement(whereaboutstypes.Allocate)
+	if len(ips) != 1 || ips[0].IP.String() != "fd00:1234:5678:9abc::1" {
+		t.Fatalf("unexpected IPs: %v", ips)
+	}
+
+	poolIdentifier := PoolIdentifier{IpRange: "fd00:1234:5678:9abc::/64", NetworkName: longNetworkName}
+	pool := getIPPool(t, wbClientSet, IPPoolName(poolIdentifier))
+	if pool.Annotations[OriginalNameAnnotation] != ipPoolOriginalName(poolIdentifier) {
+		t.Errorf("expected the pool to hold its original name, got %v", pool.Annotations)
+	}
+
+	reservation, err := wbClientSet.WhereaboutsV1alpha1().OverlappingRangeIPReservations(shardsNamespace).Get(
+		context.Background(), NormalizeIP(ips[0].IP, longNetworkName), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get the OverlappingRangeIPReservation: %v", err)
+	}
+	if reservation.Annotations[IPAnnotation] != "fd00:1234:5678:9abc::1" {
+		t.Errorf("expected the reservation to hold its IP, got %v", reservation.Annotations)
+	}
+
+	ipManagement(whereaboutstypes.Deallocate)
+	reservations, err := wbClientSet.WhereaboutsV1alpha1().OverlappingRangeIPReservations(shardsNamespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list the OverlappingRangeIPReservations: %v", err)
+	}
+	if len(reservations.Items) != 0 {
+		t.Errorf("expected the reservation to be released, got %v", reservations.Items)
+	}
+}
+
+func TestPerAddressAllocationsOfLongNetworkNames(t *testing.T) {
+	wbClientSet := newShardsClientSet()
+	ipamConf := perAddressIPAMConfig("pod1", longNetworkName, "192.168.2.0/24")
+	if _, err := perAddressIPManagement(t, wbClientSet, whereaboutstypes.Allocate, ipamConf, "container1"); err != nil {
+		t.Fatalf("failed to allocate: %v", err)
+	}
+
+	allocations := listAllocations(t, wbClientSet)
+	if len(allocations) != 1 {
+		t.Fatalf("expected a single IPAddressAllocation, got %v", allocations)
+	}
+	if network := allocations[0].Labels[PoolNetworkLabel]; len(validation.IsValidLabelValue(network)) > 0 {
+		t.Errorf("expected a valid network label, got %s", network)
+	}
+	if allocations[0].Annotations[PoolNetworkLabel] != longNetworkName {
+		t.Errorf("expected the IPAddressAllocation to hold its network name, got %v", allocations[0].Annotations)
+	}
+
+	pools, err := NewKubernetesClient(wbClientSet, k8sfake.NewSimpleClientset()).ListIPPools()
+	if err != nil {
+		t.Fatalf("failed to list the pools: %v", err)
+	}
+	expectedName := IPPoolName(PoolIdentifier{IpRange: "192.168.2.0/24", NetworkName: longNetworkName})
+	if len(pools) != 1 || pools[0].(*KubernetesAllocationPool).Name() != expectedName {
+		t.Errorf("expected the pool %s, got %v", expectedName, pools)
+	}
+}
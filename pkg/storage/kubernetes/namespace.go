@@ -0,0 +1,108 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/clientcmd"
+
+	wbclient "github.com/k8snetworkplumbingwg/whereabouts/pkg/generated/clientset/versioned"
+	whereaboutstypes "github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+const (
+	// NamespaceEnvVariable is the environment variable holding the namespace of the whereabouts objects.
+	NamespaceEnvVariable = "WHEREABOUTS_NAMESPACE"
+	// TenantNamespaceLabel labels the IPPools kept in the namespace of their network, rather than in the namespace
+	// of the whereabouts objects.
+	TenantNamespaceLabel = "whereabouts.cni.cncf.io/tenant-namespace"
+)
+
+// Namespace returns the namespace of the whereabouts objects of an IPAM configuration: with tenant namespaces, the
+// namespace of the pod, which is the one of its network-attachment-definition; or else the one ResolveNamespace
+// returns.
+func Namespace(ipamConf whereaboutstypes.IPAMConfig) string {
+	if ipamConf.TenantNamespaces && ipamConf.PodNamespace != "" {
+		return ipamConf.PodNamespace
+	}
+	return ResolveNamespace(ipamConf.Kubernetes.Namespace, ipamConf.Kubernetes.KubeConfigPath)
+}
+
+// NetworkNamespace returns the namespace of the whereabouts objects of a network-attachment-definition of
+// nadNamespace, for the controllers which handle networks rather than pods.
+func NetworkNamespace(ipamConf whereaboutstypes.IPAMConfig, nadNamespace string) string {
+	ipamConf.PodNamespace = nadNamespace
+	return Namespace(ipamConf)
+}
+
+// ResolveNamespace returns the namespace of the whereabouts objects: the given namespace, or else the
+// WHEREABOUTS_NAMESPACE environment variable, or else the namespace of the current context of the kubeconfig,
+// or else kube-system. Every binary resolves it this way, so they all look for the objects in the same place.
+func ResolveNamespace(namespace, kubeconfigPath string) string {
+	if namespace != "" {
+		return namespace
+	}
+	if namespace := os.Getenv(NamespaceEnvVariable); namespace != "" {
+		return namespace
+	}
+	if kubeconfigPath != "" {
+		if cfg, err := clientcmd.LoadFromFile(kubeconfigPath); err == nil {
+			if ctx, ok := cfg.Contexts[cfg.CurrentContext]; ok && ctx != nil && ctx.Namespace != "" {
+				return ctx.Namespace
+			}
+		}
+	}
+	return metav1.NamespaceSystem
+}
+
+// CheckNamespace returns an error when IPPools exist outside of the namespace of the whereabouts objects: the
+// binaries resolving a different namespace allocate from these pools, which the garbage collection misses. The pools
+// of tenant namespaces are expected elsewhere.
+func CheckNamespace(ctx context.Context, client wbclient.Interface, namespace string) error {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, listRequestTimeout)
+	defer cancel()
+
+	pools, err := client.WhereaboutsV1alpha1().IPPools(metav1.NamespaceAll).List(ctxWithTimeout, metav1.ListOptions{
+		LabelSelector: "!" + TenantNamespaceLabel,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list the IPPools: %w", err)
+	}
+	otherNamespaces := map[string]struct{}{}
+	for _, pool := range pools.Items {
+		if pool.GetNamespace() != namespace {
+			otherNamespaces[pool.GetNamespace()] = struct{}{}
+		}
+	}
+	if len(otherNamespaces) == 0 {
+		return nil
+	}
+
+	namespaces := make([]string, 0, len(otherNamespaces))
+	for otherNamespace := range otherNamespaces {
+		namespaces = append(namespaces, otherNamespace)
+	}
+	sort.Strings(namespaces)
+	return fmt.Errorf("IPPools exist in namespaces %v rather than in namespace %s: set the same namespace "+
+		"in the `kubernetes.namespace` IPAM parameter, the %s environment variable or the kubeconfig context of "+
+		"every whereabouts component", namespaces, namespace, NamespaceEnvVariable)
+}
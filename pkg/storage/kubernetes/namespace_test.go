@@ -0,0 +1,171 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	whereaboutsv1alpha1 "github.com/k8snetworkplumbingwg/whereabouts/pkg/api/whereabouts.cni.cncf.io/v1alpha1"
+	wbfake "github.com/k8snetworkplumbingwg/whereabouts/pkg/generated/clientset/versioned/fake"
+	whereaboutstypes "github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+const kubeconfigWithNamespace = `apiVersion: v1
+kind: Config
+clusters:
+- name: cluster
+  cluster:
+    server: https://127.0.0.1:6443
+users:
+- name: user
+  user:
+    token: token
+contexts:
+- name: context
+  context:
+    cluster: cluster
+    user: user
+    namespace: kubeconfig-namespace
+current-context: context
+`
+
+func TestResolveNamespace(t *testing.T) {
+	kubeconfigPath := filepath.Join(t.TempDir(), "whereabouts.kubeconfig")
+	if err := os.WriteFile(kubeconfigPath, []byte(kubeconfigWithNamespace), 0600); err != nil {
+		t.Fatalf("failed to write the kubeconfig: %v", err)
+	}
+
+	for _, tc := range []struct {
+		description    string
+		namespace      string
+		envNamespace   string
+		kubeconfigPath string
+		expected       string
+	}{
+		{"the explicit namespace comes first", "explicit-namespace", "env-namespace", kubeconfigPath, "explicit-namespace"},
+		{"the environment comes before the kubeconfig", "", "env-namespace", kubeconfigPath, "env-namespace"},
+		{"the kubeconfig context comes next", "", "", kubeconfigPath, "kubeconfig-namespace"},
+		{"an unreadable kubeconfig is skipped", "", "", filepath.Join(t.TempDir(), "missing"), metav1.NamespaceSystem},
+		{"kube-system comes last", "", "", "", metav1.NamespaceSystem},
+	} {
+		t.Run(tc.description, func(t *testing.T) {
+			t.Setenv(NamespaceEnvVariable, tc.envNamespace)
+			if namespace := ResolveNamespace(tc.namespace, tc.kubeconfigPath); namespace != tc.expected {
+				t.Errorf("expected namespace %s, got %s", tc.expected, namespace)
+			}
+		})
+	}
+
+	t.Run("tenant namespaces come before anything else", func(t *testing.T) {
+		t.Setenv(NamespaceEnvVariable, "env-namespace")
+		ipamConf := whereaboutstypes.IPAMConfig{
+			TenantNamespaces: true,
+			PodNamespace:     "tenant-a",
+			Kubernetes:       whereaboutstypes.KubernetesConfig{KubeConfigPath: kubeconfigPath, Namespace: "explicit-namespace"},
+		}
+		if namespace := Namespace(ipamConf); namespace != "tenant-a" {
+			t.Errorf("expected the namespace of the pod, got %s", namespace)
+		}
+		if namespace := NetworkNamespace(ipamConf, "tenant-b"); namespace != "tenant-b" {
+			t.Errorf("expected the namespace of the network-attachment-definition, got %s", namespace)
+		}
+		ipamConf.TenantNamespaces = false
+		if namespace := NetworkNamespace(ipamConf, "tenant-b"); namespace != "explicit-namespace" {
+			t.Errorf("expected the explicit namespace without tenant namespaces, got %s", namespace)
+		}
+	})
+
+	t.Run("the IPAM configuration is resolved the same way", func(t *testing.T) {
+		t.Setenv(NamespaceEnvVariable, "")
+		ipamConf := whereaboutstypes.IPAMConfig{Kubernetes: whereaboutstypes.KubernetesConfig{KubeConfigPath: kubeconfigPath}}
+		if namespace := Namespace(ipamConf); namespace != "kubeconfig-namespace" {
+			t.Errorf("expected the namespace of the kubeconfig, got %s", namespace)
+		}
+		ipamConf.Kubernetes.Namespace = "explicit-namespace"
+		if namespace := Namespace(ipamConf); namespace != "explicit-namespace" {
+			t.Errorf("expected the namespace of the IPAM configuration, got %s", namespace)
+		}
+	})
+}
+
+func TestCheckNamespace(t *testing.T) {
+	pool := func(namespace, name string) *whereaboutsv1alpha1.IPPool {
+		return &whereaboutsv1alpha1.IPPool{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+	}
+	client := wbfake.NewSimpleClientset(pool("kube-system", "10.0.0.0-24"))
+	if err := CheckNamespace(context.Background(), client, "kube-system"); err != nil {
+		t.Errorf("expected no mismatch, got %v", err)
+	}
+
+	tenantPool := pool("tenant-a", "10.0.3.0-24")
+	tenantPool.Labels = map[string]string{TenantNamespaceLabel: "true"}
+	client = wbfake.NewSimpleClientset(pool("kube-system", "10.0.0.0-24"), pool("whereabouts", "10.0.1.0-24"), pool("other", "10.0.2.0-24"), tenantPool)
+	err := CheckNamespace(context.Background(), client, "kube-system")
+	if err == nil || !strings.Contains(err.Error(), "[other whereabouts]") {
+		t.Errorf("expected the other namespaces to be reported, got %v", err)
+	}
+}
+
+func TestTenantNamespacePools(t *testing.T) {
+	wbClientSet := newShardsClientSet()
+	for pod := 1; pod <= 5; pod++ {
+		ipamConf := whereaboutstypes.IPAMConfig{
+			PodName:             fmt.Sprintf("pod%d", pod),
+			PodNamespace:        "tenant-a",
+			TenantNamespaces:    true,
+			PoolShardSize:       4,
+			LeaderLeaseDuration: 1500,
+			LeaderRenewDeadline: 1000,
+			LeaderRetryPeriod:   500,
+			IPRanges:            []whereaboutstypes.RangeConfiguration{{Range: "10.0.0.0/28"}},
+		}
+		ipam := newKubernetesIPAM(fmt.Sprintf("container%d", pod), "eth0", ipamConf, Namespace(ipamConf),
+			*NewKubernetesClient(wbClientSet, k8sfake.NewSimpleClientset()))
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		_, err := IPManagement(ctx, whereaboutstypes.Allocate, ipamConf, ipam)
+		cancel()
+		if err != nil {
+			t.Fatalf("IP management of pod%d failed: %v", pod, err)
+		}
+	}
+
+	pools, err := wbClientSet.WhereaboutsV1alpha1().IPPools(metav1.NamespaceAll).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list the IPPools: %v", err)
+	}
+	if len(pools.Items) != 3 {
+		t.Fatalf("expected a sharded pool and two shards, got %v", pools.Items)
+	}
+	for _, pool := range pools.Items {
+		if pool.GetNamespace() != "tenant-a" || pool.GetLabels()[TenantNamespaceLabel] != "true" {
+			t.Errorf("expected IPPool %s/%s to be a pool of the tenant namespace, labelled %v",
+				pool.GetNamespace(), pool.GetName(), pool.GetLabels())
+		}
+	}
+	if err := CheckNamespace(context.Background(), wbClientSet, metav1.NamespaceSystem); err != nil {
+		t.Errorf("expected the pools of the tenant namespace not to be reported, got %v", err)
+	}
+}
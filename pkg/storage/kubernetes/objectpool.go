@@ -0,0 +1,114 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/logging"
+)
+
+// ObjectClient is the typed client of the objects of a pool kept as one object per allocated IP.
+type ObjectClient[T any] interface {
+	Create(ctx context.Context, object *T, opts metav1.CreateOptions) (*T, error)
+	Update(ctx context.Context, object *T, opts metav1.UpdateOptions) (*T, error)
+	Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error
+}
+
+// ObjectPoolUpdate brings the objects of a pool kept as one object per allocated IP, keyed by their names, from
+// those read to the desired ones.
+type ObjectPoolUpdate[T any, PT interface {
+	*T
+	metav1.Object
+}] struct {
+	Client ObjectClient[T]
+	// Kind names the objects in the errors.
+	Kind    string
+	Current map[string]T
+	Desired map[string]T
+	// Merge returns the current object holding the reservation of the desired one, and whether it changed.
+	Merge func(current, desired T) (T, bool)
+	// AlreadyExists tells apart the objects concurrently created for the same pool, whose reservation fails with a
+	// temporary error, from the others.
+	AlreadyExists func(ctx context.Context, name string) error
+	// Conflict returns the temporary error of an object changed since it was read.
+	Conflict func(name string, err error) error
+}
+
+// Apply creates the objects of the new reservations, updates the changed ones, and deletes the released ones,
+// provided they were not changed since they were read. The objects it created are deleted when it fails.
+func (u ObjectPoolUpdate[T, PT]) Apply(ctx context.Context) error {
+	var created []string
+	rollback := func() {
+		for _, name := range created {
+			if err := u.Client.Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+				logging.Errorf("failed to delete %s %s of a failed pool update: %v", u.Kind, name, err)
+			}
+		}
+	}
+
+	for name, desired := range u.Desired {
+		current, ok := u.Current[name]
+		if !ok {
+			if _, err := u.Client.Create(ctx, &desired, metav1.CreateOptions{}); err != nil {
+				rollback()
+				if errors.IsAlreadyExists(err) {
+					return u.AlreadyExists(ctx, name)
+				}
+				return BackendError(fmt.Errorf("failed to create %s %s: %w", u.Kind, name, err))
+			}
+			created = append(created, name)
+			continue
+		}
+		merged, changed := u.Merge(current, desired)
+		if !changed {
+			continue
+		}
+		if _, err := u.Client.Update(ctx, &merged, metav1.UpdateOptions{}); err != nil {
+			rollback()
+			if errors.IsConflict(err) || errors.IsNotFound(err) {
+				return u.Conflict(name, err)
+			}
+			return BackendError(fmt.Errorf("failed to update %s %s: %w", u.Kind, name, err))
+		}
+	}
+
+	for name, current := range u.Current {
+		if _, ok := u.Desired[name]; ok {
+			continue
+		}
+		object := PT(&current)
+		uid, resourceVersion := object.GetUID(), object.GetResourceVersion()
+		err := u.Client.Delete(ctx, name, metav1.DeleteOptions{
+			Preconditions: &metav1.Preconditions{UID: &uid, ResourceVersion: &resourceVersion},
+		})
+		if errors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			rollback()
+			if errors.IsConflict(err) {
+				return u.Conflict(name, err)
+			}
+			return BackendError(fmt.Errorf("failed to delete %s %s: %w", u.Kind, name, err))
+		}
+	}
+	return nil
+}
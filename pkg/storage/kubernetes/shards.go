@@ -0,0 +1,258 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	whereaboutsv1alpha1 "github.com/k8snetworkplumbingwg/whereabouts/pkg/api/whereabouts.cni.cncf.io/v1alpha1"
+	wbclient "github.com/k8snetworkplumbingwg/whereabouts/pkg/generated/clientset/versioned"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/iphelpers"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage"
+	whereaboutstypes "github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+// ParentPoolLabel holds, on the shards of a pool, the name of the pool they belong to.
+const ParentPoolLabel = "whereabouts.cni.cncf.io/parent-pool"
+
+var _ storage.IPPool = &KubernetesShardedIPPool{}
+
+// KubernetesShardedIPPool is a pool whose allocations are split across shards: IPPools holding the allocations of
+// consecutive slices of the offsets of its range, created as IPs are allocated from them. Allocating an IP only
+// patches the shard holding it, so the updates stay small however many IPs are allocated, and concurrent
+// allocations only conflict when they pick IPs of the same shard.
+type KubernetesShardedIPPool struct {
+	client  wbclient.Interface
+	firstIP net.IP
+	pool    *whereaboutsv1alpha1.IPPool
+	shards  map[int64]*whereaboutsv1alpha1.IPPool
+}
+
+// shardPoolSize returns the shard size of a new pool for the given range, or 0 when the range is to be kept in a
+// single IPPool: it fits in a single shard, or sharding is disabled.
+func shardPoolSize(ipRange string, shardSize int64) int64 {
+	if shardSize <= 0 {
+		return 0
+	}
+	_, ipNet, err := net.ParseCIDR(ipRange)
+	if err != nil {
+		return 0
+	}
+	ones, bits := ipNet.Mask.Size()
+	if hostBits := bits - ones; hostBits < 63 && int64(1)<<hostBits <= shardSize {
+		return 0
+	}
+	return shardSize
+}
+
+func (i *KubernetesIPAM) getShardedPool(ctx context.Context, pool *whereaboutsv1alpha1.IPPool, firstIP net.IP) (storage.IPPool, error) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, storage.RequestTimeout)
+	defer cancel()
+
+	shardList, err := i.client.WhereaboutsV1alpha1().IPPools(pool.GetNamespace()).List(ctxWithTimeout, metav1.ListOptions{LabelSelector: ShardSelector(pool.GetName()).String()})
+	if err != nil {
+		return nil, BackendError(fmt.Errorf("k8s list IPPool shards error: %w", err))
+	}
+
+	shardedPool := newShardedIPPool(i.client, firstIP, pool)
+	for idx := range shardList.Items {
+		shardedPool.addShard(&shardList.Items[idx])
+	}
+	return shardedPool, nil
+}
+
+func newShardedIPPool(client wbclient.Interface, firstIP net.IP, pool *whereaboutsv1alpha1.IPPool) *KubernetesShardedIPPool {
+	return &KubernetesShardedIPPool{
+		client:  client,
+		firstIP: firstIP,
+		pool:    pool,
+		shards:  map[int64]*whereaboutsv1alpha1.IPPool{},
+	}
+}
+
+func (p *KubernetesShardedIPPool) addShard(shard *whereaboutsv1alpha1.IPPool) {
+	if shard.Spec.Shard == nil || shard.Spec.Shard.Parent != p.pool.GetName() {
+		return
+	}
+	p.shards[shard.Spec.Shard.Start/p.pool.Spec.ShardSize] = shard
+}
+
+// Allocations returns the allocations of all the shards of the pool
+func (p *KubernetesShardedIPPool) Allocations() []whereaboutstypes.IPReservation {
+	reservations := []whereaboutstypes.IPReservation{}
+	for _, shard := range p.shards {
+		reservations = append(reservations, toIPReservationList(shard.Spec.Allocations, p.firstIP)...)
+	}
+	return reservations
+}
+
+// Name returns the name of the IPPool resource the shards belong to
+func (p *KubernetesShardedIPPool) Name() string {
+	return p.pool.GetName()
+}
+
+// Namespace returns the namespace of the IPPool resource the shards belong to
+func (p *KubernetesShardedIPPool) Namespace() string {
+	return p.pool.GetNamespace()
+}
+
+// Update sets the allocations of the pool to the given IP reservations: the shards whose allocations changed are
+// patched, as long as they were not updated since they were read, and the missing shards holding new allocations
+// are created. The shards left empty are kept, for the next allocations.
+func (p *KubernetesShardedIPPool) Update(ctx context.Context, reservations []whereaboutstypes.IPReservation) error {
+	reservationsPerShard := map[int64][]whereaboutstypes.IPReservation{}
+	for index := range p.shards {
+		reservationsPerShard[index] = nil
+	}
+	for _, reservation := range reservations {
+		offset, err := iphelpers.IPGetOffset(reservation.IP, p.firstIP)
+		if err != nil {
+			return err
+		}
+		index := int64(offset) / p.pool.Spec.ShardSize
+		reservationsPerShard[index] = append(reservationsPerShard[index], reservation)
+	}
+
+	var indexes []int64
+	for index := range reservationsPerShard {
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(a, b int) bool { return indexes[a] < indexes[b] })
+
+	for _, index := range indexes {
+		allocations, err := toAllocationMap(reservationsPerShard[index], p.firstIP)
+		if err != nil {
+			return err
+		}
+		shard, ok := p.shards[index]
+		if !ok {
+			if err := p.createShard(ctx, index, allocations); err != nil {
+				return err
+			}
+			continue
+		}
+		if len(shard.Spec.Allocations) == 0 && len(allocations) == 0 || reflect.DeepEqual(shard.Spec.Allocations, allocations) {
+			continue
+		}
+		shardPool := &KubernetesIPPool{client: p.client, firstIP: p.firstIP, pool: shard}
+		if err := shardPool.Update(ctx, reservationsPerShard[index]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *KubernetesShardedIPPool) createShard(ctx context.Context, index int64, allocations map[string]whereaboutsv1alpha1.IPAllocation) error {
+	shardSize := p.pool.Spec.ShardSize
+	labels := map[string]string{ParentPoolLabel: p.pool.GetName()}
+	if tenantNamespace, ok := p.pool.GetLabels()[TenantNamespaceLabel]; ok {
+		labels[TenantNamespaceLabel] = tenantNamespace
+	}
+	shard := &whereaboutsv1alpha1.IPPool{
+		ObjectMeta: hashedObjectMeta(shardOriginalName(p.pool.GetName(), index), labels),
+		Spec: whereaboutsv1alpha1.IPPoolSpec{
+			Range:       p.pool.Spec.Range,
+			Allocations: allocations,
+			Shard: &whereaboutsv1alpha1.IPPoolShard{
+				Parent: p.pool.GetName(),
+				Start:  index * shardSize,
+				End:    (index + 1) * shardSize,
+			},
+		},
+	}
+	shard.Namespace = p.pool.GetNamespace()
+	// the shards are garbage collected along with their pool
+	shard.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: whereaboutsv1alpha1.SchemeGroupVersion.String(),
+		Kind:       "IPPool",
+		Name:       p.pool.GetName(),
+		UID:        p.pool.GetUID(),
+	}}
+
+	_, err := p.client.WhereaboutsV1alpha1().IPPools(shard.GetNamespace()).Create(ctx, shard, metav1.CreateOptions{})
+	if err != nil && errors.IsAlreadyExists(err) {
+		// the shard was just created by another allocator -- allow retry
+		return &temporaryError{err}
+	} else if err != nil {
+		return BackendError(fmt.Errorf("k8s create IPPool shard error: %w", err))
+	}
+	return nil
+}
+
+// ShardName returns the name of the shard of the given pool holding the index-th slice of its offsets.
+func ShardName(poolName string, index int64) string {
+	return objectName(shardOriginalName(poolName, index))
+}
+
+func shardOriginalName(poolName string, index int64) string {
+	return fmt.Sprintf("%s-shard-%d", poolName, index)
+}
+
+// ShardSelector selects the shards of the given pool.
+func ShardSelector(poolName string) labels.Selector {
+	return labels.SelectorFromSet(labels.Set{ParentPoolLabel: LabelValue(poolName)})
+}
+
+// groupShards returns the pools of a list of IPPools, the shards being grouped with the pool they belong to; the
+// shards whose pool is not listed are returned as pools of their own.
+func groupShards(client wbclient.Interface, ipPools []whereaboutsv1alpha1.IPPool) ([]storage.IPPool, error) {
+	type poolKey struct {
+		namespace string
+		name      string
+	}
+
+	var pools []storage.IPPool
+	shardedPools := map[poolKey]*KubernetesShardedIPPool{}
+	for idx, pool := range ipPools {
+		if pool.Spec.ShardSize <= 0 {
+			continue
+		}
+		firstIP, _, err := pool.ParseCIDR()
+		if err != nil {
+			return nil, err
+		}
+		shardedPool := newShardedIPPool(client, firstIP, &ipPools[idx])
+		shardedPools[poolKey{namespace: pool.GetNamespace(), name: pool.GetName()}] = shardedPool
+		pools = append(pools, shardedPool)
+	}
+
+	for idx, pool := range ipPools {
+		if pool.Spec.ShardSize > 0 {
+			continue
+		}
+		if pool.Spec.Shard != nil {
+			if shardedPool, ok := shardedPools[poolKey{namespace: pool.GetNamespace(), name: pool.Spec.Shard.Parent}]; ok {
+				shardedPool.addShard(&ipPools[idx])
+				continue
+			}
+		}
+		firstIP, _, err := pool.ParseCIDR()
+		if err != nil {
+			return nil, err
+		}
+		pools = append(pools, &KubernetesIPPool{client: client, firstIP: firstIP, pool: &ipPools[idx]})
+	}
+	return pools, nil
+}
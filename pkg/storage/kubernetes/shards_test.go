@@ -0,0 +1,165 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	whereaboutsv1alpha1 "github.com/k8snetworkplumbingwg/whereabouts/pkg/api/whereabouts.cni.cncf.io/v1alpha1"
+	wbfake "github.com/k8snetworkplumbingwg/whereabouts/pkg/generated/clientset/versioned/fake"
+	whereaboutstypes "github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+const shardsNamespace = "kube-system"
+
+func shardedIPManagement(t *testing.T, wbClientSet *wbfake.Clientset, mode int, ipRange string, pod int) []string {
+	t.Helper()
+	ipamConf := whereaboutstypes.IPAMConfig{
+		PodName:             fmt.Sprintf("pod%d", pod),
+		PodNamespace:        "default",
+		PoolShardSize:       4,
+		LeaderLeaseDuration: 1500,
+		LeaderRenewDeadline: 1000,
+		LeaderRetryPeriod:   500,
+		IPRanges:            []whereaboutstypes.RangeConfiguration{{Range: ipRange}},
+	}
+	ipam := newKubernetesIPAM(fmt.Sprintf("container%d", pod), "eth0", ipamConf, shardsNamespace,
+		*NewKubernetesClient(wbClientSet, k8sfake.NewSimpleClientset()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ips, err := IPManagement(ctx, mode, ipamConf, ipam)
+	if err != nil {
+		t.Fatalf("IP management of pod%d failed: %v", pod, err)
+	}
+	var addresses []string
+	for _, ip := range ips {
+		addresses = append(addresses, ip.IP.String())
+	}
+	return addresses
+}
+
+// newShardsClientSet returns a fake clientset which sets the resourceVersion of the IPPools it creates, as the pool
+// updates are guarded by it.
+func newShardsClientSet() *wbfake.Clientset {
+	wbClientSet := wbfake.NewSimpleClientset()
+	wbClientSet.PrependReactor("create", "ippools", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		action.(k8stesting.CreateAction).GetObject().(*whereaboutsv1alpha1.IPPool).ResourceVersion = "1"
+		return false, nil, nil
+	})
+	return wbClientSet
+}
+
+func getIPPool(t *testing.T, wbClientSet *wbfake.Clientset, name string) *whereaboutsv1alpha1.IPPool {
+	t.Helper()
+	pool, err := wbClientSet.WhereaboutsV1alpha1().IPPools(shardsNamespace).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get IPPool %s: %v", name, err)
+	}
+	return pool
+}
+
+func allocationOffsets(pool *whereaboutsv1alpha1.IPPool) []string {
+	var offsets []string
+	for offset := range pool.Spec.Allocations {
+		offsets = append(offsets, offset)
+	}
+	sort.Strings(offsets)
+	return offsets
+}
+
+func TestShardedIPPool(t *testing.T) {
+	const ipRange = "10.0.0.0/24"
+
+	wbClientSet := newShardsClientSet()
+	for pod := 1; pod <= 6; pod++ {
+		shardedIPManagement(t, wbClientSet, whereaboutstypes.Allocate, ipRange, pod)
+	}
+
+	pool := getIPPool(t, wbClientSet, "10.0.0.0-24")
+	if pool.Spec.ShardSize != 4 || len(pool.Spec.Allocations) != 0 {
+		t.Fatalf("expected an empty pool sharded by 4 offsets, got %+v", pool.Spec)
+	}
+
+	shard := getIPPool(t, wbClientSet, "10.0.0.0-24-shard-1")
+	if shard.Labels[ParentPoolLabel] != "10.0.0.0-24" {
+		t.Errorf("expected the shard to be labelled with its pool, got %v", shard.Labels)
+	}
+	if len(shard.OwnerReferences) != 1 || shard.OwnerReferences[0].Name != "10.0.0.0-24" || shard.OwnerReferences[0].Kind != "IPPool" {
+		t.Errorf("expected the shard to be owned by its pool, got %v", shard.OwnerReferences)
+	}
+	expectedShard := whereaboutsv1alpha1.IPPoolShard{Parent: "10.0.0.0-24", Start: 4, End: 8}
+	if shard.Spec.Range != ipRange || *shard.Spec.Shard != expectedShard {
+		t.Errorf("expected shard %+v of range %s, got %+v", expectedShard, ipRange, shard.Spec)
+	}
+	if offsets := allocationOffsets(shard); !reflect.DeepEqual(offsets, []string{"4", "5", "6"}) {
+		t.Errorf("unexpected offsets in the second shard: %v", offsets)
+	}
+	if offsets := allocationOffsets(getIPPool(t, wbClientSet, "10.0.0.0-24-shard-0")); !reflect.DeepEqual(offsets, []string{"1", "2", "3"}) {
+		t.Errorf("unexpected offsets in the first shard: %v", offsets)
+	}
+
+	wbClientSet.ClearActions()
+	shardedIPManagement(t, wbClientSet, whereaboutstypes.Deallocate, ipRange, 2)
+	var patched []string
+	for _, action := range wbClientSet.Actions() {
+		if patch, ok := action.(k8stesting.PatchAction); ok {
+			patched = append(patched, patch.GetName())
+		}
+	}
+	if !reflect.DeepEqual(patched, []string{"10.0.0.0-24-shard-0"}) {
+		t.Errorf("expected only the shard holding the released IP to be patched, got %v", patched)
+	}
+	if offsets := allocationOffsets(getIPPool(t, wbClientSet, "10.0.0.0-24-shard-0")); !reflect.DeepEqual(offsets, []string{"1", "3"}) {
+		t.Errorf("unexpected offsets in the first shard after the release: %v", offsets)
+	}
+
+	if ips := shardedIPManagement(t, wbClientSet, whereaboutstypes.Allocate, ipRange, 7); !reflect.DeepEqual(ips, []string{"10.0.0.2"}) {
+		t.Errorf("expected the released IP to be allocated again, got %v", ips)
+	}
+
+	pools, err := NewKubernetesClient(wbClientSet, k8sfake.NewSimpleClientset()).ListIPPools()
+	if err != nil {
+		t.Fatalf("failed to list the pools: %v", err)
+	}
+	if len(pools) != 1 {
+		t.Fatalf("expected the shards to be listed as a single pool, got %d pools", len(pools))
+	}
+	if shardedPool, ok := pools[0].(*KubernetesShardedIPPool); !ok || shardedPool.Name() != "10.0.0.0-24" || len(shardedPool.Allocations()) != 6 {
+		t.Errorf("expected the sharded pool with its 6 allocations, got %+v", pools[0])
+	}
+}
+
+func TestSmallRangesAreNotSharded(t *testing.T) {
+	wbClientSet := newShardsClientSet()
+	shardedIPManagement(t, wbClientSet, whereaboutstypes.Allocate, "10.0.0.0/30", 1)
+
+	pool := getIPPool(t, wbClientSet, "10.0.0.0-30")
+	if pool.Spec.ShardSize != 0 || len(pool.Spec.Allocations) != 1 {
+		t.Errorf("expected the range to be kept in a single IPPool, got %+v", pool.Spec)
+	}
+}
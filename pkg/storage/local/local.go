@@ -0,0 +1,313 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package local implements the node-local storage backend, for the single-node sites and the clusters whose API
+// server is not available yet: the pools and the overlapping range reservations are kept in a JSON file next to
+// the whereabouts flat configuration file, guarded by a file lock shared by all the CNI invocations of the node.
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/api/whereabouts.cni.cncf.io/v1alpha1"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/logging"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+const (
+	// DefaultPath is where the datastore is kept when the IPAM configuration does not specify a configuration path.
+	DefaultPath = "/etc/cni/net.d/whereabouts.d/" + fileName
+
+	fileName       = "whereabouts-datastore.json"
+	lockSuffix     = ".lock"
+	fileMode       = 0600
+	dirMode        = 0700
+	lockRetryDelay = 5 * time.Millisecond
+)
+
+var (
+	_ storage.Store                 = &Store{}
+	_ storage.OverlappingRangeStore = &Store{}
+)
+
+// Path returns the path of the datastore for the given IPAM configuration: it sits next to the whereabouts flat
+// configuration file.
+func Path(ipamConf types.IPAMConfig) string {
+	if ipamConf.ConfigurationPath == "" {
+		return DefaultPath
+	}
+	return filepath.Join(filepath.Dir(ipamConf.ConfigurationPath), fileName)
+}
+
+// Store is a storage.Store kept in a file. Any number of processes may use the same file concurrently.
+type Store struct {
+	path string
+}
+
+// New returns the store kept in path; the file is created on the first update.
+func New(path string) *Store {
+	return &Store{path: path}
+}
+
+// database is the content of the datastore file.
+type database struct {
+	Pools             map[string]*poolRecord                                `json:"pools,omitempty"`
+	OverlappingRanges map[string]v1alpha1.OverlappingRangeIPReservationSpec `json:"overlappingRanges,omitempty"`
+}
+
+type poolRecord struct {
+	Version     int64                 `json:"version"`
+	Allocations []types.IPReservation `json:"allocations,omitempty"`
+}
+
+// GetIPPool returns a snapshot of the pool; a missing pool is empty.
+func (s *Store) GetIPPool(ctx context.Context, poolIdentifier storage.PoolIdentifier) (storage.IPPool, error) {
+	key := poolKey(poolIdentifier)
+	record := &poolRecord{}
+	err := s.withLock(ctx, syscall.LOCK_SH, func(db *database) (bool, error) {
+		if r, ok := db.Pools[key]; ok {
+			record = r
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return storage.NewSnapshotIPPool(record.Version, record.Allocations, func(ctx context.Context, version int64, reservations []types.IPReservation) error {
+		return s.updatePool(ctx, key, version, reservations)
+	}), nil
+}
+
+// GetOverlappingRangeStore returns the store itself, which also holds the overlapping range reservations.
+func (s *Store) GetOverlappingRangeStore() (storage.OverlappingRangeStore, error) {
+	return s, nil
+}
+
+// Status checks the datastore can be locked and read.
+func (s *Store) Status(ctx context.Context) error {
+	return s.withLock(ctx, syscall.LOCK_SH, func(*database) (bool, error) { return false, nil })
+}
+
+// Close is a no-op: the file is only open while it is locked.
+func (s *Store) Close() error {
+	return nil
+}
+
+// GetOverlappingRangeIPReservation returns the reservation of ip in the given network, or nil when there is none.
+func (s *Store) GetOverlappingRangeIPReservation(ctx context.Context, ip net.IP, _, networkName string) (*v1alpha1.OverlappingRangeIPReservation, error) {
+	var reservation *v1alpha1.OverlappingRangeIPReservation
+	err := s.withLock(ctx, syscall.LOCK_SH, func(db *database) (bool, error) {
+		if spec, ok := db.OverlappingRanges[overlappingRangeKey(ip, networkName)]; ok {
+			reservation = &v1alpha1.OverlappingRangeIPReservation{Spec: spec}
+		}
+		return false, nil
+	})
+	return reservation, err
+}
+
+// UpdateOverlappingRangeAllocation reserves, or releases, ip in the given network.
+func (s *Store) UpdateOverlappingRangeAllocation(ctx context.Context, mode int, ip net.IP, containerID, podRef, ifName, networkName string) error {
+	key := overlappingRangeKey(ip, networkName)
+	return s.withLock(ctx, syscall.LOCK_EX, func(db *database) (bool, error) {
+		switch mode {
+		case types.Allocate:
+			if spec, ok := db.OverlappingRanges[key]; ok {
+				return false, &storage.OverlappingRangeReservedError{IP: ip, Err: fmt.Errorf("reserved by %s", spec.PodRef)}
+			}
+			if db.OverlappingRanges == nil {
+				db.OverlappingRanges = map[string]v1alpha1.OverlappingRangeIPReservationSpec{}
+			}
+			db.OverlappingRanges[key] = v1alpha1.OverlappingRangeIPReservationSpec{ContainerID: containerID, PodRef: podRef, IfName: ifName}
+		case types.Deallocate:
+			spec, ok := db.OverlappingRanges[key]
+			if !ok {
+				return false, fmt.Errorf("overlapping range reservation of IP %s: %w", ip, storage.ErrNotFound)
+			}
+			if !storage.IsOverlappingRangeReservationOwner(spec, containerID, podRef, ifName) {
+				return false, nil
+			}
+			delete(db.OverlappingRanges, key)
+		}
+		return true, nil
+	})
+}
+
+// TransferOverlappingRangeReservation hands the reservation of ip over to another container interface of its pod.
+func (s *Store) TransferOverlappingRangeReservation(ctx context.Context, reservation *v1alpha1.OverlappingRangeIPReservation, ip net.IP,
+	containerID, ifName, networkName string) error {
+	key := overlappingRangeKey(ip, networkName)
+	return s.withLock(ctx, syscall.LOCK_EX, func(db *database) (bool, error) {
+		spec, ok := db.OverlappingRanges[key]
+		if !ok || spec != reservation.Spec {
+			return false, &storage.OverlappingRangeChangedError{IP: ip, Err: fmt.Errorf("reserved by %q", spec.ContainerID)}
+		}
+		spec.ContainerID, spec.IfName = containerID, ifName
+		db.OverlappingRanges[key] = spec
+		return true, nil
+	})
+}
+
+func (s *Store) updatePool(ctx context.Context, key string, version int64, allocations []types.IPReservation) error {
+	return s.withLock(ctx, syscall.LOCK_EX, func(db *database) (bool, error) {
+		record, ok := db.Pools[key]
+		if !ok {
+			record = &poolRecord{}
+		}
+		if record.Version != version {
+			return false, &storage.ConflictError{Pool: key, Version: version}
+		}
+
+		if db.Pools == nil {
+			db.Pools = map[string]*poolRecord{}
+		}
+		db.Pools[key] = &poolRecord{Version: record.Version + 1, Allocations: allocations}
+		return true, nil
+	})
+}
+
+// withLock calls fn with the content of the datastore while holding the file lock, taken with how (LOCK_SH or
+// LOCK_EX); the content is written back when fn reports it changed it.
+func (s *Store) withLock(ctx context.Context, how int, fn func(db *database) (bool, error)) error {
+	if how == syscall.LOCK_EX {
+		if err := os.MkdirAll(filepath.Dir(s.path), dirMode); err != nil {
+			return &storage.BackendUnavailableError{Err: fmt.Errorf("failed to create the local datastore directory: %w", err)}
+		}
+	}
+
+	unlock, err := s.lock(ctx, how)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	db, err := s.read()
+	if err != nil {
+		return err
+	}
+	changed, err := fn(db)
+	if err != nil || !changed {
+		return err
+	}
+	return s.write(db)
+}
+
+// lock takes the file lock, polling for it until ctx is done so that the time limits of the CNI invocation are
+// honored.
+func (s *Store) lock(ctx context.Context, how int) (func(), error) {
+	lockFile, err := os.OpenFile(s.path+lockSuffix, os.O_CREATE|os.O_RDWR, fileMode)
+	if os.IsNotExist(err) && how == syscall.LOCK_SH {
+		// Nothing was ever written to the datastore.
+		return func() {}, nil
+	} else if err != nil {
+		return nil, &storage.BackendUnavailableError{Err: fmt.Errorf("failed to open the local datastore lock: %w", err)}
+	}
+
+	for {
+		err := syscall.Flock(int(lockFile.Fd()), how|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			lockFile.Close()
+			return nil, &storage.BackendUnavailableError{Err: fmt.Errorf("failed to lock the local datastore: %w", err)}
+		}
+
+		select {
+		case <-ctx.Done():
+			lockFile.Close()
+			return nil, &storage.BackendUnavailableError{Err: fmt.Errorf("failed to lock the local datastore: %w", ctx.Err())}
+		case <-time.After(lockRetryDelay):
+		}
+	}
+
+	return func() {
+		_ = syscall.Flock(int(lockFile.Fd()), syscall.LOCK_UN)
+		lockFile.Close()
+	}, nil
+}
+
+func (s *Store) read() (*database, error) {
+	db := &database{}
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return db, nil
+	} else if err != nil {
+		return nil, &storage.BackendUnavailableError{Err: fmt.Errorf("failed to read the local datastore: %w", err)}
+	}
+	if err := json.Unmarshal(data, db); err != nil {
+		return nil, fmt.Errorf("corrupted local datastore %s: %w", s.path, err)
+	}
+	return db, nil
+}
+
+// write atomically replaces the content of the datastore.
+func (s *Store) write(db *database) error {
+	data, err := json.Marshal(db)
+	if err != nil {
+		return fmt.Errorf("failed to serialize the local datastore: %w", err)
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(s.path), "."+filepath.Base(s.path))
+	if err != nil {
+		return &storage.BackendUnavailableError{Err: fmt.Errorf("failed to write the local datastore: %w", err)}
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return &storage.BackendUnavailableError{Err: fmt.Errorf("failed to write the local datastore: %w", err)}
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return &storage.BackendUnavailableError{Err: fmt.Errorf("failed to write the local datastore: %w", err)}
+	}
+	if err := tmpFile.Close(); err != nil {
+		return &storage.BackendUnavailableError{Err: fmt.Errorf("failed to write the local datastore: %w", err)}
+	}
+	if err := os.Rename(tmpFile.Name(), s.path); err != nil {
+		return &storage.BackendUnavailableError{Err: fmt.Errorf("failed to write the local datastore: %w", err)}
+	}
+	logging.Debugf("local datastore %s updated", s.path)
+	return nil
+}
+
+// poolKey names a pool in the datastore, e.g. "192.168.1.0/24" or "mynet/192.168.1.0/24".
+func poolKey(poolIdentifier storage.PoolIdentifier) string {
+	key := poolIdentifier.IpRange
+	if poolIdentifier.NetworkName != "" {
+		key = poolIdentifier.NetworkName + "/" + key
+	}
+	if poolIdentifier.NodeName != "" {
+		key += "@" + poolIdentifier.NodeName
+	}
+	return key
+}
+
+func overlappingRangeKey(ip net.IP, networkName string) string {
+	if networkName == "" {
+		return ip.String()
+	}
+	return networkName + "/" + ip.String()
+}
@@ -0,0 +1,198 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/ipmanagement"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage/storagetest"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+const (
+	// helperPathEnv makes the test binary act as a CNI invocation allocating from the datastore it names.
+	helperPathEnv = "WHEREABOUTS_LOCAL_HELPER_PATH"
+	helperPodEnv  = "WHEREABOUTS_LOCAL_HELPER_POD"
+
+	ipRange            = "192.168.2.0/24"
+	allocationsPerProc = 5
+)
+
+func TestLocal(t *testing.T) {
+	if path := os.Getenv(helperPathEnv); path != "" {
+		allocateInHelperProcess(path, os.Getenv(helperPodEnv))
+		return
+	}
+
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Local storage")
+}
+
+// allocateInHelperProcess allocates allocationsPerProc IPs from the datastore in path, printing them one per line.
+func allocateInHelperProcess(path, podName string) {
+	store := New(path)
+	for i := 0; i < allocationsPerProc; i++ {
+		ips, err := ipmanagement.Manage(context.Background(), types.Allocate, store,
+			ipamConfig(fmt.Sprintf("%s-%d", podName, i)), fmt.Sprintf("%s-container-%d", podName, i), "eth0")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println(ips[0].IP)
+	}
+	os.Exit(0)
+}
+
+func ipamConfig(podName string) types.IPAMConfig {
+	return types.IPAMConfig{
+		IPRanges:          []types.RangeConfiguration{{Range: ipRange}},
+		PodNamespace:      "default",
+		PodName:           podName,
+		OverlappingRanges: true,
+	}
+}
+
+var _ = Describe("Local datastore", func() {
+	var (
+		ctx    context.Context
+		tmpDir string
+		path   string
+		store  *Store
+		id     storage.PoolIdentifier
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = os.MkdirTemp("", "whereabouts")
+		Expect(err).NotTo(HaveOccurred())
+
+		ctx = context.Background()
+		path = filepath.Join(tmpDir, "whereabouts.d", fileName)
+		store = New(path)
+		id = storage.PoolIdentifier{IpRange: ipRange}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	storagetest.Conformance(func() storage.Store { return store })
+
+	It("is kept next to the flat configuration file", func() {
+		Expect(Path(types.IPAMConfig{ConfigurationPath: "/host/etc/cni/net.d/whereabouts.d/whereabouts.conf"})).To(
+			Equal("/host/etc/cni/net.d/whereabouts.d/whereabouts-datastore.json"))
+		Expect(Path(types.IPAMConfig{})).To(Equal(DefaultPath))
+	})
+
+	It("reads a missing datastore as empty", func() {
+		Expect(store.Status(ctx)).To(Succeed())
+		pool, err := store.GetIPPool(ctx, id)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pool.Allocations()).To(BeEmpty())
+		Expect(path).NotTo(BeAnExistingFile())
+	})
+
+	It("persists the pools across stores", func() {
+		pool, err := store.GetIPPool(ctx, id)
+		Expect(err).NotTo(HaveOccurred())
+		reservation := types.IPReservation{IP: net.ParseIP("192.168.2.1"), ContainerID: "c1", PodRef: "default/pod1", IfName: "eth0"}
+		Expect(pool.Update(ctx, []types.IPReservation{reservation})).To(Succeed())
+
+		pool, err = New(path).GetIPPool(ctx, id)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pool.Allocations()).To(HaveLen(1))
+		Expect(pool.Allocations()[0].IP.Equal(reservation.IP)).To(BeTrue())
+		Expect(pool.Allocations()[0].ContainerID).To(Equal("c1"))
+	})
+
+	It("gives up waiting for the lock once the context is done", func() {
+		Expect(store.Status(ctx)).To(Succeed())
+		Expect(os.MkdirAll(filepath.Dir(path), dirMode)).To(Succeed())
+		lockFile, err := os.OpenFile(path+lockSuffix, os.O_CREATE|os.O_RDWR, fileMode)
+		Expect(err).NotTo(HaveOccurred())
+		defer lockFile.Close()
+		Expect(syscall.Flock(int(lockFile.Fd()), syscall.LOCK_EX)).To(Succeed())
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		_, err = store.GetIPPool(timeoutCtx, id)
+		var unavailable *storage.BackendUnavailableError
+		Expect(errors.As(err, &unavailable)).To(BeTrue())
+		Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+
+		Expect(syscall.Flock(int(lockFile.Fd()), syscall.LOCK_UN)).To(Succeed())
+		_, err = store.GetIPPool(ctx, id)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("reports a corrupted datastore", func() {
+		Expect(os.MkdirAll(filepath.Dir(path), dirMode)).To(Succeed())
+		Expect(os.WriteFile(path, []byte("{not json"), fileMode)).To(Succeed())
+
+		_, err := store.GetIPPool(ctx, id)
+		Expect(err).To(MatchError(ContainSubstring("corrupted local datastore")))
+	})
+
+	It("gives distinct IPs to concurrent processes", func() {
+		const processes = 8
+
+		commands := make([]*exec.Cmd, processes)
+		outputs := make([]*strings.Builder, processes)
+		for i := range commands {
+			outputs[i] = &strings.Builder{}
+			commands[i] = exec.Command(os.Args[0], "-test.run=^TestLocal$")
+			commands[i].Env = append(os.Environ(), helperPathEnv+"="+path, fmt.Sprintf("%s=pod%d", helperPodEnv, i))
+			commands[i].Stdout = outputs[i]
+			commands[i].Stderr = GinkgoWriter
+			Expect(commands[i].Start()).To(Succeed())
+		}
+
+		seen := map[string]bool{}
+		for i, command := range commands {
+			Expect(command.Wait()).To(Succeed())
+			ips := strings.Fields(outputs[i].String())
+			Expect(ips).To(HaveLen(allocationsPerProc))
+			for _, ip := range ips {
+				Expect(seen).NotTo(HaveKey(ip))
+				seen[ip] = true
+			}
+		}
+
+		pool, err := store.GetIPPool(ctx, id)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pool.Allocations()).To(HaveLen(processes * allocationsPerProc))
+
+		db, err := store.read()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(db.OverlappingRanges).To(HaveLen(processes * allocationsPerProc))
+	})
+})
@@ -0,0 +1,89 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage"
+)
+
+// Operation is an operation of the store in which faults can be injected.
+type Operation string
+
+const (
+	OpStatus                 Operation = "status"
+	OpGetPool                Operation = "get-pool"
+	OpUpdatePool             Operation = "update-pool"
+	OpGetOverlappingRange    Operation = "get-overlapping-range"
+	OpUpdateOverlappingRange Operation = "update-overlapping-range"
+)
+
+// Fault is a failure injected in an operation of the store.
+type Fault int
+
+const (
+	// FaultConflict fails the operation as if a concurrent writer got there first.
+	FaultConflict Fault = iota
+	// FaultTimeout fails the operation as if the backend did not answer in time.
+	FaultTimeout
+	// FaultNotFound fails the operation as if the object was missing.
+	FaultNotFound
+)
+
+// InjectFault makes the next times calls of op fail with fault, after the faults already injected in op.
+func (s *Store) InjectFault(op Operation, fault Fault, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := 0; i < times; i++ {
+		s.faults[op] = append(s.faults[op], fault)
+	}
+}
+
+// Calls returns how many times op was called, including the calls which failed.
+func (s *Store) Calls(op Operation) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.calls[op]
+}
+
+// call records a call of op, and returns the error of the next fault injected in it, if any. s.mu must be held.
+func (s *Store) call(ctx context.Context, op Operation) error {
+	s.calls[op]++
+	if err := ctx.Err(); err != nil {
+		return &storage.BackendUnavailableError{Err: err}
+	}
+	if len(s.faults[op]) == 0 {
+		return nil
+	}
+
+	fault := s.faults[op][0]
+	s.faults[op] = s.faults[op][1:]
+	switch fault {
+	case FaultConflict:
+		return &storage.ConflictError{}
+	case FaultTimeout:
+		return &storage.BackendUnavailableError{Err: fmt.Errorf("%s: %w", op, context.DeadlineExceeded)}
+	case FaultNotFound:
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	default:
+		return fmt.Errorf("%s: unknown fault %d", op, fault)
+	}
+}
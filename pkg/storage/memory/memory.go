@@ -0,0 +1,246 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package memory implements a storage backend keeping the pools in memory, for the tests of the allocation loop and
+// of its callers. Like the Kubernetes backend, it detects the concurrent updates of a pool through its version, and
+// it allows injecting faults in its operations.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/api/whereabouts.cni.cncf.io/v1alpha1"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+var (
+	_ storage.Store                 = &Store{}
+	_ storage.NodeSliceStore        = &Store{}
+	_ storage.OverlappingRangeStore = &Store{}
+)
+
+// Store is an in-memory storage.Store. The zero value is not usable; use New.
+type Store struct {
+	mu                sync.Mutex
+	pools             map[storage.PoolIdentifier]*pool
+	overlappingRanges map[string]v1alpha1.OverlappingRangeIPReservationSpec
+	nodeName          string
+	nodeSliceRange    string
+	faults            map[Operation][]Fault
+	calls             map[Operation]int
+}
+
+type pool struct {
+	version      int64
+	reservations []types.IPReservation
+}
+
+// New returns an empty store.
+func New() *Store {
+	return &Store{
+		pools:             map[storage.PoolIdentifier]*pool{},
+		overlappingRanges: map[string]v1alpha1.OverlappingRangeIPReservationSpec{},
+		faults:            map[Operation][]Fault{},
+		calls:             map[Operation]int{},
+	}
+}
+
+// GetIPPool returns a snapshot of the pool, creating it when missing.
+func (s *Store) GetIPPool(ctx context.Context, poolIdentifier storage.PoolIdentifier) (storage.IPPool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.call(ctx, OpGetPool); err != nil {
+		return nil, err
+	}
+	p := s.pool(poolIdentifier)
+	return storage.NewSnapshotIPPool(p.version, p.reservations, func(ctx context.Context, version int64, reservations []types.IPReservation) error {
+		return s.updatePool(ctx, poolIdentifier, version, reservations)
+	}), nil
+}
+
+// GetOverlappingRangeStore returns the store itself, which also holds the overlapping range reservations.
+func (s *Store) GetOverlappingRangeStore() (storage.OverlappingRangeStore, error) {
+	return s, nil
+}
+
+// Status fails only when a fault is injected.
+func (s *Store) Status(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.call(ctx, OpStatus)
+}
+
+// Close does nothing.
+func (s *Store) Close() error {
+	return nil
+}
+
+// SetNodeSlice makes the store support the node slices, allocating the given range to the given node.
+func (s *Store) SetNodeSlice(nodeName, sliceRange string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nodeName = nodeName
+	s.nodeSliceRange = sliceRange
+}
+
+// NodeSliceRange returns the node slice set with SetNodeSlice.
+func (s *Store) NodeSliceRange(context.Context) (string, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.nodeSliceRange == "" {
+		return "", "", fmt.Errorf("no allocated node slice for node")
+	}
+	return s.nodeName, s.nodeSliceRange, nil
+}
+
+// GetOverlappingRangeIPReservation returns the reservation of ip in the given network, or nil when there is none.
+func (s *Store) GetOverlappingRangeIPReservation(ctx context.Context, ip net.IP, _, networkName string) (*v1alpha1.OverlappingRangeIPReservation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.call(ctx, OpGetOverlappingRange); err != nil {
+		return nil, err
+	}
+	key := overlappingRangeKey(ip, networkName)
+	spec, ok := s.overlappingRanges[key]
+	if !ok {
+		return nil, nil
+	}
+	return &v1alpha1.OverlappingRangeIPReservation{Spec: spec}, nil
+}
+
+// UpdateOverlappingRangeAllocation reserves, or releases, ip in the given network.
+func (s *Store) UpdateOverlappingRangeAllocation(ctx context.Context, mode int, ip net.IP, containerID, podRef, ifName, networkName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.call(ctx, OpUpdateOverlappingRange); err != nil {
+		return err
+	}
+	key := overlappingRangeKey(ip, networkName)
+	switch mode {
+	case types.Allocate:
+		if spec, ok := s.overlappingRanges[key]; ok {
+			return &storage.OverlappingRangeReservedError{IP: ip, Err: fmt.Errorf("reserved by %s", spec.PodRef)}
+		}
+		s.overlappingRanges[key] = v1alpha1.OverlappingRangeIPReservationSpec{ContainerID: containerID, PodRef: podRef, IfName: ifName}
+	case types.Deallocate:
+		spec, ok := s.overlappingRanges[key]
+		if !ok {
+			return fmt.Errorf("overlapping range reservation of IP %s: %w", ip, storage.ErrNotFound)
+		}
+		if storage.IsOverlappingRangeReservationOwner(spec, containerID, podRef, ifName) {
+			delete(s.overlappingRanges, key)
+		}
+	}
+	return nil
+}
+
+// TransferOverlappingRangeReservation hands the reservation of ip over to another container interface of its pod.
+func (s *Store) TransferOverlappingRangeReservation(ctx context.Context, reservation *v1alpha1.OverlappingRangeIPReservation, ip net.IP,
+	containerID, ifName, networkName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.call(ctx, OpUpdateOverlappingRange); err != nil {
+		return err
+	}
+	key := overlappingRangeKey(ip, networkName)
+	spec, ok := s.overlappingRanges[key]
+	if !ok || spec != reservation.Spec {
+		return &storage.OverlappingRangeChangedError{IP: ip, Err: fmt.Errorf("reserved by %q", spec.ContainerID)}
+	}
+	spec.ContainerID, spec.IfName = containerID, ifName
+	s.overlappingRanges[key] = spec
+	return nil
+}
+
+// Allocations returns the current reservations of the pool.
+func (s *Store) Allocations(poolIdentifier storage.PoolIdentifier) []types.IPReservation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if p, ok := s.pools[poolIdentifier]; ok {
+		return copyReservations(p.reservations)
+	}
+	return nil
+}
+
+// SetAllocations replaces the reservations of the pool, creating it when missing.
+func (s *Store) SetAllocations(poolIdentifier storage.PoolIdentifier, reservations []types.IPReservation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.pool(poolIdentifier)
+	p.reservations = copyReservations(reservations)
+	p.version++
+}
+
+// OverlappingRangeReservations returns the overlapping range reservations, keyed by network name and IP.
+func (s *Store) OverlappingRangeReservations() map[string]v1alpha1.OverlappingRangeIPReservationSpec {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reservations := make(map[string]v1alpha1.OverlappingRangeIPReservationSpec, len(s.overlappingRanges))
+	for key, spec := range s.overlappingRanges {
+		reservations[key] = spec
+	}
+	return reservations
+}
+
+func (s *Store) pool(poolIdentifier storage.PoolIdentifier) *pool {
+	p, ok := s.pools[poolIdentifier]
+	if !ok {
+		p = &pool{}
+		s.pools[poolIdentifier] = p
+	}
+	return p
+}
+
+func (s *Store) updatePool(ctx context.Context, poolIdentifier storage.PoolIdentifier, version int64, reservations []types.IPReservation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.call(ctx, OpUpdatePool); err != nil {
+		return err
+	}
+	p := s.pool(poolIdentifier)
+	if p.version != version {
+		return &storage.ConflictError{Pool: fmt.Sprintf("%v", poolIdentifier), Version: version}
+	}
+	p.reservations = copyReservations(reservations)
+	p.version++
+	return nil
+}
+
+func overlappingRangeKey(ip net.IP, networkName string) string {
+	return networkName + "/" + ip.String()
+}
+
+func copyReservations(reservations []types.IPReservation) []types.IPReservation {
+	if reservations == nil {
+		return nil
+	}
+	return append([]types.IPReservation(nil), reservations...)
+}
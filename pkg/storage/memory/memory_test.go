@@ -0,0 +1,94 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage/storagetest"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+func TestMemory(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "In-memory storage")
+}
+
+var _ = Describe("In-memory store", func() {
+	const ipRange = "192.168.2.0/24"
+
+	var (
+		ctx   context.Context
+		store *Store
+		id    storage.PoolIdentifier
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		store = New()
+		id = storage.PoolIdentifier{IpRange: ipRange}
+	})
+
+	storagetest.Conformance(func() storage.Store { return store })
+
+	reservation := func(ip, podRef string) types.IPReservation {
+		return types.IPReservation{IP: net.ParseIP(ip), PodRef: podRef, IfName: "eth0"}
+	}
+
+	It("does not share the reservations with the snapshots", func() {
+		store.SetAllocations(id, []types.IPReservation{reservation("192.168.2.1", "default/pod1")})
+		pool, err := store.GetIPPool(ctx, id)
+		Expect(err).NotTo(HaveOccurred())
+
+		pool.Allocations()[0].PodRef = "default/other"
+		Expect(store.Allocations(id)[0].PodRef).To(Equal("default/pod1"))
+	})
+
+	It("fails the operations with the injected faults, in order", func() {
+		store.InjectFault(OpGetPool, FaultTimeout, 1)
+		store.InjectFault(OpGetPool, FaultNotFound, 1)
+
+		_, err := store.GetIPPool(ctx, id)
+		Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+		var unavailable *storage.BackendUnavailableError
+		Expect(errors.As(err, &unavailable)).To(BeTrue())
+
+		_, err = store.GetIPPool(ctx, id)
+		Expect(errors.Is(err, storage.ErrNotFound)).To(BeTrue())
+
+		_, err = store.GetIPPool(ctx, id)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(store.Calls(OpGetPool)).To(Equal(3))
+	})
+
+	It("fails the operations once the context is done", func() {
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		err := store.Status(canceled)
+		var unavailable *storage.BackendUnavailableError
+		Expect(errors.As(err, &unavailable)).To(BeTrue())
+		Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+	})
+})
@@ -0,0 +1,155 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	defaultRetryBaseDelay = 5 * time.Millisecond
+	defaultRetryMaxDelay  = 500 * time.Millisecond
+)
+
+// ErrRetriesExhausted is returned once all the attempts allowed by a RetryPolicy were made.
+var ErrRetriesExhausted = errors.New("retries exhausted")
+
+// RetryPolicy paces the attempts at reading and updating a pool. Each conflict -- a concurrent writer got to the pool
+// first -- is followed by an exponentially growing, jittered, delay, so that concurrent writers spread their attempts
+// rather than hammering the backend.
+type RetryPolicy struct {
+	// MaxAttempts bounds the number of attempts.
+	MaxAttempts int
+	// BaseDelay follows the first conflict; the delay doubles with every further conflict, up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// DefaultRetryPolicy returns the policy of the pool updates.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: DatastoreRetries,
+		BaseDelay:   defaultRetryBaseDelay,
+		MaxDelay:    defaultRetryMaxDelay,
+	}
+}
+
+// NewRetrier returns a Retrier making its attempts under the policy, until ctx is done.
+func (p RetryPolicy) NewRetrier(ctx context.Context) *Retrier {
+	return &Retrier{ctx: ctx, policy: p}
+}
+
+// backoff returns the delay following the given number of conflicts: half of it is fixed, the other half random.
+func (p RetryPolicy) backoff(conflicts int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < conflicts && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, p.MaxDelay)
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// Retrier tracks the attempts made under a RetryPolicy. It is meant to drive a retry loop:
+//
+//	retry := policy.NewRetrier(ctx)
+//	for retry.Next() {
+//		if err := update(); isConflict(err) {
+//			retry.Conflict()
+//			continue
+//		}
+//		break
+//	}
+type Retrier struct {
+	ctx       context.Context
+	policy    RetryPolicy
+	attempts  int
+	conflicts int
+	backoff   bool
+	err       error
+}
+
+// Next tells whether another attempt may be made, after waiting for the backoff delay when the previous attempt
+// conflicted. It returns false once the attempts are exhausted, or when the context is done or would be before the
+// end of the delay; Err then tells why.
+func (r *Retrier) Next() bool {
+	if r.err != nil {
+		return false
+	}
+	if r.attempts >= r.policy.MaxAttempts {
+		r.err = fmt.Errorf("%w after %d attempts (%d conflicts)", ErrRetriesExhausted, r.attempts, r.conflicts)
+		return false
+	}
+
+	if r.backoff {
+		r.backoff = false
+		if !r.wait(r.policy.backoff(r.conflicts)) {
+			return false
+		}
+	}
+	if err := r.ctx.Err(); err != nil {
+		r.err = err
+		return false
+	}
+
+	r.attempts++
+	return true
+}
+
+// Conflict records that the current attempt conflicted with a concurrent writer; the next attempt is delayed.
+func (r *Retrier) Conflict() {
+	r.conflicts++
+	r.backoff = true
+}
+
+// Attempts returns the number of attempts made so far.
+func (r *Retrier) Attempts() int {
+	return r.attempts
+}
+
+// Conflicts returns the number of attempts which conflicted.
+func (r *Retrier) Conflicts() int {
+	return r.conflicts
+}
+
+// Err returns why no further attempt may be made, or nil.
+func (r *Retrier) Err() error {
+	return r.err
+}
+
+func (r *Retrier) wait(delay time.Duration) bool {
+	if deadline, ok := r.ctx.Deadline(); ok && time.Until(deadline) < delay {
+		r.err = fmt.Errorf("%w: backing off for %v would exceed the deadline", context.DeadlineExceeded, delay)
+		return false
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-r.ctx.Done():
+		r.err = r.ctx.Err()
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Retry policy", func() {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: 10 * time.Millisecond, MaxDelay: 80 * time.Millisecond}
+
+	It("backs off exponentially, with jitter, up to the maximum delay", func() {
+		for i := 0; i < 20; i++ {
+			Expect(policy.backoff(1)).To(BeNumerically(">=", 5*time.Millisecond))
+			Expect(policy.backoff(1)).To(BeNumerically("<=", 10*time.Millisecond))
+			Expect(policy.backoff(3)).To(BeNumerically(">=", 20*time.Millisecond))
+			Expect(policy.backoff(3)).To(BeNumerically("<=", 40*time.Millisecond))
+			Expect(policy.backoff(100)).To(BeNumerically(">=", 40*time.Millisecond))
+			Expect(policy.backoff(100)).To(BeNumerically("<=", 80*time.Millisecond))
+		}
+	})
+
+	It("counts the attempts and the conflicts, and gives up once the attempts are exhausted", func() {
+		retry := policy.NewRetrier(context.Background())
+		for retry.Next() {
+			retry.Conflict()
+		}
+		Expect(retry.Attempts()).To(Equal(3))
+		Expect(retry.Conflicts()).To(Equal(3))
+		Expect(errors.Is(retry.Err(), ErrRetriesExhausted)).To(BeTrue())
+	})
+
+	It("does not delay the attempts which did not conflict", func() {
+		retry := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour}.NewRetrier(context.Background())
+		Expect(retry.Next()).To(BeTrue())
+		Expect(retry.Next()).To(BeTrue())
+		Expect(retry.Conflicts()).To(BeZero())
+	})
+
+	It("gives up rather than backing off past the deadline of the context", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		retry := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour}.NewRetrier(ctx)
+		Expect(retry.Next()).To(BeTrue())
+		retry.Conflict()
+
+		start := time.Now()
+		Expect(retry.Next()).To(BeFalse())
+		Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+		Expect(errors.Is(retry.Err(), context.DeadlineExceeded)).To(BeTrue())
+	})
+
+	It("stops once the context is cancelled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		retry := policy.NewRetrier(ctx)
+		Expect(retry.Next()).To(BeFalse())
+		Expect(retry.Attempts()).To(BeZero())
+		Expect(errors.Is(retry.Err(), context.Canceled)).To(BeTrue())
+	})
+})
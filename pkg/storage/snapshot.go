@@ -0,0 +1,87 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+// ErrNotFound is returned when releasing an overlapping range reservation which does not exist.
+var ErrNotFound = errors.New("not found")
+
+// ConflictError is returned when updating a SnapshotIPPool whose pool was updated since the snapshot was taken, or
+// a pool kept as one object per IP one of whose objects was. It is temporary: the allocation loop reads the pool
+// again, and only reports an UpdateConflictError once it gives up.
+type ConflictError struct {
+	Pool    string
+	Version int64
+	// Object names the changed object of a pool kept as one object per IP.
+	Object string
+}
+
+func (e *ConflictError) Error() string {
+	if e.Object != "" {
+		return fmt.Sprintf("%s of pool %s was changed concurrently", e.Object, e.Pool)
+	}
+	return fmt.Sprintf("pool %s was updated since version %d", e.Pool, e.Version)
+}
+
+// Temporary tells the allocation loop to retry.
+func (e *ConflictError) Temporary() bool {
+	return true
+}
+
+// UpdateFunc replaces the reservations of a pool, as long as it is still at the given version; it fails with a
+// ConflictError otherwise.
+type UpdateFunc func(ctx context.Context, version int64, reservations []types.IPReservation) error
+
+// SnapshotIPPool is an IPPool as read at a version of the pool, for the backends which keep the reservations of a
+// pool together and detect its concurrent updates through its version.
+type SnapshotIPPool struct {
+	version      int64
+	reservations []types.IPReservation
+	update       UpdateFunc
+}
+
+var _ IPPool = &SnapshotIPPool{}
+
+// NewSnapshotIPPool returns the snapshot of a pool read at the given version, which update writes back.
+func NewSnapshotIPPool(version int64, reservations []types.IPReservation, update UpdateFunc) *SnapshotIPPool {
+	return &SnapshotIPPool{version: version, reservations: copyReservations(reservations), update: update}
+}
+
+// Allocations returns the reservations of the pool, as of the snapshot.
+func (p *SnapshotIPPool) Allocations() []types.IPReservation {
+	return copyReservations(p.reservations)
+}
+
+// Update replaces the reservations of the pool; it fails with a ConflictError when the pool was updated since the
+// snapshot was taken.
+func (p *SnapshotIPPool) Update(ctx context.Context, reservations []types.IPReservation) error {
+	return p.update(ctx, p.version, reservations)
+}
+
+func copyReservations(reservations []types.IPReservation) []types.IPReservation {
+	if reservations == nil {
+		return nil
+	}
+	return append([]types.IPReservation(nil), reservations...)
+}
@@ -18,10 +18,10 @@ package storage
 
 import (
 	"context"
-	"github.com/k8snetworkplumbingwg/whereabouts/pkg/api/whereabouts.cni.cncf.io/v1alpha1"
 	"net"
 	"time"
 
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/api/whereabouts.cni.cncf.io/v1alpha1"
 	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
 )
 
@@ -34,6 +34,14 @@ var (
 	PodRefreshRetries = 3
 )
 
+// PoolIdentifier identifies an IP pool: the range it allocates from, the network it belongs to and, for the node
+// slices, the node it is dedicated to.
+type PoolIdentifier struct {
+	IpRange     string
+	NetworkName string
+	NodeName    string
+}
+
 // IPPool is the interface that represents an manageable pool of allocated IPs
 type IPPool interface {
 	Allocations() []types.IPReservation
@@ -42,16 +50,39 @@ type IPPool interface {
 
 // Store is the interface that wraps the basic IP Allocation methods on the underlying storage backend
 type Store interface {
-	GetIPPool(ctx context.Context, ipRange string) (IPPool, error)
+	GetIPPool(ctx context.Context, poolIdentifier PoolIdentifier) (IPPool, error)
 	GetOverlappingRangeStore() (OverlappingRangeStore, error)
 	Status(ctx context.Context) error
 	Close() error
 }
 
-// OverlappingRangeStore is an interface for wrapping overlappingrange storage options
+// NodeSliceStore is implemented by the storage backends supporting the node slices.
+type NodeSliceStore interface {
+	// NodeSliceRange returns the name of this node, and the range of the node slice allocated to it.
+	NodeSliceRange(ctx context.Context) (string, string, error)
+}
+
+// OverlappingRangeStore is an interface for wrapping overlappingrange storage options. An IP is reserved only once
+// per network: reserving it again fails with an OverlappingRangeReservedError, and releasing an IP which is not
+// reserved fails with ErrNotFound, or with the NotFound of the API server for the kubernetes datastore. A
+// reservation records the container interface it was made for, and is only released for that container interface,
+// so a late release of a previous owner of the IP leaves the reservation of its current owner alone.
 type OverlappingRangeStore interface {
 	GetOverlappingRangeIPReservation(ctx context.Context, ip net.IP, podRef, networkName string) (*v1alpha1.OverlappingRangeIPReservation, error)
-	UpdateOverlappingRangeAllocation(ctx context.Context, mode int, ip net.IP, podRef, ifName, networkName string) error
+	UpdateOverlappingRangeAllocation(ctx context.Context, mode int, ip net.IP, containerID, podRef, ifName, networkName string) error
+	// TransferOverlappingRangeReservation hands the reservation of ip, as read, over to another container interface of
+	// its pod; it fails with an OverlappingRangeChangedError when the reservation changed since it was read.
+	TransferOverlappingRangeReservation(ctx context.Context, reservation *v1alpha1.OverlappingRangeIPReservation, ip net.IP,
+		containerID, ifName, networkName string) error
+}
+
+// IsOverlappingRangeReservationOwner returns whether the overlapping range reservation was made for the given
+// container interface. The reservations made before the container ID was recorded are matched on their pod.
+func IsOverlappingRangeReservationOwner(spec v1alpha1.OverlappingRangeIPReservationSpec, containerID, podRef, ifName string) bool {
+	if spec.ContainerID == "" {
+		return spec.PodRef == podRef
+	}
+	return spec.ContainerID == containerID && (spec.IfName == "" || spec.IfName == ifName)
 }
 
 type Temporary interface {
@@ -0,0 +1,170 @@
+// Copyright 2025 whereabouts authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package storagetest holds the specs every storage backend keeping its pools as snapshots is held to.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/ipmanagement"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/storage"
+	"github.com/k8snetworkplumbingwg/whereabouts/pkg/types"
+)
+
+// ipRange is the range the specs allocate from.
+const ipRange = "192.168.2.0/24"
+
+// Conformance declares the specs of a storage backend, in the container of its own specs; getStore returns the
+// empty store the current spec runs against, prepared by the BeforeEach of the backend.
+func Conformance(getStore func() storage.Store) {
+	var (
+		ctx   context.Context
+		store storage.Store
+		id    storage.PoolIdentifier
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		store = getStore()
+		id = storage.PoolIdentifier{IpRange: ipRange}
+	})
+
+	overlappingRangeStore := func() storage.OverlappingRangeStore {
+		overlappingRangeStore, err := store.GetOverlappingRangeStore()
+		Expect(err).NotTo(HaveOccurred())
+		return overlappingRangeStore
+	}
+
+	It("keeps the pools of every network apart", func() {
+		pool, err := store.GetIPPool(ctx, id)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pool.Allocations()).To(BeEmpty())
+		Expect(pool.Update(ctx, []types.IPReservation{{IP: net.ParseIP("192.168.2.1"), ContainerID: "c1", PodRef: "default/pod1"}})).To(Succeed())
+
+		pool, err = store.GetIPPool(ctx, id)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pool.Allocations()).To(HaveLen(1))
+		Expect(pool.Allocations()[0].ContainerID).To(Equal("c1"))
+
+		otherNetwork, err := store.GetIPPool(ctx, storage.PoolIdentifier{IpRange: ipRange, NetworkName: "other"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(otherNetwork.Allocations()).To(BeEmpty())
+	})
+
+	It("refuses to update a pool from a stale snapshot", func() {
+		first, err := store.GetIPPool(ctx, id)
+		Expect(err).NotTo(HaveOccurred())
+		second, err := store.GetIPPool(ctx, id)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(first.Update(ctx, []types.IPReservation{{IP: net.ParseIP("192.168.2.1"), PodRef: "default/pod1"}})).To(Succeed())
+		err = second.Update(ctx, []types.IPReservation{{IP: net.ParseIP("192.168.2.1"), PodRef: "default/pod2"}})
+		var conflict *storage.ConflictError
+		Expect(errors.As(err, &conflict)).To(BeTrue())
+		Expect(conflict.Temporary()).To(BeTrue())
+
+		pool, err := store.GetIPPool(ctx, id)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pool.Allocations()).To(HaveLen(1))
+		Expect(pool.Allocations()[0].PodRef).To(Equal("default/pod1"))
+	})
+
+	It("reserves an overlapping range IP only once per network", func() {
+		ip := net.ParseIP("192.168.2.1")
+		ranges := overlappingRangeStore()
+		Expect(ranges.UpdateOverlappingRangeAllocation(ctx, types.Allocate, ip, "container1", "default/pod1", "eth0", "")).To(Succeed())
+		err := ranges.UpdateOverlappingRangeAllocation(ctx, types.Allocate, ip, "container2", "default/pod2", "eth0", "")
+		var reserved *storage.OverlappingRangeReservedError
+		Expect(errors.As(err, &reserved)).To(BeTrue())
+		Expect(ranges.UpdateOverlappingRangeAllocation(ctx, types.Allocate, ip, "container2", "default/pod2", "eth0", "other")).To(Succeed())
+
+		reservation, err := ranges.GetOverlappingRangeIPReservation(ctx, ip, "default/pod2", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reservation.Spec.PodRef).To(Equal("default/pod1"))
+		Expect(reservation.Spec.ContainerID).To(Equal("container1"))
+
+		// the late release of a previous owner of the IP keeps the reservation
+		Expect(ranges.UpdateOverlappingRangeAllocation(ctx, types.Deallocate, ip, "container0", "default/pod1", "eth0", "")).To(Succeed())
+		reservation, err = ranges.GetOverlappingRangeIPReservation(ctx, ip, "default/pod1", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reservation).NotTo(BeNil())
+
+		Expect(ranges.UpdateOverlappingRangeAllocation(ctx, types.Deallocate, ip, "container1", "default/pod1", "eth0", "")).To(Succeed())
+		reservation, err = ranges.GetOverlappingRangeIPReservation(ctx, ip, "default/pod1", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reservation).To(BeNil())
+		err = ranges.UpdateOverlappingRangeAllocation(ctx, types.Deallocate, ip, "container1", "default/pod1", "eth0", "")
+		Expect(errors.Is(err, storage.ErrNotFound)).To(BeTrue())
+
+		reservation, err = ranges.GetOverlappingRangeIPReservation(ctx, ip, "default/pod1", "other")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reservation.Spec.PodRef).To(Equal("default/pod2"))
+	})
+
+	It("gives distinct IPs to concurrent allocations", func() {
+		const allocations = 20
+
+		var wg sync.WaitGroup
+		ips := make(chan net.IP, allocations)
+		errs := make(chan error, allocations)
+		for i := 0; i < allocations; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				ipamConf := types.IPAMConfig{
+					IPRanges:          []types.RangeConfiguration{{Range: ipRange}},
+					PodNamespace:      "default",
+					PodName:           fmt.Sprintf("pod%d", i),
+					OverlappingRanges: true,
+				}
+				newIPs, err := ipmanagement.Manage(ctx, types.Allocate, store, ipamConf, fmt.Sprintf("container%d", i), "eth0")
+				if err != nil {
+					errs <- err
+					return
+				}
+				ips <- newIPs[0].IP
+			}(i)
+		}
+		wg.Wait()
+		close(ips)
+		close(errs)
+
+		Expect(errs).To(BeEmpty())
+		seen := map[string]bool{}
+		ranges := overlappingRangeStore()
+		for ip := range ips {
+			Expect(seen).NotTo(HaveKey(ip.String()))
+			seen[ip.String()] = true
+
+			reservation, err := ranges.GetOverlappingRangeIPReservation(ctx, ip, "default/other", "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reservation).NotTo(BeNil())
+		}
+		Expect(seen).To(HaveLen(allocations))
+
+		pool, err := store.GetIPPool(ctx, id)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pool.Allocations()).To(HaveLen(allocations))
+	})
+}
@@ -37,6 +37,14 @@ const (
 	DefaultSleepForRace           = 0
 )
 
+// Storage backends, selected by the `datastore` IPAM parameter.
+const (
+	DatastoreKubernetes = "kubernetes"
+	DatastoreLocal      = "local"
+	DatastoreEtcd       = "etcd"
+	DatastoreIPAddress  = "ipaddress"
+)
+
 // Net is The top-level network config - IPAM plugins are passed the full configuration
 // of the calling plugin, not just the IPAM section.
 type Net struct {
@@ -66,6 +74,7 @@ type IPAMConfig struct {
 	Name                     string
 	Type                     string               `json:"type"`
 	Routes                   []*cnitypes.Route    `json:"routes"`
+	Datastore                string               `json:"datastore,omitempty"`
 	Addresses                []Address            `json:"addresses,omitempty"`
 	IPRanges                 []RangeConfiguration `json:"ipRanges"`
 	OmitRanges               []string             `json:"exclude,omitempty"`
@@ -75,6 +84,12 @@ type IPAMConfig struct {
 	RangeStart               net.IP               `json:"range_start,omitempty"`
 	RangeEnd                 net.IP               `json:"range_end,omitempty"`
 	GatewayStr               string               `json:"gateway"`
+	EtcdHost                 string               `json:"etcd_host,omitempty"`
+	EtcdUsername             string               `json:"etcd_username,omitempty"`
+	EtcdPassword             string               `json:"etcd_password,omitempty"`
+	EtcdKeyFile              string               `json:"etcd_key_file,omitempty"`
+	EtcdCertFile             string               `json:"etcd_cert_file,omitempty"`
+	EtcdCACertFile           string               `json:"etcd_ca_cert_file,omitempty"`
 	LeaderLeaseDuration      int                  `json:"leader_lease_duration,omitempty"`
 	LeaderRenewDeadline      int                  `json:"leader_renew_deadline,omitempty"`
 	LeaderRetryPeriod        int                  `json:"leader_retry_period,omitempty"`
@@ -83,11 +98,16 @@ type IPAMConfig struct {
 	ReconcilerCronExpression string               `json:"reconciler_cron_expression,omitempty"`
 	OverlappingRanges        bool                 `json:"enable_overlapping_ranges,omitempty"`
 	SleepForRace             int                  `json:"sleep_for_race,omitempty"`
+	OptimisticConcurrency    bool                 `json:"optimistic_concurrency,omitempty"`
+	PerAddressAllocations    bool                 `json:"per_address_allocations,omitempty"`
+	PoolShardSize            int64                `json:"pool_shard_size,omitempty"`
+	TenantNamespaces         bool                 `json:"tenant_namespaces,omitempty"`
 	Gateway                  net.IP
 	Kubernetes               KubernetesConfig `json:"kubernetes,omitempty"`
 	ConfigurationPath        string           `json:"configuration_path"`
 	PodName                  string
 	PodNamespace             string
+	PodUID                   string
 	NetworkName              string `json:"network_name,omitempty"`
 }
 
@@ -120,11 +140,16 @@ func (ic *IPAMConfig) UnmarshalJSON(data []byte) error {
 		ReconcilerCronExpression string               `json:"reconciler_cron_expression,omitempty"`
 		OverlappingRanges        bool                 `json:"enable_overlapping_ranges,omitempty"`
 		SleepForRace             int                  `json:"sleep_for_race,omitempty"`
+		OptimisticConcurrency    bool                 `json:"optimistic_concurrency,omitempty"`
+		PerAddressAllocations    bool                 `json:"per_address_allocations,omitempty"`
+		PoolShardSize            int64                `json:"pool_shard_size,omitempty"`
+		TenantNamespaces         bool                 `json:"tenant_namespaces,omitempty"`
 		Gateway                  string
 		Kubernetes               KubernetesConfig `json:"kubernetes,omitempty"`
 		ConfigurationPath        string           `json:"configuration_path"`
 		PodName                  string
 		PodNamespace             string
+		PodUID                   string
 		NetworkName              string `json:"network_name,omitempty"`
 	}
 
@@ -140,6 +165,7 @@ func (ic *IPAMConfig) UnmarshalJSON(data []byte) error {
 		Name:                     ipamConfigAlias.Name,
 		Type:                     ipamConfigAlias.Type,
 		Routes:                   ipamConfigAlias.Routes,
+		Datastore:                ipamConfigAlias.Datastore,
 		Addresses:                ipamConfigAlias.Addresses,
 		IPRanges:                 ipamConfigAlias.IPRanges,
 		OmitRanges:               ipamConfigAlias.OmitRanges,
@@ -149,6 +175,12 @@ func (ic *IPAMConfig) UnmarshalJSON(data []byte) error {
 		RangeEnd:                 backwardsCompatibleIPAddress(ipamConfigAlias.RangeEnd),
 		NodeSliceSize:            ipamConfigAlias.NodeSliceSize,
 		GatewayStr:               ipamConfigAlias.GatewayStr,
+		EtcdHost:                 ipamConfigAlias.EtcdHost,
+		EtcdUsername:             ipamConfigAlias.EtcdUsername,
+		EtcdPassword:             ipamConfigAlias.EtcdPassword,
+		EtcdKeyFile:              ipamConfigAlias.EtcdKeyFile,
+		EtcdCertFile:             ipamConfigAlias.EtcdCertFile,
+		EtcdCACertFile:           ipamConfigAlias.EtcdCACertFile,
 		LeaderLeaseDuration:      ipamConfigAlias.LeaderLeaseDuration,
 		LeaderRenewDeadline:      ipamConfigAlias.LeaderRenewDeadline,
 		LeaderRetryPeriod:        ipamConfigAlias.LeaderRetryPeriod,
@@ -157,16 +189,33 @@ func (ic *IPAMConfig) UnmarshalJSON(data []byte) error {
 		OverlappingRanges:        ipamConfigAlias.OverlappingRanges,
 		ReconcilerCronExpression: ipamConfigAlias.ReconcilerCronExpression,
 		SleepForRace:             ipamConfigAlias.SleepForRace,
+		OptimisticConcurrency:    ipamConfigAlias.OptimisticConcurrency,
+		PerAddressAllocations:    ipamConfigAlias.PerAddressAllocations,
+		PoolShardSize:            ipamConfigAlias.PoolShardSize,
+		TenantNamespaces:         ipamConfigAlias.TenantNamespaces,
 		Gateway:                  backwardsCompatibleIPAddress(ipamConfigAlias.Gateway),
 		Kubernetes:               ipamConfigAlias.Kubernetes,
 		ConfigurationPath:        ipamConfigAlias.ConfigurationPath,
 		PodName:                  ipamConfigAlias.PodName,
 		PodNamespace:             ipamConfigAlias.PodNamespace,
+		PodUID:                   ipamConfigAlias.PodUID,
 		NetworkName:              ipamConfigAlias.NetworkName,
 	}
 	return nil
 }
 
+// GetDatastore returns the storage backend selected by the configuration: configurations which predate the
+// `datastore` parameter select etcd by giving an `etcd_host` without a kubeconfig.
+func (ic *IPAMConfig) GetDatastore() string {
+	if ic.Datastore != "" {
+		return ic.Datastore
+	}
+	if ic.EtcdHost != "" && ic.Kubernetes.KubeConfigPath == "" {
+		return DatastoreEtcd
+	}
+	return DatastoreKubernetes
+}
+
 func (ic *IPAMConfig) GetPodRef() string {
 	return fmt.Sprintf("%s/%s", ic.PodNamespace, ic.PodName)
 }
@@ -186,6 +235,7 @@ type IPAMEnvArgs struct {
 	GATEWAY                    cnitypes.UnmarshallableString `json:"gateway,omitempty"`
 	K8S_POD_NAME               cnitypes.UnmarshallableString //revive:disable-line
 	K8S_POD_NAMESPACE          cnitypes.UnmarshallableString //revive:disable-line
+	K8S_POD_UID                cnitypes.UnmarshallableString //revive:disable-line
 	K8S_POD_INFRA_CONTAINER_ID cnitypes.UnmarshallableString //revive:disable-line
 }
 
@@ -193,6 +243,9 @@ type IPAMEnvArgs struct {
 type KubernetesConfig struct {
 	KubeConfigPath string `json:"kubeconfig,omitempty"`
 	K8sAPIRoot     string `json:"k8s_api_root,omitempty"`
+	// Namespace is the namespace of the whereabouts objects; it takes precedence over the WHEREABOUTS_NAMESPACE
+	// environment variable and the namespace of the kubeconfig context.
+	Namespace string `json:"namespace,omitempty"`
 }
 
 // Address is our standard address.
@@ -210,6 +263,9 @@ type IPReservation struct {
 	PodRef      string `json:"podref"`
 	IfName      string `json:"ifName"`
 	IsAllocated bool
+	// IsStatic marks a reservation for a statically requested address (`addresses` or CNI_ARGS `IP=`) so
+	// that it is not mistaken for the dynamically assigned IP of the same pod/interface.
+	IsStatic bool `json:"isStatic,omitempty"`
 }
 
 func (ir IPReservation) String() string {
@@ -223,4 +279,12 @@ const (
 	Deallocate = 1
 )
 
+// Plugin specific CNI error codes; the CNI spec reserves codes 100 and above for plugins.
+const (
+	// ErrRangeExhausted is reported when no IP is left for assignment in a range.
+	ErrRangeExhausted uint = 100
+	// ErrIPConflict is reported when a requested IP is already reserved by another pod.
+	ErrIPConflict uint = 101
+)
+
 var ErrNoIPRanges = errors.New("no IP ranges in whereabouts config")